@@ -0,0 +1,49 @@
+// Copyright Sam Xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otelsql
+
+import (
+	"context"
+	"database/sql/driver"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// ComposeAttributesGetters returns an AttributesGetter returning the
+// attributes of all getters, in order, so that several libraries can each
+// contribute attributes. Nil getters are ignored.
+func ComposeAttributesGetters(getters ...AttributesGetter) AttributesGetter {
+	return AttributesGetter(composeGetters(getters))
+}
+
+// ComposeInstrumentAttributesGetters returns an InstrumentAttributesGetter
+// returning the attributes of all getters, in order. Nil getters are ignored.
+func ComposeInstrumentAttributesGetters(getters ...InstrumentAttributesGetter) InstrumentAttributesGetter {
+	return InstrumentAttributesGetter(composeGetters(getters))
+}
+
+func composeGetters[G ~func(context.Context, Method, string, []driver.NamedValue) []attribute.KeyValue](
+	getters []G,
+) func(context.Context, Method, string, []driver.NamedValue) []attribute.KeyValue {
+	return func(ctx context.Context, method Method, query string, args []driver.NamedValue) []attribute.KeyValue {
+		var attrs []attribute.KeyValue
+		for _, getter := range getters {
+			if getter != nil {
+				attrs = append(attrs, getter(ctx, method, query, args)...)
+			}
+		}
+		return attrs
+	}
+}
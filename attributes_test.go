@@ -0,0 +1,45 @@
+// Copyright Sam Xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otelsql
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func TestComposeAttributesGetters(t *testing.T) {
+	methodGetter := func(_ context.Context, method Method, _ string, _ []driver.NamedValue) []attribute.KeyValue {
+		return []attribute.KeyValue{attribute.String("method", string(method))}
+	}
+	queryGetter := func(_ context.Context, _ Method, query string, _ []driver.NamedValue) []attribute.KeyValue {
+		return []attribute.KeyValue{attribute.String("query", query)}
+	}
+	expected := []attribute.KeyValue{
+		attribute.String("method", string(MethodConnQuery)),
+		attribute.String("query", "SELECT 1"),
+	}
+
+	getter := ComposeAttributesGetters(methodGetter, nil, queryGetter)
+	assert.Equal(t, expected, getter(context.Background(), MethodConnQuery, "SELECT 1", nil))
+
+	instrumentGetter := ComposeInstrumentAttributesGetters(methodGetter, queryGetter, nil)
+	assert.Equal(t, expected, instrumentGetter(context.Background(), MethodConnQuery, "SELECT 1", nil))
+
+	assert.Nil(t, ComposeAttributesGetters()(context.Background(), MethodConnQuery, "", nil))
+}
@@ -0,0 +1,92 @@
+// Copyright Sam Xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otelsql
+
+import (
+	"context"
+	"database/sql/driver"
+	"slices"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var dbResponseAffectedRowsKey = attribute.Key("db.response.affected_rows")
+
+// AuditRecord is a data modification statement run through the driver,
+// passed to the AuditRecorder set with WithAuditRecorder.
+type AuditRecord struct {
+	// Method is the method of the call.
+	Method Method
+	// Query is the query of the call, without its arguments.
+	Query string
+	// Err is the error of the call, nil if it succeeded.
+	Err error
+	// Attributes are the attributes of the call: the ones set with
+	// WithAttributes, the method, the operation name, the fingerprint of the
+	// query, the tenant, the ones of WithAttributesGetter, the outcome as
+	// status, and the number of affected rows of Exec calls.
+	Attributes []attribute.KeyValue
+}
+
+// AuditRecorder records the data modification statements run through the
+// driver, see WithAuditRecorder. ctx carries the span of the call.
+type AuditRecorder func(ctx context.Context, record AuditRecord)
+
+// recordAuditLogDeferred passes the call of query to the AuditRecorder of
+// cfg, if it is a data modification statement. ctx carries the span of the
+// call, so the record is correlated with the trace. res is nil for queries,
+// like INSERT ... RETURNING, whose number of affected rows is unknown. Calls
+// failed with driver.ErrSkip are not recorded, as database/sql retries them
+// with a prepared statement.
+func recordAuditLogDeferred(
+	ctx context.Context, cfg config, method Method, query string, args []driver.NamedValue,
+	res *driver.Result, err *error,
+) {
+	if cfg.AuditRecorder == nil || *err == driver.ErrSkip || !isDMLQuery(query) {
+		return
+	}
+
+	attrs := append(slices.Clip(cfg.Attributes), contextAttributes(ctx)...)
+	attrs = append(attrs,
+		queryMethodKey.String(string(method)),
+		dbOperationNameKey.String(operationName(query)),
+		dbQueryFingerprintKey.String(queryFingerprint(query)),
+	)
+	if cfg.TenantGetter != nil {
+		if tenant := cfg.TenantGetter(ctx); tenant != "" {
+			attrs = append(attrs, tenantIDKey.String(tenant))
+		}
+	}
+	if cfg.AttributesGetter != nil {
+		attrs = append(attrs, cfg.AttributesGetter(ctx, method, query, args)...)
+	}
+
+	if *err != nil {
+		attrs = append(attrs, queryStatusKey.String("error"))
+	} else {
+		attrs = append(attrs, queryStatusKey.String("ok"))
+		if res != nil && *res != nil {
+			if n, err := (*res).RowsAffected(); err == nil {
+				attrs = append(attrs, dbResponseAffectedRowsKey.Int64(n))
+			}
+		}
+	}
+	cfg.AuditRecorder(ctx, AuditRecord{
+		Method:     method,
+		Query:      query,
+		Err:        *err,
+		Attributes: attrs,
+	})
+}
@@ -0,0 +1,134 @@
+// Copyright Sam Xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otelsql
+
+import (
+	"context"
+	"database/sql/driver"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// auditRecords keeps the records passed to its recorder.
+type auditRecords struct {
+	mu       sync.Mutex
+	records  []AuditRecord
+	contexts []context.Context
+}
+
+func (r *auditRecords) record(ctx context.Context, record AuditRecord) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.records = append(r.records, record)
+	r.contexts = append(r.contexts, ctx)
+}
+
+func TestAuditLog(t *testing.T) {
+	ctx, _, tracer, _ := prepareTraces(true)
+	recorder := &auditRecords{}
+	cfg := newMockConfig(t, tracer)
+	cfg.AuditRecorder = recorder.record
+	cfg.TenantGetter = tenantFromContext
+	ctx = context.WithValue(ctx, tenantContextKey{}, "acme")
+
+	conn := newConn(mockConnWithResult{newMockConn(false)}, cfg)
+	_, err := conn.ExecContext(ctx, "UPDATE users SET name = ? WHERE id = ?", nil)
+	require.NoError(t, err)
+	// Reads are not audited.
+	_, err = conn.ExecContext(ctx, "SELECT 1", nil)
+	require.NoError(t, err)
+	_, err = newConn(newMockConn(true), cfg).ExecContext(ctx, "DELETE FROM users", nil)
+	require.Error(t, err)
+
+	records := recorder.records
+	require.Len(t, records, 2)
+
+	assert.Equal(t, MethodConnExec, records[0].Method)
+	assert.Equal(t, "UPDATE users SET name = ? WHERE id = ?", records[0].Query)
+	assert.NoError(t, records[0].Err)
+	attrs := attribute.NewSet(records[0].Attributes...)
+	assert.Equal(t, attribute.NewSet(append(cfg.Attributes,
+		queryMethodKey.String(string(MethodConnExec)),
+		dbOperationNameKey.String("UPDATE"),
+		dbQueryFingerprintKey.String(queryFingerprint("UPDATE users SET name = ? WHERE id = ?")),
+		tenantIDKey.String("acme"),
+		queryStatusKey.String("ok"),
+		dbResponseAffectedRowsKey.Int64(3),
+	)...), attrs)
+	// The recorder is called with the context of the call span.
+	assert.True(t, trace.SpanContextFromContext(recorder.contexts[0]).IsValid())
+
+	assert.Error(t, records[1].Err)
+	attrs = attribute.NewSet(records[1].Attributes...)
+	status, _ := attrs.Value(queryStatusKey)
+	assert.Equal(t, "error", status.AsString())
+	assert.False(t, attrs.HasValue(dbResponseAffectedRowsKey))
+}
+
+func TestAuditLog_EmptyQuotes(t *testing.T) {
+	ctx, _, tracer, _ := prepareTraces(true)
+	recorder := &auditRecords{}
+	cfg := newMockConfig(t, tracer)
+	cfg.AuditRecorder = recorder.record
+
+	const query = `UPDATE t SET name = ""`
+	_, err := newConn(newMockConn(false), cfg).ExecContext(ctx, query, nil)
+	require.NoError(t, err)
+
+	require.Len(t, recorder.records, 1)
+	attrs := attribute.NewSet(recorder.records[0].Attributes...)
+	fingerprint, _ := attrs.Value(dbQueryFingerprintKey)
+	assert.Equal(t, "UPDATE t SET name", fingerprint.AsString())
+}
+
+func TestAuditLog_Query(t *testing.T) {
+	ctx, _, tracer, _ := prepareTraces(true)
+	recorder := &auditRecords{}
+	cfg := newMockConfig(t, tracer)
+	cfg.AuditRecorder = recorder.record
+
+	const query = "INSERT INTO users (name) VALUES (?) RETURNING id"
+	conn := newConn(newMockConn(false), cfg)
+	rows, err := conn.QueryContext(ctx, query, nil)
+	require.NoError(t, err)
+	require.NoError(t, rows.Close())
+	// Reads are not audited.
+	rows, err = conn.QueryContext(ctx, "SELECT 1", nil)
+	require.NoError(t, err)
+	require.NoError(t, rows.Close())
+
+	stmt, err := conn.PrepareContext(ctx, query)
+	require.NoError(t, err)
+	rows, err = stmt.(driver.StmtQueryContext).QueryContext(ctx, nil)
+	require.NoError(t, err)
+	require.NoError(t, rows.Close())
+
+	records := recorder.records
+	require.Len(t, records, 2)
+	for i, method := range []Method{MethodConnQuery, MethodStmtQuery} {
+		assert.Equal(t, method, records[i].Method)
+		assert.Equal(t, query, records[i].Query)
+		attrs := attribute.NewSet(records[i].Attributes...)
+		operation, _ := attrs.Value(dbOperationNameKey)
+		assert.Equal(t, "INSERT", operation.AsString())
+		assert.False(t, attrs.HasValue(dbResponseAffectedRowsKey))
+		assert.True(t, trace.SpanContextFromContext(recorder.contexts[i]).IsValid())
+	}
+}
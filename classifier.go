@@ -0,0 +1,75 @@
+// Copyright Sam Xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otelsql
+
+import (
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/XSAM/otelsql/semconvutil"
+)
+
+// ErrorClassifier returns attributes describing err, e.g. a vendor error code
+// or whether err is worth retrying. It returns nil for errors it does not
+// know.
+type ErrorClassifier func(err error) []attribute.KeyValue
+
+var (
+	errorClassifiersMu sync.RWMutex
+	errorClassifiers   = make(map[string][]ErrorClassifier)
+)
+
+// RegisterErrorClassifier registers classifier for the databases whose
+// db.system attribute, set with WithAttributes or WithDBSystem, is dbSystem.
+// An empty dbSystem registers classifier for all the databases.
+//
+// The attributes returned by the classifiers are added to the spans and the
+// db.sql.latency measurements of the failed calls, so that driver-specific
+// packages and applications can enrich errors without changes to otelsql.
+// Classifiers are called on each failed call and must be safe for concurrent
+// use.
+func RegisterErrorClassifier(dbSystem string, classifier ErrorClassifier) {
+	errorClassifiersMu.Lock()
+	defer errorClassifiersMu.Unlock()
+	errorClassifiers[dbSystem] = append(errorClassifiers[dbSystem], classifier)
+}
+
+// classifyError returns the attributes describing err: its db.error.category,
+// its error.type under the stable semantic conventions, and the attributes
+// returned by the classifiers registered for the db.system of cfg.
+func classifyError(cfg config, err error) []attribute.KeyValue {
+	if err == nil {
+		return nil
+	}
+
+	var attrs []attribute.KeyValue
+	if category := errorCategory(err); category != "" {
+		attrs = append(attrs, dbErrorCategoryKey.String(category))
+	}
+	attrs = append(attrs, semconvutil.ErrorTypeAttributes(err, cfg.semconvStability)...)
+
+	errorClassifiersMu.RLock()
+	defer errorClassifiersMu.RUnlock()
+	for _, classifier := range errorClassifiers[""] {
+		attrs = append(attrs, classifier(err)...)
+	}
+	if cfg.dbSystem != "" {
+		for _, classifier := range errorClassifiers[cfg.dbSystem] {
+			attrs = append(attrs, classifier(err)...)
+		}
+	}
+	return attrs
+}
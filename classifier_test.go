@@ -0,0 +1,84 @@
+// Copyright Sam Xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !otelsql_noop
+
+package otelsql
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/XSAM/otelsql/semconvutil"
+)
+
+func TestRegisterErrorClassifier(t *testing.T) {
+	t.Cleanup(func() {
+		errorClassifiersMu.Lock()
+		defer errorClassifiersMu.Unlock()
+		errorClassifiers = make(map[string][]ErrorClassifier)
+	})
+
+	errDuplicate := errors.New("duplicate")
+	RegisterErrorClassifier("mysql", func(err error) []attribute.KeyValue {
+		if errors.Is(err, errDuplicate) {
+			return []attribute.KeyValue{attribute.String("db.error.code", "1062")}
+		}
+		return nil
+	})
+	RegisterErrorClassifier("", func(error) []attribute.KeyValue {
+		return []attribute.KeyValue{attribute.Bool("classified", true)}
+	})
+
+	mysqlCfg := newConfig(WithDBSystem("mysql"))
+	postgresCfg := newConfig(WithDBSystem("postgresql"))
+
+	assert.Nil(t, classifyError(mysqlCfg, nil))
+	assert.Equal(t, []attribute.KeyValue{
+		attribute.Bool("classified", true),
+		attribute.String("db.error.code", "1062"),
+	}, classifyError(mysqlCfg, errDuplicate))
+	assert.Equal(t, []attribute.KeyValue{
+		attribute.Bool("classified", true),
+	}, classifyError(postgresCfg, errDuplicate))
+	assert.Equal(t, []attribute.KeyValue{
+		dbErrorCategoryKey.String(errorCategoryDeadlock),
+		attribute.Bool("classified", true),
+	}, classifyError(postgresCfg, mockSQLStateError("40P01")))
+
+	// The attributes are added to the spans of failed calls.
+	_, sr, tracer, _ := prepareTraces(true)
+	mysqlCfg.Tracer = tracer
+	_, err := newConn(newMockConn(true), mysqlCfg).ExecContext(context.Background(), "INSERT", nil)
+	assert.Error(t, err)
+	spanList := sr.Ended()
+	if assert.Len(t, spanList, 1) {
+		assert.Contains(t, spanList[0].Attributes(), attribute.Bool("classified", true))
+	}
+}
+
+func TestClassifyErrorType(t *testing.T) {
+	cfg := newConfig()
+	assert.Nil(t, classifyError(cfg, errors.New("failed")))
+
+	cfg.semconvStability = semconvutil.StabilityStable
+	assert.Equal(t,
+		semconvutil.ErrorTypeAttributes(errors.New("failed"), semconvutil.StabilityStable),
+		classifyError(cfg, errors.New("failed")),
+	)
+}
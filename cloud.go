@@ -0,0 +1,126 @@
+// Copyright Sam Xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otelsql
+
+import (
+	"regexp"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.18.0"
+)
+
+var (
+	awsRDSClusterIDKey       = attribute.Key("aws.rds.cluster.id")
+	awsRDSInstanceIDKey      = attribute.Key("aws.rds.instance.id")
+	gcpCloudSQLInstanceIDKey = attribute.Key("gcp.cloud_sql.instance.id")
+	azureSQLServerNameKey    = attribute.Key("azure.sql.server.name")
+)
+
+// gcpCloudSQLConnectionNameRegexp matches Cloud SQL instance connection names
+// of the form project:region:instance. Domain-scoped projects contain an extra
+// colon, e.g. example.com:project:region:instance.
+var gcpCloudSQLConnectionNameRegexp = regexp.MustCompile(`^((?:[a-z0-9.-]+:)?[a-z][a-z0-9-]*):([a-z]+-[a-z]+[0-9]+):([a-z0-9][a-z0-9-]*)$`)
+
+// cloudAttributes returns cloud attributes for DSNs that point to a managed
+// database service. It returns nil if the endpoint is not recognized.
+func cloudAttributes(info dsnInfo) []attribute.KeyValue {
+	host := strings.ToLower(strings.TrimSuffix(info.host, "."))
+	if attrs := awsRDSAttributes(host); attrs != nil {
+		return attrs
+	}
+	if attrs := gcpCloudSQLAttributes(host); attrs != nil {
+		return attrs
+	}
+	return azureSQLAttributes(host)
+}
+
+// awsRDSAttributes parses RDS and Aurora endpoints, which have the form
+// <identifier>.<endpoint-type-and-hash>.<region>.rds.amazonaws.com, e.g.
+//
+//	mydb.c9akciq32.us-east-1.rds.amazonaws.com
+//	mycluster.cluster-c9akciq32.us-east-1.rds.amazonaws.com
+//	mycluster.cluster-ro-c9akciq32.us-east-1.rds.amazonaws.com
+func awsRDSAttributes(host string) []attribute.KeyValue {
+	var prefix string
+	for _, suffix := range []string{".rds.amazonaws.com", ".rds.amazonaws.com.cn"} {
+		if p, ok := strings.CutSuffix(host, suffix); ok {
+			prefix = p
+			break
+		}
+	}
+
+	labels := strings.Split(prefix, ".")
+	if len(labels) != 3 {
+		return nil
+	}
+	identifier, endpointType, region := labels[0], labels[1], labels[2]
+
+	attrs := []attribute.KeyValue{
+		semconv.CloudProviderAWS,
+		semconv.CloudRegion(region),
+	}
+	switch {
+	case strings.HasPrefix(endpointType, "cluster-custom-"), strings.HasPrefix(endpointType, "proxy-"):
+		// Custom cluster endpoints and RDS Proxy endpoints are named by the
+		// user and do not identify a cluster or an instance.
+	case strings.HasPrefix(endpointType, "cluster-"):
+		attrs = append(attrs, awsRDSClusterIDKey.String(identifier))
+	default:
+		attrs = append(attrs, awsRDSInstanceIDKey.String(identifier))
+	}
+	return attrs
+}
+
+// gcpCloudSQLAttributes parses Cloud SQL instance connection names, which are
+// used as the address by the Cloud SQL Go connector and its dialers, e.g.
+//
+//	user:password@cloudsql-mysql(my-project:us-central1:my-instance)/dbname
+//	host=my-project:us-central1:my-instance user=user dbname=dbname
+func gcpCloudSQLAttributes(host string) []attribute.KeyValue {
+	m := gcpCloudSQLConnectionNameRegexp.FindStringSubmatch(host)
+	if m == nil {
+		return nil
+	}
+
+	return []attribute.KeyValue{
+		semconv.CloudProviderGCP,
+		semconv.CloudRegion(m[2]),
+		semconv.CloudAccountID(m[1]),
+		gcpCloudSQLInstanceIDKey.String(m[3]),
+	}
+}
+
+// azureSQLAttributes parses Azure SQL Database and Managed Instance server
+// names in the public and sovereign clouds, e.g.
+//
+//	myserver.database.windows.net
+//	myinstance.0123456789ab.database.windows.net
+func azureSQLAttributes(host string) []attribute.KeyValue {
+	for _, suffix := range []string{
+		".database.windows.net",
+		".database.chinacloudapi.cn",
+		".database.usgovcloudapi.net",
+	} {
+		if prefix, ok := strings.CutSuffix(host, suffix); ok && prefix != "" {
+			server, _, _ := strings.Cut(prefix, ".")
+			return []attribute.KeyValue{
+				semconv.CloudProviderAzure,
+				azureSQLServerNameKey.String(server),
+			}
+		}
+	}
+	return nil
+}
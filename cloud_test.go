@@ -0,0 +1,184 @@
+// Copyright Sam Xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otelsql
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.18.0"
+)
+
+func TestAWSRDSAttributes(t *testing.T) {
+	testCases := []struct {
+		name     string
+		host     string
+		expected []attribute.KeyValue
+	}{
+		{
+			name: "not rds",
+			host: "localhost",
+		},
+		{
+			name: "instance",
+			host: "mydb.c9akciq32.us-east-1.rds.amazonaws.com",
+			expected: []attribute.KeyValue{
+				semconv.CloudProviderAWS,
+				semconv.CloudRegion("us-east-1"),
+				awsRDSInstanceIDKey.String("mydb"),
+			},
+		},
+		{
+			name: "aurora cluster writer",
+			host: "mycluster.cluster-c9akciq32.eu-west-1.rds.amazonaws.com",
+			expected: []attribute.KeyValue{
+				semconv.CloudProviderAWS,
+				semconv.CloudRegion("eu-west-1"),
+				awsRDSClusterIDKey.String("mycluster"),
+			},
+		},
+		{
+			name: "aurora cluster reader",
+			host: "mycluster.cluster-ro-c9akciq32.eu-west-1.rds.amazonaws.com",
+			expected: []attribute.KeyValue{
+				semconv.CloudProviderAWS,
+				semconv.CloudRegion("eu-west-1"),
+				awsRDSClusterIDKey.String("mycluster"),
+			},
+		},
+		{
+			name: "aurora custom endpoint",
+			host: "analytics.cluster-custom-c9akciq32.eu-west-1.rds.amazonaws.com",
+			expected: []attribute.KeyValue{
+				semconv.CloudProviderAWS,
+				semconv.CloudRegion("eu-west-1"),
+			},
+		},
+		{
+			name: "rds proxy",
+			host: "myproxy.proxy-c9akciq32.us-east-1.rds.amazonaws.com",
+			expected: []attribute.KeyValue{
+				semconv.CloudProviderAWS,
+				semconv.CloudRegion("us-east-1"),
+			},
+		},
+		{
+			name: "china region",
+			host: "mydb.c9akciq32.cn-north-1.rds.amazonaws.com.cn",
+			expected: []attribute.KeyValue{
+				semconv.CloudProviderAWS,
+				semconv.CloudRegion("cn-north-1"),
+				awsRDSInstanceIDKey.String("mydb"),
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, awsRDSAttributes(tc.host))
+		})
+	}
+}
+
+func TestGCPCloudSQLAttributes(t *testing.T) {
+	testCases := []struct {
+		name     string
+		host     string
+		expected []attribute.KeyValue
+	}{
+		{
+			name: "not cloud sql",
+			host: "localhost",
+		},
+		{
+			name: "ipv6",
+			host: "fe80::1",
+		},
+		{
+			name: "connection name",
+			host: "my-project:us-central1:my-instance",
+			expected: []attribute.KeyValue{
+				semconv.CloudProviderGCP,
+				semconv.CloudRegion("us-central1"),
+				semconv.CloudAccountID("my-project"),
+				gcpCloudSQLInstanceIDKey.String("my-instance"),
+			},
+		},
+		{
+			name: "domain-scoped project",
+			host: "example.com:my-project:europe-west3:my-instance",
+			expected: []attribute.KeyValue{
+				semconv.CloudProviderGCP,
+				semconv.CloudRegion("europe-west3"),
+				semconv.CloudAccountID("example.com:my-project"),
+				gcpCloudSQLInstanceIDKey.String("my-instance"),
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, gcpCloudSQLAttributes(tc.host))
+		})
+	}
+}
+
+func TestAzureSQLAttributes(t *testing.T) {
+	testCases := []struct {
+		name     string
+		host     string
+		expected []attribute.KeyValue
+	}{
+		{
+			name: "not azure sql",
+			host: "localhost",
+		},
+		{
+			name: "bare suffix",
+			host: "database.windows.net",
+		},
+		{
+			name: "server",
+			host: "myserver.database.windows.net",
+			expected: []attribute.KeyValue{
+				semconv.CloudProviderAzure,
+				azureSQLServerNameKey.String("myserver"),
+			},
+		},
+		{
+			name: "managed instance",
+			host: "myinstance.0123456789ab.database.windows.net",
+			expected: []attribute.KeyValue{
+				semconv.CloudProviderAzure,
+				azureSQLServerNameKey.String("myinstance"),
+			},
+		},
+		{
+			name: "china cloud",
+			host: "myserver.database.chinacloudapi.cn",
+			expected: []attribute.KeyValue{
+				semconv.CloudProviderAzure,
+				azureSQLServerNameKey.String("myserver"),
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, azureSQLAttributes(tc.host))
+		})
+	}
+}
@@ -16,15 +16,27 @@ package otelsql
 
 import (
 	"context"
-	"fmt"
 	"net/url"
+	"slices"
 	"strings"
+	"unicode"
 
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/propagation"
 )
 
-type commentCarrier []string
+// commentField is a field of the comment, with its key and value already
+// URL-encoded.
+type commentField struct {
+	key, value string
+}
+
+// len returns the length of the marshaled field, key='value'.
+func (f commentField) len() int {
+	return len(f.key) + len(f.value) + 3
+}
+
+type commentCarrier []commentField
 
 var _ propagation.TextMapCarrier = (*commentCarrier)(nil)
 
@@ -32,17 +44,97 @@ func (c *commentCarrier) Keys() []string { return nil }
 
 func (c *commentCarrier) Get(string) string { return "" }
 
+// Set adds a field to the comment. Keys and values are URL-encoded as required
+// by the sqlcommenter specification, which also escapes the characters that
+// could terminate the comment or the quoted value, like "*/" and "'".
 func (c *commentCarrier) Set(key, value string) {
-	*c = append(*c, fmt.Sprintf("%s='%s'", url.QueryEscape(key), url.QueryEscape(value)))
+	*c = append(*c, commentField{key: url.QueryEscape(key), value: url.QueryEscape(value)})
+}
+
+// len returns the length of the marshaled comment content.
+func (c commentCarrier) len() int {
+	length := len(c) - 1
+	for _, field := range c {
+		length += field.len()
+	}
+	return length
+}
+
+// appendQuery returns query followed by the comment. The fields are sorted by
+// key as required by the sqlcommenter specification, and the result is built
+// in a single allocation.
+func (c commentCarrier) appendQuery(query string) string {
+	slices.SortFunc(c, func(a, b commentField) int {
+		return strings.Compare(a.key, b.key)
+	})
+
+	var b strings.Builder
+	b.Grow(len(query) + len(" /*") + c.len() + len("*/"))
+	b.WriteString(query)
+	b.WriteString(" /*")
+	for i, field := range c {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(field.key)
+		b.WriteString("='")
+		b.WriteString(field.value)
+		b.WriteByte('\'')
+	}
+	b.WriteString("*/")
+	return b.String()
 }
 
-func (c *commentCarrier) Marshal() string {
-	return strings.Join(*c, ",")
+// truncate drops fields until the marshaled comment is not longer than
+// maxLength. Fields are dropped from the last one, and traceparent is dropped
+// only if no other field remains. It reports whether any field was dropped.
+func (c *commentCarrier) truncate(maxLength int) bool {
+	length := c.len()
+	if length <= maxLength {
+		return false
+	}
+
+	fields := *c
+	for i := len(fields) - 1; i >= 0 && length > maxLength; i-- {
+		if fields[i].key == "traceparent" {
+			continue
+		}
+		length -= fields[i].len() + 1
+		fields = append(fields[:i], fields[i+1:]...)
+	}
+	if length > maxLength {
+		fields = fields[:0]
+	}
+	*c = fields
+	return true
+}
+
+// keyFilterCarrier drops the fields of a propagator that are not allowed.
+type keyFilterCarrier struct {
+	propagation.TextMapCarrier
+	allowKey func(key string) bool
+}
+
+func (c keyFilterCarrier) Set(key, value string) {
+	if c.allowKey(key) {
+		c.TextMapCarrier.Set(key, value)
+	}
 }
 
 type commenter struct {
 	enabled    bool
 	propagator propagation.TextMapPropagator
+	// allowKey, if set, reports whether a propagator field can be injected.
+	allowKey func(key string) bool
+	// maxLength, if positive, is the maximum length of the comment content.
+	maxLength int
+	// onTruncate, if set, is invoked when fields are dropped due to maxLength.
+	onTruncate func(ctx context.Context)
+	// onComment, if set, is invoked with the number of bytes added to each
+	// commented query.
+	onComment func(ctx context.Context, addedBytes int)
+	// dmlOnly, if set, restricts comments to data modification statements.
+	dmlOnly bool
 }
 
 func newCommenter(enabled bool) *commenter {
@@ -53,15 +145,123 @@ func newCommenter(enabled bool) *commenter {
 }
 
 func (c *commenter) withComment(ctx context.Context, query string) string {
-	if !c.enabled {
+	if !c.enabled || sqlCommentDisabled(ctx) || c.dmlOnly && !isDMLQuery(query) {
 		return query
 	}
 
 	var cc commentCarrier
-	c.propagator.Inject(ctx, &cc)
+	if c.allowKey != nil {
+		c.propagator.Inject(ctx, keyFilterCarrier{TextMapCarrier: &cc, allowKey: c.allowKey})
+	} else {
+		c.propagator.Inject(ctx, &cc)
+	}
+
+	if c.maxLength > 0 && cc.truncate(c.maxLength) && c.onTruncate != nil {
+		c.onTruncate(ctx)
+	}
 
 	if len(cc) == 0 {
 		return query
 	}
-	return fmt.Sprintf("%s /*%s*/", query, cc.Marshal())
+	commented := cc.appendQuery(query)
+	if c.onComment != nil {
+		c.onComment(ctx, len(commented)-len(query))
+	}
+	return commented
+}
+
+// driverQuery returns query as passed to the driver for a call of method:
+// with the comment of the SQL commenter and rewritten by the query
+// transformer of cfg, in the order set by QueryTransformerAfterCommenter.
+func driverQuery(ctx context.Context, cfg config, method Method, query string) string {
+	if cfg.QueryTransformer == nil {
+		return cfg.SQLCommenter.withComment(ctx, query)
+	}
+	if cfg.QueryTransformerAfterCommenter {
+		return cfg.QueryTransformer(ctx, method, cfg.SQLCommenter.withComment(ctx, query))
+	}
+	return cfg.SQLCommenter.withComment(ctx, cfg.QueryTransformer(ctx, method, query))
+}
+
+// newCommenterKeyFilter returns a func reporting whether a propagator field is
+// allowed by the include and exclude lists. Keys are compared
+// case-insensitively. An empty include list allows all keys that are not
+// excluded. It returns nil if both lists are empty.
+func newCommenterKeyFilter(include, exclude []string) func(key string) bool {
+	if len(include) == 0 && len(exclude) == 0 {
+		return nil
+	}
+
+	toSet := func(keys []string) map[string]struct{} {
+		set := make(map[string]struct{}, len(keys))
+		for _, k := range keys {
+			set[strings.ToLower(k)] = struct{}{}
+		}
+		return set
+	}
+	includeSet, excludeSet := toSet(include), toSet(exclude)
+
+	return func(key string) bool {
+		key = strings.ToLower(key)
+		if _, ok := excludeSet[key]; ok {
+			return false
+		}
+		if len(includeSet) == 0 {
+			return true
+		}
+		_, ok := includeSet[key]
+		return ok
+	}
+}
+
+// dmlKeywords are the keywords starting data modification statements.
+var dmlKeywords = map[string]bool{
+	"INSERT": true, "UPDATE": true, "DELETE": true,
+	"MERGE": true, "UPSERT": true, "REPLACE": true,
+}
+
+// isDMLQuery reports whether query is an INSERT, UPDATE, DELETE, or another
+// data modification statement. Statements starting with a WITH clause are
+// DML if they contain a data modification keyword.
+func isDMLQuery(query string) bool {
+	keyword := strings.ToUpper(firstSQLWord(query))
+	if keyword == "WITH" {
+		for _, token := range sqlTokens(query) {
+			if dmlKeywords[strings.ToUpper(token)] {
+				return true
+			}
+		}
+		return false
+	}
+	return dmlKeywords[keyword]
+}
+
+// firstSQLWord returns the first word of query, skipping leading whitespace
+// and comments, without tokenizing the rest of the query.
+func firstSQLWord(query string) string {
+	for {
+		query = strings.TrimLeftFunc(query, unicode.IsSpace)
+		switch {
+		case strings.HasPrefix(query, "--"):
+			i := strings.IndexByte(query, '\n')
+			if i < 0 {
+				return ""
+			}
+			query = query[i:]
+		case strings.HasPrefix(query, "/*"):
+			i := strings.Index(query, "*/")
+			if i < 0 {
+				return ""
+			}
+			query = query[i+2:]
+		default:
+			end := strings.IndexFunc(query, func(r rune) bool {
+				return !unicode.IsLetter(r)
+			})
+			if end < 0 {
+				return query
+			}
+			return query[:end]
+		}
+	}
 }
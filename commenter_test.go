@@ -48,10 +48,12 @@ func TestCommenter_WithComment(t *testing.T) {
 	ctx = baggage.ContextWithBaggage(ctx, b)
 
 	testCases := []struct {
-		name     string
-		enabled  bool
-		ctx      context.Context
-		expected string
+		name        string
+		enabled     bool
+		includeKeys []string
+		excludeKeys []string
+		ctx         context.Context
+		expected    string
 	}{
 		{
 			name:     "empty context",
@@ -69,7 +71,29 @@ func TestCommenter_WithComment(t *testing.T) {
 			name:     "context",
 			enabled:  true,
 			ctx:      ctx,
-			expected: query + " /*tracestate='rojo%3D00f067aa0ba902b7%2Ccongo%3Dt61rcWkgMzE',traceparent='00-a3d3b88cf7994e554c1afbdceec1620b-683ec6a9a3a265fb-01',baggage='foo%3Dbar'*/",
+			expected: query + " /*baggage='foo%3Dbar',traceparent='00-a3d3b88cf7994e554c1afbdceec1620b-683ec6a9a3a265fb-01',tracestate='rojo%3D00f067aa0ba902b7%2Ccongo%3Dt61rcWkgMzE'*/",
+		},
+		{
+			name:        "context with include keys",
+			enabled:     true,
+			includeKeys: []string{"TraceParent"},
+			ctx:         ctx,
+			expected:    query + " /*traceparent='00-a3d3b88cf7994e554c1afbdceec1620b-683ec6a9a3a265fb-01'*/",
+		},
+		{
+			name:        "context with exclude keys",
+			enabled:     true,
+			excludeKeys: []string{"baggage"},
+			ctx:         ctx,
+			expected:    query + " /*traceparent='00-a3d3b88cf7994e554c1afbdceec1620b-683ec6a9a3a265fb-01',tracestate='rojo%3D00f067aa0ba902b7%2Ccongo%3Dt61rcWkgMzE'*/",
+		},
+		{
+			name:        "context with all keys excluded",
+			enabled:     true,
+			includeKeys: []string{"baggage"},
+			excludeKeys: []string{"baggage"},
+			ctx:         ctx,
+			expected:    query,
 		},
 	}
 
@@ -77,9 +101,153 @@ func TestCommenter_WithComment(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			c := newCommenter(tc.enabled)
 			c.propagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+			c.allowKey = newCommenterKeyFilter(tc.includeKeys, tc.excludeKeys)
 
 			result := c.withComment(tc.ctx, query)
 			assert.Equal(t, tc.expected, result)
 		})
 	}
 }
+
+func TestCommenter_WithCommentMaxLength(t *testing.T) {
+	traceID, err := trace.TraceIDFromHex("a3d3b88cf7994e554c1afbdceec1620b")
+	require.NoError(t, err)
+	spanID, err := trace.SpanIDFromHex("683ec6a9a3a265fb")
+	require.NoError(t, err)
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: 0x1,
+	}))
+	m1, err := baggage.NewMemberRaw("user", "*/ DROP TABLE users; '")
+	require.NoError(t, err)
+	b, err := baggage.New(m1)
+	require.NoError(t, err)
+	ctx = baggage.ContextWithBaggage(ctx, b)
+
+	traceparent := "traceparent='00-a3d3b88cf7994e554c1afbdceec1620b-683ec6a9a3a265fb-01'"
+	baggageField := "baggage='user%3D%2A%2F%2520DROP%2520TABLE%2520users%253B%2520%27'"
+
+	testCases := []struct {
+		name              string
+		maxLength         int
+		expected          string
+		expectedTruncated bool
+	}{
+		{
+			name:     "no limit",
+			expected: "foo /*" + baggageField + "," + traceparent + "*/",
+		},
+		{
+			name:      "within limit",
+			maxLength: len(traceparent) + len(baggageField) + 1,
+			expected:  "foo /*" + baggageField + "," + traceparent + "*/",
+		},
+		{
+			name:              "drop baggage",
+			maxLength:         len(traceparent) + len(baggageField),
+			expected:          "foo /*" + traceparent + "*/",
+			expectedTruncated: true,
+		},
+		{
+			name:              "drop all",
+			maxLength:         len(traceparent) - 1,
+			expected:          "foo",
+			expectedTruncated: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var truncated bool
+			c := newCommenter(true)
+			c.propagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+			c.maxLength = tc.maxLength
+			c.onTruncate = func(context.Context) { truncated = true }
+
+			assert.Equal(t, tc.expected, c.withComment(ctx, "foo"))
+			assert.Equal(t, tc.expectedTruncated, truncated)
+		})
+	}
+}
+
+func TestCommenter_OnComment(t *testing.T) {
+	ctx := newTestSpanContext(t)
+	var added []int
+	c := &commenter{enabled: true, propagator: propagation.TraceContext{}}
+	c.onComment = func(_ context.Context, n int) {
+		added = append(added, n)
+	}
+	comment := " /*traceparent='00-a3d3b88cf7994e554c1afbdceec1620b-683ec6a9a3a265fb-01'*/"
+
+	c.withComment(ctx, "SELECT 1")
+	// Nothing is added without a span context.
+	c.withComment(context.Background(), "SELECT 1")
+	assert.Equal(t, []int{len(comment)}, added)
+}
+
+func BenchmarkCommenter_WithComment(b *testing.B) {
+	ctx := newTestSpanContext(b)
+	c := newCommenter(true)
+	c.propagator = propagation.TraceContext{}
+	query := "SELECT * FROM orders WHERE id = $1"
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.withComment(ctx, query)
+	}
+}
+
+func TestCommenter_WithCommentDMLOnly(t *testing.T) {
+	ctx := newTestSpanContext(t)
+	c := &commenter{enabled: true, dmlOnly: true, propagator: propagation.TraceContext{}}
+	comment := " /*traceparent='00-a3d3b88cf7994e554c1afbdceec1620b-683ec6a9a3a265fb-01'*/"
+
+	testCases := []struct {
+		query     string
+		commented bool
+	}{
+		{query: "INSERT INTO t VALUES (1)", commented: true},
+		{query: "  update t SET a = 1", commented: true},
+		{query: "/* app */ DELETE FROM t", commented: true},
+		{query: "-- app\nMERGE INTO t USING s ON t.id = s.id", commented: true},
+		{query: "WITH s AS (SELECT 1) UPDATE t SET a = 1", commented: true},
+		{query: "WITH s AS (SELECT 1) SELECT * FROM s"},
+		{query: "SELECT * FROM t"},
+		{query: "select 'INSERT'"},
+		{query: "/* unterminated"},
+		{query: ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.query, func(t *testing.T) {
+			expected := tc.query
+			if tc.commented {
+				expected += comment
+			}
+			assert.Equal(t, expected, c.withComment(ctx, tc.query))
+		})
+	}
+}
+
+func TestDriverQuery(t *testing.T) {
+	ctx := newTestSpanContext(t)
+	comment := " /*traceparent='00-a3d3b88cf7994e554c1afbdceec1620b-683ec6a9a3a265fb-01'*/"
+	limit := func(_ context.Context, method Method, query string) string {
+		if method != MethodConnQuery {
+			return query
+		}
+		return query + " LIMIT 10"
+	}
+
+	cfg := config{SQLCommenter: &commenter{enabled: true, propagator: propagation.TraceContext{}}}
+	assert.Equal(t, "SELECT 1"+comment, driverQuery(ctx, cfg, MethodConnQuery, "SELECT 1"))
+
+	cfg.QueryTransformer = limit
+	assert.Equal(t, "SELECT 1 LIMIT 10"+comment, driverQuery(ctx, cfg, MethodConnQuery, "SELECT 1"))
+	assert.Equal(t, "DELETE FROM t"+comment, driverQuery(ctx, cfg, MethodConnExec, "DELETE FROM t"))
+
+	cfg.QueryTransformerAfterCommenter = true
+	assert.Equal(t, "SELECT 1"+comment+" LIMIT 10", driverQuery(ctx, cfg, MethodConnQuery, "SELECT 1"))
+}
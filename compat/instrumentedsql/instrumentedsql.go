@@ -0,0 +1,213 @@
+// Copyright Sam Xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package instrumentedsql maps the option surface of the
+// github.com/luna-duclos/instrumentedsql wrapper onto otelsql, so that code
+// instrumented with instrumentedsql can move to otelsql by changing its
+// import path:
+//
+//	sql.Register("instrumented-postgres", instrumentedsql.WrapDriver(&pq.Driver{}))
+//
+// Spans are created with the global TracerProvider instead of the Tracer of
+// instrumentedsql, use WithOtelsqlOptions to pass further otelsql options,
+// like otelsql.WithTracerProvider. Logging is not supported.
+package instrumentedsql // import "github.com/XSAM/otelsql/compat/instrumentedsql"
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/XSAM/otelsql"
+	"github.com/XSAM/otelsql/semconvutil"
+)
+
+// The operations of instrumentedsql, which can be excluded with
+// WithOpsExcluded. The ones without a matching otelsql span, like
+// OpSQLStmtClose and OpSQLResRowsAffected, are accepted but ignored.
+const (
+	OpSQLPrepare          = "sql-prepare"
+	OpSQLConnExec         = "sql-conn-exec"
+	OpSQLConnQuery        = "sql-conn-query"
+	OpSQLStmtExec         = "sql-stmt-exec"
+	OpSQLStmtQuery        = "sql-stmt-query"
+	OpSQLStmtClose        = "sql-stmt-close"
+	OpSQLTxBegin          = "sql-tx-begin"
+	OpSQLTxCommit         = "sql-tx-commit"
+	OpSQLTxRollback       = "sql-tx-rollback"
+	OpSQLResLastInsertID  = "sql-res-lastInsertId"
+	OpSQLResRowsAffected  = "sql-res-rowsAffected"
+	OpSQLRowsNext         = "sql-rows-next"
+	OpSQLPing             = "sql-ping"
+	OpSQLDummyPing        = "sql-dummy-ping"
+	OpSQLConnectorConnect = "sql-connector-connect"
+)
+
+// opMethods are the otelsql methods of the instrumentedsql operations.
+var opMethods = map[string]otelsql.Method{
+	OpSQLPrepare:          otelsql.MethodConnPrepare,
+	OpSQLConnExec:         otelsql.MethodConnExec,
+	OpSQLConnQuery:        otelsql.MethodConnQuery,
+	OpSQLStmtExec:         otelsql.MethodStmtExec,
+	OpSQLStmtQuery:        otelsql.MethodStmtQuery,
+	OpSQLTxBegin:          otelsql.MethodConnBeginTx,
+	OpSQLTxCommit:         otelsql.MethodTxCommit,
+	OpSQLTxRollback:       otelsql.MethodTxRollback,
+	OpSQLPing:             otelsql.MethodConnPing,
+	OpSQLConnectorConnect: otelsql.MethodConnectorConnect,
+}
+
+type opts struct {
+	includeArgs    bool
+	opsExcluded    map[string]struct{}
+	queryFormatter func(query string) string
+	otelsqlOptions []otelsql.Option
+}
+
+// Opt is a functional option type for the wrapped driver.
+type Opt func(*opts)
+
+// WithOmitArgs will make it so that query arguments are omitted from spans.
+// It is the default.
+func WithOmitArgs() Opt {
+	return func(o *opts) {
+		o.includeArgs = false
+	}
+}
+
+// WithIncludeArgs will make it so that query arguments are included in
+// spans, as the args attribute.
+func WithIncludeArgs() Opt {
+	return func(o *opts) {
+		o.includeArgs = true
+	}
+}
+
+// WithOpsExcluded excludes some of the operations from tracing.
+func WithOpsExcluded(ops ...string) Opt {
+	return func(o *opts) {
+		for _, op := range ops {
+			o.opsExcluded[op] = struct{}{}
+		}
+	}
+}
+
+// WithQueryFormatter sets a func formatting the queries recorded in spans,
+// e.g. to collapse whitespace or remove literals. The queries passed to the
+// driver are unchanged.
+func WithQueryFormatter(formatter func(query string) string) Opt {
+	return func(o *opts) {
+		o.queryFormatter = formatter
+	}
+}
+
+// WithOtelsqlOptions appends otelsql options, for the features
+// instrumentedsql does not have.
+func WithOtelsqlOptions(options ...otelsql.Option) Opt {
+	return func(o *opts) {
+		o.otelsqlOptions = append(o.otelsqlOptions, options...)
+	}
+}
+
+// WrapDriver will wrap the passed SQL driver and return a new sql driver that
+// uses it and also traces the operations.
+func WrapDriver(d driver.Driver, options ...Opt) driver.Driver {
+	return otelsql.WrapDriver(d, Options(options...)...)
+}
+
+// Options returns the otelsql options matching the instrumentedsql options.
+func Options(options ...Opt) []otelsql.Option {
+	o := opts{opsExcluded: make(map[string]struct{})}
+	for _, option := range options {
+		option(&o)
+	}
+
+	excluded := func(op string) bool {
+		_, ok := o.opsExcluded[op]
+		return ok
+	}
+
+	spanOptions := otelsql.SpanOptions{
+		Ping:     !excluded(OpSQLPing),
+		RowsNext: !excluded(OpSQLRowsNext),
+		// instrumentedsql does not trace the consumption of rows.
+		OmitRows:     true,
+		DisableQuery: o.queryFormatter != nil,
+	}
+	var methods []otelsql.Method
+	for op := range o.opsExcluded {
+		if method, ok := opMethods[op]; ok {
+			methods = append(methods, method)
+		}
+	}
+	if len(methods) > 0 {
+		spanOptions.SpanFilter = otelsql.FilterNot(otelsql.FilterMethods(methods...))
+	}
+
+	var getters []otelsql.AttributesGetter
+	if o.queryFormatter != nil {
+		stability := semconvutil.StabilityFromEnv()
+		formatter := o.queryFormatter
+		getters = append(getters, func(
+			_ context.Context, _ otelsql.Method, query string, _ []driver.NamedValue,
+		) []attribute.KeyValue {
+			if query == "" {
+				return nil
+			}
+			return semconvutil.DBQueryTextAttributes(formatter(query), stability)
+		})
+	}
+	if o.includeArgs {
+		getters = append(getters, argsAttributes)
+	}
+
+	opts := []otelsql.Option{otelsql.WithSpanOptions(spanOptions)}
+	if len(getters) > 0 {
+		opts = append(opts, otelsql.WithAttributesGetter(otelsql.ComposeAttributesGetters(getters...)))
+	}
+	return append(opts, o.otelsqlOptions...)
+}
+
+// argsAttributes records the arguments of the call like instrumentedsql,
+// e.g. {$1: 42, $2: 'name'}.
+func argsAttributes(_ context.Context, _ otelsql.Method, _ string, args []driver.NamedValue) []attribute.KeyValue {
+	if len(args) == 0 {
+		return nil
+	}
+
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, arg := range args {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		if arg.Name != "" {
+			b.WriteString(arg.Name)
+		} else {
+			b.WriteString("$" + strconv.Itoa(arg.Ordinal))
+		}
+		b.WriteString(": ")
+		if s, ok := arg.Value.(string); ok {
+			b.WriteString("'" + s + "'")
+		} else {
+			fmt.Fprint(&b, arg.Value)
+		}
+	}
+	b.WriteByte('}')
+	return []attribute.KeyValue{attribute.String("args", b.String())}
+}
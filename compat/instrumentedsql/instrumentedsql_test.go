@@ -0,0 +1,113 @@
+// Copyright Sam Xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !otelsql_noop
+
+package instrumentedsql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/XSAM/otelsql"
+)
+
+type mockDriver struct{}
+
+func (mockDriver) Open(string) (driver.Conn, error) {
+	return mockConn{}, nil
+}
+
+type mockConn struct{}
+
+func (mockConn) Prepare(string) (driver.Stmt, error) {
+	return nil, driver.ErrSkip
+}
+
+func (mockConn) Close() error {
+	return nil
+}
+
+func (mockConn) Begin() (driver.Tx, error) {
+	return nil, driver.ErrSkip
+}
+
+func (mockConn) ExecContext(context.Context, string, []driver.NamedValue) (driver.Result, error) {
+	return driver.RowsAffected(1), nil
+}
+
+func (mockConn) Ping(context.Context) error {
+	return nil
+}
+
+func exec(t *testing.T, options ...Opt) map[string]attribute.Set {
+	t.Helper()
+
+	sr := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+	options = append(options, WithOtelsqlOptions(otelsql.WithTracerProvider(tp)))
+
+	sql.Register("instrumented-"+t.Name(), WrapDriver(mockDriver{}, options...))
+	db, err := sql.Open("instrumented-"+t.Name(), "")
+	require.NoError(t, err)
+	defer db.Close()
+
+	require.NoError(t, db.PingContext(context.Background()))
+	_, err = db.ExecContext(context.Background(), "UPDATE t\n  SET a = ?, b = ?", 42, "x")
+	require.NoError(t, err)
+
+	spans := make(map[string]attribute.Set)
+	for _, span := range sr.Ended() {
+		spans[span.Name()] = attribute.NewSet(span.Attributes()...)
+	}
+	return spans
+}
+
+func TestWrapDriver(t *testing.T) {
+	spans := exec(t)
+
+	assert.Contains(t, spans, string(otelsql.MethodConnPing))
+	require.Contains(t, spans, string(otelsql.MethodConnExec))
+	attrs := spans[string(otelsql.MethodConnExec)]
+	assert.False(t, attrs.HasValue("args"))
+	statement, _ := attrs.Value("db.statement")
+	assert.Equal(t, "UPDATE t\n  SET a = ?, b = ?", statement.AsString())
+}
+
+func TestWrapDriverWithOptions(t *testing.T) {
+	spans := exec(t,
+		WithIncludeArgs(),
+		WithOpsExcluded(OpSQLPing, OpSQLStmtClose),
+		WithQueryFormatter(func(query string) string {
+			return strings.Join(strings.Fields(query), " ")
+		}),
+	)
+
+	assert.NotContains(t, spans, string(otelsql.MethodConnPing))
+	require.Contains(t, spans, string(otelsql.MethodConnExec))
+	attrs := spans[string(otelsql.MethodConnExec)]
+	args, _ := attrs.Value("args")
+	assert.Equal(t, "{$1: 42, $2: 'x'}", args.AsString())
+	statement, _ := attrs.Value("db.statement")
+	assert.Equal(t, "UPDATE t SET a = ?, b = ?", statement.AsString())
+}
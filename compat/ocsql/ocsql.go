@@ -0,0 +1,290 @@
+// Copyright Sam Xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ocsql maps the option surface of the OpenCensus ocsql wrapper
+// (github.com/opencensus-integrations/ocsql) onto otelsql, so that code
+// instrumented with ocsql can move to OpenTelemetry by changing its import
+// path:
+//
+//	driverName, err := ocsql.Register("postgres", ocsql.WithAllTraceOptions())
+//
+// Telemetry is exported with the global OpenTelemetry providers, use
+// WithOtelsqlOptions to pass further otelsql options.
+//
+// # Differences from ocsql
+//
+// There is no WrapConnector, as otelsql does not expose wrapped connectors.
+// Code calling sql.OpenDB(ocsql.WrapConnector(c)) does not compile after
+// changing the import path and must call ocsql.OpenDB(c) instead.
+//
+// RowsAffected and LastInsertID are accepted for compatibility but have no
+// effect, as otelsql does not create spans for results.
+package ocsql // import "github.com/XSAM/otelsql/compat/ocsql"
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/XSAM/otelsql"
+)
+
+// TraceOptions holds the ocsql tracing options.
+type TraceOptions struct {
+	// AllowRoot, if set to true, will allow spans without a parent span.
+	AllowRoot bool
+
+	// Ping, if set to true, will enable the creation of spans on Ping
+	// requests.
+	Ping bool
+
+	// RowsNext, if set to true, will enable events on RowsNext calls.
+	RowsNext bool
+
+	// RowsClose, if set to true, will enable the creation of spans covering
+	// the consumption of rows.
+	RowsClose bool
+
+	// RowsAffected and LastInsertID have no effect.
+	RowsAffected bool
+	LastInsertID bool
+
+	// Query, if set to true, will record the query in spans.
+	Query bool
+
+	// QueryParams, if set to true, will record the arguments of the query in
+	// spans as sql.arg.<name> or sql.arg.<ordinal> attributes. It requires
+	// Query to be set.
+	QueryParams bool
+
+	// DefaultAttributes are added to every span and measurement.
+	DefaultAttributes []attribute.KeyValue
+
+	// DisableErrSkip, if set to true, will suppress driver.ErrSkip errors in
+	// spans.
+	DisableErrSkip bool
+
+	// InstanceName, if set, is recorded as db.client.connection.pool.name to
+	// tell the instrumented pools apart.
+	InstanceName string
+
+	// OtelsqlOptions are applied after the options mapped from the fields
+	// above. They are set with WithOtelsqlOptions.
+	OtelsqlOptions []otelsql.Option
+}
+
+// AllTraceOptions has all tracing options enabled.
+var AllTraceOptions = TraceOptions{
+	AllowRoot:    true,
+	Ping:         true,
+	RowsNext:     true,
+	RowsClose:    true,
+	RowsAffected: true,
+	LastInsertID: true,
+	Query:        true,
+	QueryParams:  true,
+}
+
+// TraceOption allows for managing ocsql configuration using functional
+// options.
+type TraceOption func(o *TraceOptions)
+
+// WithAllTraceOptions enables all available trace options.
+func WithAllTraceOptions() TraceOption {
+	return func(o *TraceOptions) {
+		*o = AllTraceOptions
+	}
+}
+
+// WithOptions sets the tracing options, replacing the ones set before.
+func WithOptions(options TraceOptions) TraceOption {
+	return func(o *TraceOptions) {
+		*o = options
+	}
+}
+
+// WithAllowRoot if set to true, will allow ocsql to create root spans.
+func WithAllowRoot(b bool) TraceOption {
+	return func(o *TraceOptions) {
+		o.AllowRoot = b
+	}
+}
+
+// WithPing if set to true, will enable the creation of spans on Ping
+// requests.
+func WithPing(b bool) TraceOption {
+	return func(o *TraceOptions) {
+		o.Ping = b
+	}
+}
+
+// WithRowsNext if set to true, will enable events on RowsNext calls.
+func WithRowsNext(b bool) TraceOption {
+	return func(o *TraceOptions) {
+		o.RowsNext = b
+	}
+}
+
+// WithRowsClose if set to true, will enable the creation of spans on
+// RowsClose calls.
+func WithRowsClose(b bool) TraceOption {
+	return func(o *TraceOptions) {
+		o.RowsClose = b
+	}
+}
+
+// WithRowsAffected has no effect, it is kept for compatibility.
+func WithRowsAffected(b bool) TraceOption {
+	return func(o *TraceOptions) {
+		o.RowsAffected = b
+	}
+}
+
+// WithLastInsertID has no effect, it is kept for compatibility.
+func WithLastInsertID(b bool) TraceOption {
+	return func(o *TraceOptions) {
+		o.LastInsertID = b
+	}
+}
+
+// WithQuery if set to true, will enable recording of sql queries in spans.
+func WithQuery(b bool) TraceOption {
+	return func(o *TraceOptions) {
+		o.Query = b
+	}
+}
+
+// WithQueryParams if set to true, will enable recording of parameters used
+// with parametrized queries. It will only be honored if WithQuery is set to
+// true as well.
+func WithQueryParams(b bool) TraceOption {
+	return func(o *TraceOptions) {
+		o.QueryParams = b
+	}
+}
+
+// WithDefaultAttributes will set default attributes for each span and
+// measurement.
+func WithDefaultAttributes(attrs ...attribute.KeyValue) TraceOption {
+	return func(o *TraceOptions) {
+		o.DefaultAttributes = attrs
+	}
+}
+
+// WithDisableErrSkip, if set to true, will suppress driver.ErrSkip errors in
+// spans.
+func WithDisableErrSkip(b bool) TraceOption {
+	return func(o *TraceOptions) {
+		o.DisableErrSkip = b
+	}
+}
+
+// WithInstanceName sets the name of the instrumented pool.
+func WithInstanceName(instanceName string) TraceOption {
+	return func(o *TraceOptions) {
+		o.InstanceName = instanceName
+	}
+}
+
+// WithOtelsqlOptions appends otelsql options, for the features ocsql does not
+// have.
+func WithOtelsqlOptions(options ...otelsql.Option) TraceOption {
+	return func(o *TraceOptions) {
+		o.OtelsqlOptions = append(o.OtelsqlOptions, options...)
+	}
+}
+
+// Register initializes and registers an otelsql wrapped database driver
+// identified by its driverName, and returns the name to pass to sql.Open.
+func Register(driverName string, options ...TraceOption) (string, error) {
+	return otelsql.Register(driverName, Options(options...)...)
+}
+
+// Wrap takes a SQL driver and wraps it with otelsql instrumentation.
+func Wrap(d driver.Driver, options ...TraceOption) driver.Driver {
+	return otelsql.WrapDriver(d, Options(options...)...)
+}
+
+// OpenDB opens a DB with the connector wrapped with otelsql instrumentation.
+func OpenDB(dc driver.Connector, options ...TraceOption) *sql.DB {
+	return otelsql.OpenDB(dc, Options(options...)...)
+}
+
+// RecordStats records the DBStats metrics of db until the returned func is
+// called. The interval is ignored, the metrics are collected by the reader
+// of the global MeterProvider.
+func RecordStats(db *sql.DB, _ time.Duration) func() {
+	registration, err := otelsql.RegisterDBStatsMetricsWithShutdown(db)
+	if err != nil {
+		otel.Handle(err)
+		return func() {}
+	}
+	return func() {
+		_ = registration.Shutdown(context.Background())
+	}
+}
+
+// Options returns the otelsql options matching the ocsql options.
+func Options(options ...TraceOption) []otelsql.Option {
+	var o TraceOptions
+	for _, option := range options {
+		option(&o)
+	}
+
+	spanOptions := otelsql.SpanOptions{
+		Ping:           o.Ping,
+		RowsNext:       o.RowsNext,
+		OmitRows:       !o.RowsClose,
+		DisableQuery:   !o.Query,
+		DisableErrSkip: o.DisableErrSkip,
+	}
+	if !o.AllowRoot {
+		spanOptions.ParentSpanFilter = func(
+			_ context.Context, parent trace.SpanContext, _ otelsql.Method, _ string, _ []driver.NamedValue,
+		) bool {
+			return parent.IsValid()
+		}
+	}
+
+	opts := []otelsql.Option{otelsql.WithSpanOptions(spanOptions)}
+	if len(o.DefaultAttributes) > 0 {
+		opts = append(opts, otelsql.WithAttributes(o.DefaultAttributes...))
+	}
+	if o.InstanceName != "" {
+		opts = append(opts, otelsql.WithPoolName(o.InstanceName))
+	}
+	if o.Query && o.QueryParams {
+		opts = append(opts, otelsql.WithAttributesGetter(argsAttributes))
+	}
+	return append(opts, o.OtelsqlOptions...)
+}
+
+func argsAttributes(_ context.Context, _ otelsql.Method, _ string, args []driver.NamedValue) []attribute.KeyValue {
+	attrs := make([]attribute.KeyValue, 0, len(args))
+	for _, arg := range args {
+		key := "sql.arg." + arg.Name
+		if arg.Name == "" {
+			key = "sql.arg." + strconv.Itoa(arg.Ordinal)
+		}
+		attrs = append(attrs, attribute.String(key, fmt.Sprint(arg.Value)))
+	}
+	return attrs
+}
@@ -0,0 +1,136 @@
+// Copyright Sam Xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !otelsql_noop
+
+package ocsql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/XSAM/otelsql"
+)
+
+type mockConnector struct{}
+
+func (mockConnector) Connect(context.Context) (driver.Conn, error) {
+	return mockConn{}, nil
+}
+
+func (mockConnector) Driver() driver.Driver {
+	return nil
+}
+
+type mockConn struct{}
+
+func (mockConn) Prepare(string) (driver.Stmt, error) {
+	return nil, driver.ErrSkip
+}
+
+func (mockConn) Close() error {
+	return nil
+}
+
+func (mockConn) Begin() (driver.Tx, error) {
+	return nil, driver.ErrSkip
+}
+
+func (mockConn) ExecContext(context.Context, string, []driver.NamedValue) (driver.Result, error) {
+	return driver.RowsAffected(1), nil
+}
+
+func (mockConn) Ping(context.Context) error {
+	return nil
+}
+
+func openDB(t *testing.T, options ...TraceOption) (*sql.DB, *tracetest.SpanRecorder, context.Context) {
+	t.Helper()
+
+	sr := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+	options = append(options, WithOtelsqlOptions(otelsql.WithTracerProvider(tp)))
+
+	db := OpenDB(mockConnector{}, options...)
+	t.Cleanup(func() { _ = db.Close() })
+
+	ctx, span := tp.Tracer("test").Start(context.Background(), "parent")
+	t.Cleanup(func() { span.End() })
+	return db, sr, ctx
+}
+
+func TestOpenDB(t *testing.T) {
+	db, sr, ctx := openDB(t,
+		WithAllTraceOptions(),
+		WithDefaultAttributes(attribute.String("service", "orders")),
+		WithInstanceName("primary"),
+	)
+
+	require.NoError(t, db.PingContext(ctx))
+	_, err := db.ExecContext(ctx, "UPDATE t SET a = ?", 42)
+	require.NoError(t, err)
+
+	var names []string
+	for _, span := range sr.Ended() {
+		names = append(names, span.Name())
+	}
+	assert.Contains(t, names, string(otelsql.MethodConnPing))
+	assert.Contains(t, names, string(otelsql.MethodConnExec))
+
+	for _, span := range sr.Ended() {
+		if span.Name() != string(otelsql.MethodConnExec) {
+			continue
+		}
+		attrs := attribute.NewSet(span.Attributes()...)
+		arg, _ := attrs.Value("sql.arg.1")
+		assert.Equal(t, "42", arg.AsString())
+		service, _ := attrs.Value("service")
+		assert.Equal(t, "orders", service.AsString())
+		pool, _ := attrs.Value("db.client.connection.pool.name")
+		assert.Equal(t, "primary", pool.AsString())
+		statement, _ := attrs.Value("db.statement")
+		assert.Equal(t, "UPDATE t SET a = ?", statement.AsString())
+	}
+}
+
+func TestOpenDBDefaults(t *testing.T) {
+	db, sr, ctx := openDB(t)
+
+	require.NoError(t, db.PingContext(ctx))
+	_, err := db.ExecContext(ctx, "UPDATE t SET a = ?", 42)
+	require.NoError(t, err)
+	// Root spans are not allowed by default.
+	_, err = db.ExecContext(context.Background(), "UPDATE t SET a = ?", 42)
+	require.NoError(t, err)
+
+	var spans []sdktrace.ReadOnlySpan
+	for _, span := range sr.Ended() {
+		assert.NotEqual(t, string(otelsql.MethodConnPing), span.Name())
+		if span.Name() == string(otelsql.MethodConnExec) {
+			spans = append(spans, span)
+		}
+	}
+	require.Len(t, spans, 1)
+	attrs := attribute.NewSet(spans[0].Attributes()...)
+	assert.False(t, attrs.HasValue("db.statement"))
+	assert.False(t, attrs.HasValue("sql.arg.1"))
+}
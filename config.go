@@ -17,34 +17,101 @@ package otelsql
 import (
 	"context"
 	"database/sql/driver"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"time"
 
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/metric"
+	metricnoop "go.opentelemetry.io/otel/metric/noop"
+	semconv "go.opentelemetry.io/otel/semconv/v1.18.0"
 	"go.opentelemetry.io/otel/trace"
+	tracenoop "go.opentelemetry.io/otel/trace/noop"
+
+	"github.com/XSAM/otelsql/semconvutil"
 )
 
 const (
 	instrumentationName = "github.com/XSAM/otelsql"
+
+	// disabledEnvKey is the environment variable that, if set to true, turns
+	// the instrumentation off, see newConfig.
+	disabledEnvKey = "OTEL_SQL_DISABLED"
 )
 
 var (
 	connectionStatusKey = attribute.Key("status")
+	connectionStateKey  = attribute.Key("db.client.connection.state")
 	queryStatusKey      = attribute.Key("status")
 	queryMethodKey      = attribute.Key("method")
+	txIsolationLevelKey = attribute.Key("db.sql.tx.isolation_level")
+	txReadOnlyKey       = attribute.Key("db.sql.tx.read_only")
+
+	deadlineRemainingKey = attribute.Key("db.sql.context.deadline_remaining")
+	eventDurationKey     = attribute.Key("db.sql.duration")
+
+	// dbSystemNameKey is the stable replacement of db.system.
+	dbSystemNameKey = attribute.Key("db.system.name")
+
+	dbRoleKey    = attribute.Key("db.role")
+	poolNameKey  = attribute.Key("db.client.connection.pool.name")
+	dbShardIDKey = attribute.Key("db.shard.id")
+	tenantIDKey  = attribute.Key("tenant.id")
 )
 
+var errMissingDBSystem = errors.New("otelsql: strict mode requires the db.system attribute to be configured")
+
 // SpanNameFormatter supports formatting span names.
 type SpanNameFormatter func(ctx context.Context, method Method, query string) string
 
+// QueryTransformer returns the query passed to the driver in place of query
+// for a call of method, e.g. with optimizer hints or routing comments.
+type QueryTransformer func(ctx context.Context, method Method, query string) string
+
+// ArgsRedactor returns the arguments of a call as seen by the telemetry, e.g.
+// with emails or other personal data masked.
+type ArgsRedactor func(args []driver.NamedValue) []driver.NamedValue
+
 // AttributesGetter provides additional attributes on spans creation.
 type AttributesGetter func(ctx context.Context, method Method, query string, args []driver.NamedValue) []attribute.KeyValue
 
 // InstrumentAttributesGetter provides additional attributes while recording metrics to instruments.
 type InstrumentAttributesGetter func(ctx context.Context, method Method, query string, args []driver.NamedValue) []attribute.KeyValue
 
+// ConnectAttributesGetter provides additional attributes on the spans and
+// measurements of sql.connector.connect, given the data source name of the
+// connector without credentials, which is empty if unknown, e.g. with OpenDB,
+// and the connector wrapped by otelsql.
+type ConnectAttributesGetter func(ctx context.Context, dsn string, connector driver.Connector) []attribute.KeyValue
+
+// DurationUnit is the unit of the instruments recording durations, see
+// WithDurationUnit.
+type DurationUnit string
+
+const (
+	// DurationUnitMilliseconds records durations in milliseconds.
+	DurationUnitMilliseconds DurationUnit = "ms"
+	// DurationUnitSeconds records durations in seconds, the unit of the
+	// semantic conventions.
+	DurationUnitSeconds DurationUnit = "s"
+)
+
+// TenantGetter returns the tenant a call is made for, or an empty string if
+// there is none, see WithTenantAttribute.
+type TenantGetter func(ctx context.Context) string
+
 type SpanFilter func(ctx context.Context, method Method, query string, args []driver.NamedValue) bool
 
+// ParentSpanFilter is a SpanFilter also given the span context of the parent
+// span, which is invalid if there is none.
+type ParentSpanFilter func(
+	ctx context.Context, parent trace.SpanContext, method Method, query string, args []driver.NamedValue,
+) bool
+
 type config struct {
 	TracerProvider trace.TracerProvider
 	Tracer         trace.Tracer
@@ -59,6 +126,30 @@ type config struct {
 	// Attributes will be set to each span.
 	Attributes []attribute.KeyValue
 
+	// InstrumentationAttributes are the attributes of the instrumentation
+	// scope of the tracer and meter.
+	InstrumentationAttributes []attribute.KeyValue
+
+	// DBSystem is added to Attributes as db.system, db.system.name, or both,
+	// following the semantic conventions opted in with
+	// OTEL_SEMCONV_STABILITY_OPT_IN, when it is not empty.
+	DBSystem string
+
+	// DatabaseRole is added to Attributes as db.role when it is not empty.
+	DatabaseRole string
+
+	// PoolName is added to Attributes as db.client.connection.pool.name when
+	// it is not empty.
+	PoolName string
+
+	// PoolLimits, if set, are the limits of the connection pool reported by
+	// the metrics of RegisterDBStatsMetrics.
+	// Default is nil
+	PoolLimits *PoolLimits
+
+	// ShardID is added to Attributes as db.shard.id when it is not empty.
+	ShardID string
+
 	// SpanNameFormatter will be called to produce span's name.
 	// Default use method as span name
 	SpanNameFormatter SpanNameFormatter
@@ -73,10 +164,193 @@ type config struct {
 	SQLCommenterEnabled bool
 	SQLCommenter        *commenter
 
+	// SQLCommenterIncludeKeys and SQLCommenterExcludeKeys decide which
+	// propagator fields, like traceparent or baggage, are injected into
+	// the comment.
+	// Default injects all fields
+	SQLCommenterIncludeKeys []string
+	SQLCommenterExcludeKeys []string
+
+	// SQLCommenterDMLOnly, if set to true, only injects comments into
+	// INSERT, UPDATE, DELETE, and other data modification statements.
+	// Default is false
+	SQLCommenterDMLOnly bool
+
+	// ArgsRedactor, if set, redacts the arguments of calls before they are
+	// passed to the getters, the filters, and the recorders.
+	// Default is nil
+	ArgsRedactor ArgsRedactor
+
+	// QueryTransformer, if set, rewrites the queries passed to the driver.
+	// Spans and metrics keep the original query.
+	// Default is nil
+	QueryTransformer QueryTransformer
+
+	// QueryTransformerAfterCommenter, if set to true, applies
+	// QueryTransformer to the query with the comment of SQLCommenter instead
+	// of before the comment is added.
+	// Default is false
+	QueryTransformerAfterCommenter bool
+
+	// ApplicationNamePropagation, if set to true, propagates the span context
+	// by setting the PostgreSQL application_name of the session, prefixed by
+	// ApplicationNamePrefix.
+	// Default is false
+	ApplicationNamePropagation bool
+	ApplicationNamePrefix      string
+
+	// UnwrappedRows, if set to true, returns the rows of the driver as is,
+	// without sql.rows spans nor measurements, to avoid the overhead of the
+	// wrapper on large result sets.
+	// Default is false
+	UnwrappedRows bool
+
+	// ServerVersionProbe, if set to true, queries the version of the
+	// database server, chosen by the db.system attribute, on the first
+	// connection and adds it to spans as db.system.version.
+	// Default is false
+	ServerVersionProbe bool
+
+	// DatabaseNameProbe, if set to true, queries the name of the current
+	// database, chosen by the db.system attribute, on the first connection
+	// and adds it to spans as db.name or db.namespace unless Attributes
+	// already contain one of them.
+	// Default is false
+	DatabaseNameProbe bool
+
+	// ErrorTraceIDs, if set to true, annotates errors returned by the driver
+	// with the active span context, see TraceIDFromError.
+	// Default is false
+	ErrorTraceIDs bool
+
+	// PprofLabels, if set to true, sets pprof labels with the method and the
+	// query summary on the goroutine during driver calls.
+	// Default is false
+	PprofLabels bool
+
+	// QuerySummaryMetricLimit, if positive, adds the db.query.summary
+	// attribute to the latency metric, with at most QuerySummaryMetricLimit
+	// distinct values.
+	// Default is 0
+	QuerySummaryMetricLimit int
+
+	// CollectionNameOnMetrics, if set to true, adds the db.collection.name
+	// attribute, the first table of the query, to db.sql.latency and
+	// db.client.operation.duration.
+	// Default is false
+	CollectionNameOnMetrics bool
+
+	// DurationUnit is the unit of db.sql.latency and
+	// db.sql.connection.wait_duration.
+	// Default is DurationUnitMilliseconds
+	DurationUnit DurationUnit
+
+	// PrometheusNaming, if set to true, names the sql.DBStats instruments
+	// following the Prometheus conventions, see WithPrometheusNaming.
+	// Default is false
+	PrometheusNaming bool
+
+	// QueryRecorder, if set, captures the statements sent to the driver.
+	// Default is nil
+	QueryRecorder *QueryRecorder
+
+	// QueryAggregator, if set, aggregates the statistics of the executed
+	// statements.
+	// Default is nil
+	QueryAggregator *QueryAggregator
+
+	// OldestCallMetric, if set to true, records the age of the oldest driver
+	// call in progress with the db.sql.oldest_call.age gauge.
+	// Default is false
+	OldestCallMetric bool
+
+	// OldestCallFingerprint, if set to true, adds the fingerprint of the
+	// query of the oldest call to db.sql.oldest_call.age as
+	// db.query.fingerprint.
+	// Default is false
+	OldestCallFingerprint bool
+
+	// AuditRecorder, if set, is called for each data modification statement
+	// run through the driver.
+	// Default is nil
+	AuditRecorder AuditRecorder
+
+	// TenantGetter, if set, adds the tenant.id attribute to spans.
+	// Default is nil
+	TenantGetter TenantGetter
+
+	// TenantMetricLimit, if positive, also adds the tenant.id attribute to
+	// the latency metric, with at most TenantMetricLimit distinct values.
+	// Default is 0
+	TenantMetricLimit int
+
+	// semconvStability is the version of the semantic conventions followed by
+	// the instruments, opted in with OTEL_SEMCONV_STABILITY_OPT_IN.
+	semconvStability semconvutil.Stability
+
+	// querySummaries, collectionNames and tenants cap the distinct
+	// db.query.summary, db.collection.name and tenant.id values recorded in
+	// metrics.
+	querySummaries  *metricAttributeLimiter
+	collectionNames *metricAttributeLimiter
+	tenants         *metricAttributeLimiter
+
+	// serverProbe runs the probe queries and holds their results. It is
+	// shared by all connections created from the config.
+	serverProbe *serverProbe
+
+	// disabled reports whether the instrumentation is turned off by the
+	// OTEL_SQL_DISABLED environment variable.
+	disabled bool
+
+	// inFlightCalls keeps the driver calls in progress if OldestCallMetric
+	// is set.
+	inFlightCalls *inFlightRegistry
+
+	// noopMeter reports whether MeterProvider is the MeterProvider of
+	// go.opentelemetry.io/otel/metric/noop, in which case recordMetric skips
+	// building the attributes of measurements. Other providers recording
+	// nothing, like the global one before otel.SetMeterProvider is called or
+	// an SDK one without readers, are not detected, as they may start
+	// recording later.
+	noopMeter bool
+
+	// dbSystem is the value of the db.system attribute, if set, used to look
+	// up the classifiers registered by RegisterErrorClassifier.
+	dbSystem string
+
+	// SessionContextPropagation, if set to true, propagates the span context
+	// by setting the SQL Server SESSION_CONTEXT of the session when it is
+	// reset.
+	// Default is false
+	SessionContextPropagation bool
+
+	// MySQLConnectionAttributes, if not nil, are added to the
+	// connectionAttributes of go-sql-driver/mysql DSNs together with the
+	// traceparent of the connecting context.
+	// Default is nil
+	MySQLConnectionAttributes map[string]string
+
+	// ServerAttributesFromDSN, if set to true, adds the server address and
+	// port parsed from the data source name of the driver connections to
+	// spans and metrics.
+	// Default is false
+	ServerAttributesFromDSN bool
+
+	// SQLCommenterMaxLength, if positive, limits the length of the injected
+	// comment content. Fields that exceed the limit are dropped.
+	// Default is 0, which means no limit
+	SQLCommenterMaxLength int
+
 	// AttributesGetter will be called to produce additional attributes while creating spans.
 	// Default returns nil
 	AttributesGetter AttributesGetter
 
+	// ConnectAttributesGetter will be called to produce additional attributes
+	// on connect, e.g. the shard or the replica role of the connection.
+	// Default is nil
+	ConnectAttributesGetter ConnectAttributesGetter
+
 	// InstrumentAttributesGetter will be called to produce additional attributes while recording metrics to instruments.
 	// Default returns nil
 	InstrumentAttributesGetter InstrumentAttributesGetter
@@ -85,6 +359,30 @@ type config struct {
 	// The measurement will be recorded as status=ok.
 	// Default is false
 	DisableSkipErrMeasurement bool
+
+	// DisableRowsMeasurement, if set to true, will suppress the
+	// measurements of sql.rows in db.sql.latency and
+	// db.client.operation.duration. They measure how long the application
+	// iterates over rows, not the latency of the database, and skew the
+	// percentiles of the other methods. sql.rows spans are kept.
+	// Default is false
+	DisableRowsMeasurement bool
+
+	// SavepointDetection, if set to true, instruments savepoint statements
+	// issued through Exec with dedicated methods, like sql.tx.savepoint.
+	// Default is false
+	SavepointDetection bool
+
+	// ExpectedErrors, if set, will be invoked with errors returned by the
+	// driver. If it returns true, the error is neither recorded on the span
+	// nor measured as status=error, but it is still returned to the caller.
+	// Default is nil
+	ExpectedErrors func(err error) bool
+
+	// StrictMode, if set to true, makes Open and Register fail when required
+	// semantic convention attributes, like db.system, are not configured.
+	// Default is false
+	StrictMode bool
 }
 
 // SpanOptions holds configuration of tracing span to decide
@@ -100,12 +398,58 @@ type SpanOptions struct {
 	// calls. This can result in many events.
 	RowsNext bool
 
+	// RowsProgressInterval and RowsProgressRows, if positive, enable
+	// sql.rows.progress events on sql.rows spans, emitted after the given
+	// duration or number of rows since the last event. The events carry the
+	// number of rows read so far, so long iterations can be observed before
+	// they finish.
+	// Default is 0
+	RowsProgressInterval time.Duration
+	RowsProgressRows     int
+
+	// LargeResultSetRows, if positive, adds a single
+	// db.response.large_result_set event to sql.rows spans when more than
+	// LargeResultSetRows rows are read, to flag accidental unbounded queries.
+	// Default is 0
+	LargeResultSetRows int
+
 	// DisableErrSkip, if set to true, will suppress driver.ErrSkip errors in spans.
 	DisableErrSkip bool
 
 	// DisableQuery if set to true, will suppress db.statement in spans.
 	DisableQuery bool
 
+	// RecordConnectionString, if set to true, will add the data source name
+	// the connector was opened with, without its password, to
+	// sql.connector.connect spans as db.connection_string. DSNs of unknown
+	// formats are not recorded, as their password cannot be removed.
+	RecordConnectionString bool
+
+	// RecordTransactionID, if set to true, will generate an ID for each
+	// transaction and add it as db.transaction.id to the spans of the
+	// transaction, from sql.conn.begin_tx to sql.tx.commit or
+	// sql.tx.rollback, so that they can be grouped without a parent span
+	// for the transaction.
+	RecordTransactionID bool
+
+	// RecordTransactionSummary, if set to true, will add the number of
+	// statements executed in the transaction, the rows they affected, and the
+	// time elapsed since the transaction began to sql.tx.commit and
+	// sql.tx.rollback spans.
+	RecordTransactionSummary bool
+
+	// RecordStatementID, if set to true, will generate an ID for each
+	// prepared statement and add it as db.prepared_statement.id to its
+	// sql.conn.prepare span and to the spans of its executions, so that the
+	// reuse of a statement can be traced back to its preparation.
+	RecordStatementID bool
+
+	// RecordDeadline, if set to true, will add the time remaining until the
+	// context deadline at the start of the call, in seconds, to spans as
+	// db.sql.context.deadline_remaining. Nothing is added if the context has
+	// no deadline.
+	RecordDeadline bool
+
 	// RecordError, if set, will be invoked with the current error, and if the func returns true
 	// the record will be recorded on the current span.
 	//
@@ -113,56 +457,265 @@ type SpanOptions struct {
 	// DisableErrSkip).
 	RecordError func(err error) bool
 
+	// RecordErrorWithContext, if set, will be invoked like RecordError with
+	// the context, the method, and the query of the failed call, so the
+	// recording can differ between methods, e.g. to ignore Ping errors. An
+	// error is only recorded if both RecordError and RecordErrorWithContext
+	// allow it.
+	RecordErrorWithContext func(ctx context.Context, method Method, query string, err error) bool
+
+	// ErrorRecordingMode decides whether an error is recorded as an exception
+	// event, as the span status, or both. Default is ErrorRecordingModeBoth.
+	ErrorRecordingMode ErrorRecordingMode
+
+	// RecordErrorStackTrace, if set to true, will add the stack trace of the
+	// call site to the exception event of recorded errors.
+	RecordErrorStackTrace bool
+
+	// CompactSpans, if set to true, records sql.tx.commit, sql.tx.rollback,
+	// and sql.conn.reset_session as events on the span of the calling context
+	// instead of separate spans. The events carry the duration of the call
+	// and its error, if any.
+	CompactSpans bool
+
 	// OmitConnResetSession if set to true will suppress sql.conn.reset_session spans
 	OmitConnResetSession bool
 
+	// CollapsePrepareFallback, if set to true, reduces the spans created
+	// when database/sql falls back to Prepare and a statement call because
+	// the connection returned driver.ErrSkip: the sql.conn.prepare span is
+	// suppressed and the statement span has the db.sql.prepare_fallback
+	// attribute.
+	CollapsePrepareFallback bool
+
 	// OmitConnPrepare if set to true will suppress sql.conn.prepare spans
 	OmitConnPrepare bool
 
 	// OmitConnQuery if set to true will suppress sql.conn.query spans
 	OmitConnQuery bool
 
+	// OmitConnExec if set to true will suppress sql.conn.exec spans
+	OmitConnExec bool
+
+	// MergeRowsIntoQuerySpan, if set to true, ends sql.conn.query and
+	// sql.stmt.query spans when the returned rows are closed, with the number
+	// of rows read, instead of creating sql.rows spans.
+	MergeRowsIntoQuerySpan bool
+
+	// RowsSpanRelation decides how sql.rows spans, covering the consumption
+	// of rows, relate to the sql.conn.query or sql.stmt.query spans, which end
+	// when the driver returns the rows. Default is RowsSpanRelationSibling.
+	RowsSpanRelation RowsSpanRelation
+
 	// OmitRows if set to true will suppress sql.rows spans
 	OmitRows bool
 
+	// RowsCloseErrorAsEvent, if set to true, records errors returned when
+	// closing rows, e.g. a context canceled during cleanup after a successful
+	// iteration, as sql.rows.close.error events instead of span errors, and
+	// records the call as successful in the metrics. Errors of the iteration
+	// are still recorded as span errors.
+	RowsCloseErrorAsEvent bool
+
+	// OmitTxCommit if set to true will suppress sql.tx.commit spans
+	OmitTxCommit bool
+
+	// OmitTxRollback if set to true will suppress sql.tx.rollback spans
+	OmitTxRollback bool
+
+	// OmitStmtExec if set to true will suppress sql.stmt.exec spans
+	OmitStmtExec bool
+
+	// OmitStmtQuery if set to true will suppress sql.stmt.query spans
+	OmitStmtQuery bool
+
 	// OmitConnectorConnect if set to true will suppress sql.connector.connect spans
 	OmitConnectorConnect bool
 
 	// SpanFilter, if set, will be invoked before each call to create a span. If it returns
 	// false, the span will not be created.
 	SpanFilter SpanFilter
+
+	// ParentSpanFilter, if set, will be invoked before each call to create a
+	// span with the span context of the parent span, e.g. to only create
+	// spans when the parent is sampled. If it returns false, the span will
+	// not be created. It applies together with SpanFilter.
+	ParentSpanFilter ParentSpanFilter
 }
 
+// ErrorRecordingMode specifies how errors are recorded on spans.
+type ErrorRecordingMode int
+
+const (
+	// ErrorRecordingModeBoth records errors as exception events and sets the
+	// span status to error.
+	ErrorRecordingModeBoth ErrorRecordingMode = iota
+	// ErrorRecordingModeEvent only records errors as exception events.
+	ErrorRecordingModeEvent
+	// ErrorRecordingModeStatus only sets the span status to error.
+	ErrorRecordingModeStatus
+)
+
+// RowsSpanRelation specifies how sql.rows spans relate to the spans of the
+// queries returning the rows.
+type RowsSpanRelation int
+
+const (
+	// RowsSpanRelationSibling makes sql.rows spans children of the parent of
+	// the query spans, without relation to them.
+	RowsSpanRelationSibling RowsSpanRelation = iota
+	// RowsSpanRelationLink makes sql.rows spans siblings of the query spans,
+	// with a link to them.
+	RowsSpanRelationLink
+	// RowsSpanRelationChild makes sql.rows spans children of the query spans.
+	RowsSpanRelationChild
+)
+
 func defaultSpanNameFormatter(_ context.Context, method Method, _ string) string {
 	return string(method)
 }
 
 // newConfig returns a config with all Options set.
+//
+// If the OTEL_SQL_DISABLED environment variable is set to true, or the
+// package is built with the otelsql_noop build tag, options are ignored and
+// the config uses no-op providers, so that the drivers and connectors are
+// returned unwrapped, see disabledByEnv.
 func newConfig(options ...Option) config {
 	cfg := config{
 		TracerProvider:    otel.GetTracerProvider(),
 		MeterProvider:     otel.GetMeterProvider(),
 		SpanNameFormatter: defaultSpanNameFormatter,
 	}
-	for _, opt := range options {
-		opt.Apply(&cfg)
+	if noopBuild || disabledByEnv() {
+		cfg.TracerProvider = tracenoop.NewTracerProvider()
+		cfg.MeterProvider = metricnoop.NewMeterProvider()
+		cfg.disabled = true
+	} else {
+		for _, opt := range getDefaultOptions() {
+			opt.Apply(&cfg)
+		}
+		for _, opt := range options {
+			opt.Apply(&cfg)
+		}
 	}
 
-	cfg.Tracer = cfg.TracerProvider.Tracer(
-		instrumentationName,
+	cfg.semconvStability = semconvutil.StabilityFromEnv()
+	if cfg.ExpectedErrors != nil {
+		cfg.SpanOptions.RecordError = withoutExpectedErrors(cfg.SpanOptions.RecordError, cfg.ExpectedErrors)
+	}
+
+	if cfg.DBSystem != "" {
+		cfg.Attributes = append(slices.Clip(cfg.Attributes), semconvutil.DBSystemAttributes(cfg.DBSystem, cfg.semconvStability)...)
+	}
+	if cfg.DatabaseRole != "" {
+		cfg.Attributes = append(slices.Clip(cfg.Attributes), dbRoleKey.String(cfg.DatabaseRole))
+	}
+	if cfg.PoolName != "" {
+		cfg.Attributes = append(slices.Clip(cfg.Attributes), poolNameKey.String(cfg.PoolName))
+	}
+	if cfg.ShardID != "" {
+		cfg.Attributes = append(slices.Clip(cfg.Attributes), dbShardIDKey.String(cfg.ShardID))
+	}
+	for _, attr := range cfg.Attributes {
+		if attr.Key == semconv.DBSystemKey || attr.Key == dbSystemNameKey {
+			cfg.dbSystem = attr.Value.AsString()
+		}
+	}
+
+	cfg.serverProbe = newServerProbe(probeQueries(cfg))
+	if cfg.QuerySummaryMetricLimit > 0 {
+		cfg.querySummaries = newQuerySummaryLimiter(cfg.QuerySummaryMetricLimit)
+	}
+	if cfg.CollectionNameOnMetrics {
+		cfg.collectionNames = newCollectionNameLimiter()
+	}
+	if cfg.TenantGetter != nil && cfg.TenantMetricLimit > 0 {
+		cfg.tenants = newTenantLimiter(cfg.TenantMetricLimit)
+	}
+
+	_, cfg.noopMeter = cfg.MeterProvider.(metricnoop.MeterProvider)
+	schemaURL := semconvutil.SchemaURL(cfg.semconvStability)
+	tracerOptions := []trace.TracerOption{
 		trace.WithInstrumentationVersion(Version()),
-	)
-	cfg.Meter = cfg.MeterProvider.Meter(
-		instrumentationName,
+		trace.WithSchemaURL(schemaURL),
+	}
+	meterOptions := []metric.MeterOption{
 		metric.WithInstrumentationVersion(Version()),
-	)
-
-	cfg.SQLCommenter = newCommenter(cfg.SQLCommenterEnabled)
+		metric.WithSchemaURL(schemaURL),
+	}
+	if len(cfg.InstrumentationAttributes) > 0 {
+		tracerOptions = append(tracerOptions, trace.WithInstrumentationAttributes(cfg.InstrumentationAttributes...))
+		meterOptions = append(meterOptions, metric.WithInstrumentationAttributes(cfg.InstrumentationAttributes...))
+	}
+	cfg.Tracer = cfg.TracerProvider.Tracer(instrumentationName, tracerOptions...)
+	cfg.Meter = cfg.MeterProvider.Meter(instrumentationName, meterOptions...)
 
 	var err error
-	if cfg.Instruments, err = newInstruments(cfg.Meter); err != nil {
+	if cfg.Instruments, err = newInstruments(cfg.Meter, cfg.DurationUnit, cfg.semconvStability); err != nil {
 		otel.Handle(err)
 	}
 
+	cfg.SQLCommenter = newCommenter(cfg.SQLCommenterEnabled)
+	cfg.SQLCommenter.allowKey = newCommenterKeyFilter(cfg.SQLCommenterIncludeKeys, cfg.SQLCommenterExcludeKeys)
+	cfg.SQLCommenter.dmlOnly = cfg.SQLCommenterDMLOnly
+	if cfg.SQLCommenterEnabled && cfg.Instruments != nil {
+		addedBytes, attrs := cfg.Instruments.commenterAddedBytes, metric.WithAttributes(cfg.Attributes...)
+		cfg.SQLCommenter.onComment = func(ctx context.Context, n int) {
+			addedBytes.Record(ctx, int64(n), attrs)
+		}
+	}
+	if cfg.SQLCommenterMaxLength > 0 {
+		cfg.SQLCommenter.maxLength = cfg.SQLCommenterMaxLength
+		if cfg.Instruments != nil {
+			truncated, attrs := cfg.Instruments.commenterTruncated, metric.WithAttributes(cfg.Attributes...)
+			cfg.SQLCommenter.onTruncate = func(ctx context.Context) {
+				truncated.Add(ctx, 1, attrs)
+			}
+		}
+	}
+
 	return cfg
 }
+
+// disabledByEnv reports whether the OTEL_SQL_DISABLED environment variable
+// turns the instrumentation off. Invalid values are reported to the global
+// error handler and leave the instrumentation on.
+func disabledByEnv() bool {
+	v, ok := os.LookupEnv(disabledEnvKey)
+	if !ok || v == "" {
+		return false
+	}
+	disabled, err := strconv.ParseBool(v)
+	if err != nil {
+		otel.Handle(fmt.Errorf("otelsql: invalid %s value %q: %w", disabledEnvKey, v, err))
+		return false
+	}
+	return disabled
+}
+
+// validate checks that the config satisfies strict mode.
+// It always returns nil if strict mode is disabled.
+func (c config) validate() error {
+	if !c.StrictMode {
+		return nil
+	}
+
+	for _, attr := range c.Attributes {
+		if attr.Key == semconv.DBSystemKey || attr.Key == dbSystemNameKey {
+			return nil
+		}
+	}
+	return errMissingDBSystem
+}
+
+// withoutExpectedErrors wraps a SpanOptions.RecordError func so that expected
+// errors are never recorded.
+func withoutExpectedErrors(recordError, expectedErrors func(err error) bool) func(err error) bool {
+	return func(err error) bool {
+		if expectedErrors(err) {
+			return false
+		}
+		return recordError == nil || recordError(err)
+	}
+}
@@ -12,18 +12,29 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
+//go:build !otelsql_noop
+
 package otelsql
 
 import (
 	"context"
+	"database/sql/driver"
+	"errors"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/metric"
+	metricnoop "go.opentelemetry.io/otel/metric/noop"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 	semconv "go.opentelemetry.io/otel/semconv/v1.18.0"
 	"go.opentelemetry.io/otel/trace"
+	tracenoop "go.opentelemetry.io/otel/trace/noop"
+
+	"github.com/XSAM/otelsql/semconvutil"
 )
 
 func TestNewConfig(t *testing.T) {
@@ -39,11 +50,13 @@ func TestNewConfig(t *testing.T) {
 		Tracer: otel.GetTracerProvider().Tracer(
 			instrumentationName,
 			trace.WithInstrumentationVersion(Version()),
+			trace.WithSchemaURL(semconv.SchemaURL),
 		),
 		MeterProvider: otel.GetMeterProvider(),
 		Meter: otel.GetMeterProvider().Meter(
 			instrumentationName,
 			metric.WithInstrumentationVersion(Version()),
+			metric.WithSchemaURL(semconv.SchemaURL),
 		),
 		// No need to check values of instruments in this part.
 		Instruments: cfg.Instruments,
@@ -52,6 +65,176 @@ func TestNewConfig(t *testing.T) {
 			semconv.DBSystemMySQL,
 		},
 		SQLCommenter: newCommenter(false),
+		dbSystem:     "mysql",
 	}, cfg)
 	assert.NotNil(t, cfg.Instruments)
 }
+
+func TestNewConfigWithInstrumentationAttributes(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+	role := attribute.String("role", "primary")
+
+	cfg := newConfig(WithTracerProvider(tp), WithInstrumentationAttributes(role))
+	_, span := cfg.Tracer.Start(context.Background(), "test")
+	span.End()
+
+	spanList := sr.Ended()
+	require.Len(t, spanList, 1)
+	scope := spanList[0].InstrumentationScope()
+	assert.Equal(t, instrumentationName, scope.Name)
+	assert.Equal(t, attribute.NewSet(role), scope.Attributes)
+}
+
+func TestNewConfigSchemaURL(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+
+	cfg := newConfig(WithTracerProvider(tp))
+	_, span := cfg.Tracer.Start(context.Background(), "test")
+	span.End()
+
+	spanList := sr.Ended()
+	require.Len(t, spanList, 1)
+	assert.Equal(t, semconv.SchemaURL, spanList[0].InstrumentationScope().SchemaURL)
+
+	// The schema URL follows the stable conventions when opted in.
+	t.Setenv(semconvutil.OptInEnvKey, "database")
+	cfg = newConfig(WithTracerProvider(tp))
+	_, span = cfg.Tracer.Start(context.Background(), "test")
+	span.End()
+
+	spanList = sr.Ended()
+	require.Len(t, spanList, 2)
+	assert.Equal(t, semconvutil.SchemaURL(semconvutil.StabilityStable), spanList[1].InstrumentationScope().SchemaURL)
+}
+
+func TestNewConfigWithDBSystem(t *testing.T) {
+	attrs := make([]attribute.KeyValue, 1, 2)
+	attrs[0] = attribute.String("foo", "bar")
+
+	cfg := newConfig(WithDBSystem("mysql"), WithAttributes(attrs...))
+
+	assert.Equal(t, []attribute.KeyValue{
+		attribute.String("foo", "bar"),
+		semconv.DBSystemMySQL,
+	}, cfg.Attributes)
+	// The attributes passed by users are not modified.
+	assert.Len(t, attrs, 1)
+	assert.Equal(t, attribute.KeyValue{}, attrs[:2][1])
+	assert.NoError(t, newConfig(WithStrictMode(true), WithDBSystem("mysql")).validate())
+}
+
+func TestNewConfigWithDBSystemStable(t *testing.T) {
+	t.Setenv(semconvutil.OptInEnvKey, "database")
+	cfg := newConfig(WithDBSystem("mysql"))
+	assert.Equal(t, []attribute.KeyValue{dbSystemNameKey.String("mysql")}, cfg.Attributes)
+	assert.Equal(t, "mysql", cfg.dbSystem)
+
+	t.Setenv(semconvutil.OptInEnvKey, "database/dup")
+	cfg = newConfig(WithDBSystem("mysql"))
+	assert.Equal(t, []attribute.KeyValue{semconv.DBSystemMySQL, dbSystemNameKey.String("mysql")}, cfg.Attributes)
+}
+
+func TestNewConfigWithDatabaseRole(t *testing.T) {
+	cfg := newConfig(WithDatabaseRole(DatabaseRoleReplica), WithAttributes(semconv.DBSystemMySQL))
+
+	assert.Equal(t, []attribute.KeyValue{
+		semconv.DBSystemMySQL,
+		dbRoleKey.String("replica"),
+	}, cfg.Attributes)
+}
+
+func TestNewConfigWithPoolName(t *testing.T) {
+	cfg := newConfig(WithPoolName("reports"), WithAttributes(semconv.DBSystemMySQL))
+
+	assert.Equal(t, []attribute.KeyValue{
+		semconv.DBSystemMySQL,
+		poolNameKey.String("reports"),
+	}, cfg.Attributes)
+}
+
+func TestNewConfigWithExpectedErrors(t *testing.T) {
+	expectedErr := errors.New("expected")
+	isExpected := func(err error) bool { return errors.Is(err, expectedErr) }
+
+	cfg := newConfig(WithExpectedErrors(isExpected))
+	assert.False(t, cfg.SpanOptions.RecordError(expectedErr))
+	assert.True(t, cfg.SpanOptions.RecordError(assert.AnError))
+
+	// The RecordError func set by users is still respected.
+	cfg = newConfig(
+		WithExpectedErrors(isExpected),
+		WithSpanOptions(SpanOptions{RecordError: func(err error) bool { return err != driver.ErrSkip }}),
+	)
+	assert.False(t, cfg.SpanOptions.RecordError(expectedErr))
+	assert.False(t, cfg.SpanOptions.RecordError(driver.ErrSkip))
+	assert.True(t, cfg.SpanOptions.RecordError(assert.AnError))
+}
+
+func TestConfigValidate(t *testing.T) {
+	testCases := []struct {
+		name        string
+		cfg         config
+		expectedErr error
+	}{
+		{
+			name: "strict mode disabled",
+			cfg:  config{},
+		},
+		{
+			name:        "strict mode without db.system",
+			cfg:         config{StrictMode: true, Attributes: []attribute.KeyValue{attribute.String("foo", "bar")}},
+			expectedErr: errMissingDBSystem,
+		},
+		{
+			name: "strict mode with db.system",
+			cfg:  config{StrictMode: true, Attributes: []attribute.KeyValue{semconv.DBSystemMySQL}},
+		},
+		{
+			name: "strict mode with db.system.name",
+			cfg:  config{StrictMode: true, Attributes: []attribute.KeyValue{dbSystemNameKey.String("mysql")}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expectedErr, tc.cfg.validate())
+		})
+	}
+}
+
+func TestNewConfigSemconvStability(t *testing.T) {
+	t.Setenv(semconvutil.OptInEnvKey, "database/dup")
+	assert.Equal(t, semconvutil.StabilityDup, newConfig().semconvStability)
+}
+
+func TestNewConfigDisabledByEnv(t *testing.T) {
+	testCases := []struct {
+		value    string
+		disabled bool
+	}{
+		{value: "", disabled: false},
+		{value: "false", disabled: false},
+		{value: "invalid", disabled: false},
+		{value: "true", disabled: true},
+		{value: "1", disabled: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.value, func(t *testing.T) {
+			t.Setenv(disabledEnvKey, tc.value)
+
+			cfg := newConfig(WithAttributes(semconv.DBSystemMySQL), WithSQLCommenter(true))
+			assert.Equal(t, tc.disabled, cfg.disabled)
+			if tc.disabled {
+				assert.Empty(t, cfg.Attributes)
+				assert.False(t, cfg.SQLCommenterEnabled)
+				assert.IsType(t, tracenoop.NewTracerProvider(), cfg.TracerProvider)
+				assert.IsType(t, metricnoop.NewMeterProvider(), cfg.MeterProvider)
+			} else {
+				assert.Equal(t, []attribute.KeyValue{semconv.DBSystemMySQL}, cfg.Attributes)
+			}
+		})
+	}
+}
@@ -19,6 +19,7 @@ import (
 	"database/sql"
 	"database/sql/driver"
 	"errors"
+	"time"
 
 	"go.opentelemetry.io/otel/trace"
 )
@@ -39,6 +40,34 @@ var (
 type otConn struct {
 	driver.Conn
 	cfg config
+
+	// applicationName is the last application_name set on the session by
+	// propagateApplicationName.
+	applicationName string
+
+	// inTx reports whether a transaction is ongoing on the connection.
+	inTx bool
+	// txAborted reports whether setting the application_name failed in the
+	// ongoing transaction, which PostgreSQL then rejects all statements of
+	// until it ends.
+	txAborted bool
+
+	// fallbackQuery is the query for which the connection last returned
+	// driver.ErrSkip, see markPrepareFallback.
+	fallbackQuery *string
+
+	// tx is the ongoing transaction of the connection, if
+	// SpanOptions.RecordTransactionID or SpanOptions.RecordTransactionSummary
+	// is set.
+	tx *txInfo
+
+	// namespace is the database or schema the session switched to with a
+	// USE or SET search_path statement, see trackNamespaceDeferred.
+	namespace string
+	// namespaceBeforeTx is the namespace before the first SET statement
+	// switching it in the ongoing transaction, restored if the transaction
+	// is rolled back. It is nil if no SET statement switched it.
+	namespaceBeforeTx *string
 }
 
 func newConn(conn driver.Conn, cfg config) *otConn {
@@ -59,7 +88,9 @@ func (c *otConn) Ping(ctx context.Context) (err error) {
 	onDefer := recordMetric(ctx, c.cfg.Instruments, c.cfg, method, "", nil)
 	defer func() {
 		onDefer(err)
+		err = wrapError(ctx, c.cfg, err)
 	}()
+	defer setPprofLabels(ctx, pprofLabels(c.cfg, method, ""))()
 
 	if c.cfg.SpanOptions.Ping {
 		if filterSpan(ctx, c.cfg.SpanOptions, method, "", nil) {
@@ -67,7 +98,7 @@ func (c *otConn) Ping(ctx context.Context) (err error) {
 			ctx, span = createSpan(ctx, c.cfg, method, false, "", nil)
 			defer func() {
 				if err != nil {
-					recordSpanError(span, c.cfg.SpanOptions, err)
+					recordSpanError(ctx, span, c.cfg, method, "", err)
 				}
 				span.End()
 			}()
@@ -91,24 +122,41 @@ func (c *otConn) ExecContext(
 ) (res driver.Result, err error) {
 	execer, ok := c.Conn.(driver.ExecerContext)
 	if !ok {
+		c.markPrepareFallback(query)
 		return nil, driver.ErrSkip
 	}
-
-	method := MethodConnExec
-	onDefer := recordMetric(ctx, c.cfg.Instruments, c.cfg, method, query, args)
+	ctx = contextWithTxID(ctx, c.txID())
+	ctx = contextWithNamespace(ctx, c.currentNamespace())
+	defer c.recordTxStatementDeferred(&res, &err)
+	defer c.trackNamespaceDeferred(query, &err)
+
+	method := execMethod(c.cfg, MethodConnExec, query)
+	telemetryArgs := redactArgs(c.cfg, args)
+	onDefer := recordMetric(ctx, c.cfg.Instruments, c.cfg, method, query, telemetryArgs)
 	defer func() {
 		onDefer(err)
+		err = wrapError(ctx, c.cfg, err)
 	}()
+	defer setPprofLabels(ctx, pprofLabels(c.cfg, method, query))()
+
+	c.propagateApplicationName(ctx)
 
 	var span trace.Span
-	if filterSpan(ctx, c.cfg.SpanOptions, method, query, args) {
-		ctx, span = createSpan(ctx, c.cfg, method, true, query, args)
+	if !c.cfg.SpanOptions.OmitConnExec && filterSpan(ctx, c.cfg.SpanOptions, method, query, telemetryArgs) {
+		ctx, span = createSpan(ctx, c.cfg, method, true, query, telemetryArgs)
 		defer span.End()
 	}
+	defer recordAuditLogDeferred(ctx, c.cfg, method, query, telemetryArgs, &res, &err)
 
-	res, err = execer.ExecContext(ctx, c.cfg.SQLCommenter.withComment(ctx, query), args)
+	commentedQuery := driverQuery(ctx, c.cfg, method, query)
+	res, err = execer.ExecContext(ctx, commentedQuery, args)
+	if err == driver.ErrSkip {
+		c.markPrepareFallback(query)
+	} else {
+		c.cfg.QueryRecorder.record(method, commentedQuery, telemetryArgs)
+	}
 	if err != nil {
-		recordSpanError(span, c.cfg.SpanOptions, err)
+		recordSpanError(ctx, span, c.cfg, method, query, err)
 		return nil, err
 	}
 	return res, nil
@@ -127,45 +175,86 @@ func (c *otConn) QueryContext(
 ) (rows driver.Rows, err error) {
 	queryer, ok := c.Conn.(driver.QueryerContext)
 	if !ok {
+		c.markPrepareFallback(query)
 		return nil, driver.ErrSkip
 	}
+	ctx = contextWithTxID(ctx, c.txID())
+	ctx = contextWithNamespace(ctx, c.currentNamespace())
+	defer c.recordTxStatementDeferred(nil, &err)
 
 	method := MethodConnQuery
-	onDefer := recordMetric(ctx, c.cfg.Instruments, c.cfg, method, query, args)
+	telemetryArgs := redactArgs(c.cfg, args)
+	onDefer := recordMetric(ctx, c.cfg.Instruments, c.cfg, method, query, telemetryArgs)
 	defer func() {
 		onDefer(err)
+		err = wrapError(ctx, c.cfg, err)
 	}()
+	defer setPprofLabels(ctx, pprofLabels(c.cfg, method, query))()
+
+	c.propagateApplicationName(ctx)
 
 	var span trace.Span
 	queryCtx := ctx
-	if !c.cfg.SpanOptions.OmitConnQuery && filterSpan(ctx, c.cfg.SpanOptions, method, query, args) {
-		queryCtx, span = createSpan(ctx, c.cfg, method, true, query, args)
-		defer span.End()
+	if !c.cfg.SpanOptions.OmitConnQuery && filterSpan(ctx, c.cfg.SpanOptions, method, query, telemetryArgs) {
+		queryCtx, span = createSpan(ctx, c.cfg, method, true, query, telemetryArgs)
+		if c.cfg.SpanOptions.MergeRowsIntoQuerySpan && !c.cfg.UnwrappedRows {
+			defer endSpanOnErrorDeferred(span, &err)
+		} else {
+			defer span.End()
+		}
 	}
 
-	rows, err = queryer.QueryContext(queryCtx, c.cfg.SQLCommenter.withComment(queryCtx, query), args)
+	defer recordAuditLogDeferred(queryCtx, c.cfg, method, query, telemetryArgs, nil, &err)
+
+	commentedQuery := driverQuery(queryCtx, c.cfg, method, query)
+	rows, err = queryer.QueryContext(queryCtx, commentedQuery, args)
+	if err == driver.ErrSkip {
+		c.markPrepareFallback(query)
+	} else {
+		c.cfg.QueryRecorder.record(method, commentedQuery, telemetryArgs)
+	}
 	if err != nil {
-		recordSpanError(span, c.cfg.SpanOptions, err)
+		recordSpanError(ctx, span, c.cfg, method, query, err)
 		return nil, err
 	}
-	return newRows(ctx, rows, c.cfg), nil
+	if c.cfg.UnwrappedRows {
+		return rows, nil
+	}
+	otelRows := newRowsWithSpan(ctx, rows, c.cfg, span)
+	otelRows.pprofLabels = pprofLabels(c.cfg, MethodRows, query)
+	return wrapRows(otelRows), nil
 }
 
 func (c *otConn) PrepareContext(ctx context.Context, query string) (stmt driver.Stmt, err error) {
+	ctx = contextWithTxID(ctx, c.txID())
+	ctx = contextWithNamespace(ctx, c.currentNamespace())
 	method := MethodConnPrepare
 	onDefer := recordMetric(ctx, c.cfg.Instruments, c.cfg, method, query, nil)
 	defer func() {
 		onDefer(err)
+		err = wrapError(ctx, c.cfg, err)
 	}()
+	defer setPprofLabels(ctx, pprofLabels(c.cfg, method, query))()
 
+	fallback := c.takePrepareFallback(query)
+
+	var (
+		stmtID string
+		opts   []trace.SpanStartOption
+	)
+	if c.cfg.SpanOptions.RecordStatementID {
+		stmtID = newID()
+		opts = append(opts, trace.WithAttributes(dbPreparedStatementIDKey.String(stmtID)))
+	}
 	var span trace.Span
-	if !c.cfg.SpanOptions.OmitConnPrepare && filterSpan(ctx, c.cfg.SpanOptions, method, query, nil) {
-		ctx, span = createSpan(ctx, c.cfg, method, true, query, nil)
+	if !c.cfg.SpanOptions.OmitConnPrepare && !fallback && filterSpan(ctx, c.cfg.SpanOptions, method, query, nil) {
+		ctx, span = createSpan(ctx, c.cfg, method, true, query, nil, opts...)
 		defer span.End()
-		defer recordSpanErrorDeferred(span, c.cfg.SpanOptions, &err)
+		defer recordSpanErrorDeferred(ctx, span, c.cfg, method, query, &err)
 	}
 
-	commentedQuery := c.cfg.SQLCommenter.withComment(ctx, query)
+	commentedQuery := driverQuery(ctx, c.cfg, method, query)
+	c.cfg.QueryRecorder.record(method, commentedQuery, nil)
 
 	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
 		if stmt, err = preparer.PrepareContext(ctx, commentedQuery); err != nil {
@@ -184,14 +273,30 @@ func (c *otConn) PrepareContext(ctx context.Context, query string) (stmt driver.
 		}
 	}
 
-	return newStmt(stmt, c.cfg, query, c), nil
+	otelStmt := newStmt(stmt, c.cfg, query, c)
+	otelStmt.preparedQuery = commentedQuery
+	otelStmt.id = stmtID
+	otelStmt.prepareFallback = fallback
+	return otelStmt, nil
 }
 
 func (c *otConn) BeginTx(ctx context.Context, opts driver.TxOptions) (tx driver.Tx, err error) {
+	var info *txInfo
+	if c.cfg.SpanOptions.RecordTransactionID || c.cfg.SpanOptions.RecordTransactionSummary {
+		info = &txInfo{start: time.Now()}
+		if c.cfg.SpanOptions.RecordTransactionID {
+			info.id = newID()
+			ctx = contextWithTxID(ctx, info.id)
+		}
+	}
 	method := MethodConnBeginTx
-	onDefer := recordMetric(ctx, c.cfg.Instruments, c.cfg, method, "", nil)
+	onDefer := recordMetric(ctx, c.cfg.Instruments, c.cfg, method, "", nil,
+		txIsolationLevelKey.String(sql.IsolationLevel(opts.Isolation).String()),
+		txReadOnlyKey.Bool(opts.ReadOnly),
+	)
 	defer func() {
 		onDefer(err)
+		err = wrapError(ctx, c.cfg, err)
 	}()
 
 	var beginTxCtx context.Context
@@ -199,7 +304,7 @@ func (c *otConn) BeginTx(ctx context.Context, opts driver.TxOptions) (tx driver.
 		var span trace.Span
 		beginTxCtx, span = createSpan(ctx, c.cfg, method, false, "", nil)
 		defer span.End()
-		defer recordSpanErrorDeferred(span, c.cfg.SpanOptions, &err)
+		defer recordSpanErrorDeferred(ctx, span, c.cfg, method, "", &err)
 	} else {
 		beginTxCtx = ctx
 	}
@@ -235,7 +340,53 @@ func (c *otConn) BeginTx(ctx context.Context, opts driver.TxOptions) (tx driver.
 			}
 		}
 	}
-	return newTx(ctx, tx, c.cfg), nil
+	otelTx := newTx(endAPICallScope(ctx), tx, c.cfg)
+	c.tx = info
+	c.inTx = true
+	otelTx.info = info
+	otelTx.onEnd = c.endTx
+	return otelTx, nil
+}
+
+// endTx is called when the transaction of the connection ends, committed
+// or not.
+func (c *otConn) endTx(committed bool) {
+	c.tx = nil
+	c.inTx = false
+	c.txAborted = false
+	if !committed {
+		// The application_name and search_path set in the transaction are
+		// rolled back.
+		c.applicationName = ""
+		if c.namespaceBeforeTx != nil {
+			c.namespace = *c.namespaceBeforeTx
+		}
+	}
+	c.namespaceBeforeTx = nil
+}
+
+// txID returns the ID of the ongoing transaction of the connection, if any.
+func (c *otConn) txID() string {
+	if c == nil || c.tx == nil {
+		return ""
+	}
+	return c.tx.id
+}
+
+// recordTxStatementDeferred counts a statement executed in the ongoing
+// transaction of the connection and the rows it affected, see
+// SpanOptions.RecordTransactionSummary. res is nil for queries.
+func (c *otConn) recordTxStatementDeferred(res *driver.Result, err *error) {
+	if c == nil || c.tx == nil || !c.cfg.SpanOptions.RecordTransactionSummary {
+		return
+	}
+	c.tx.statements++
+	if res == nil || *res == nil || *err != nil {
+		return
+	}
+	if n, err := (*res).RowsAffected(); err == nil {
+		c.tx.rowsAffected += n
+	}
 }
 
 func (c *otConn) ResetSession(ctx context.Context) (err error) {
@@ -249,19 +400,25 @@ func (c *otConn) ResetSession(ctx context.Context) (err error) {
 	onDefer := recordMetric(ctx, c.cfg.Instruments, c.cfg, method, "", nil)
 	defer func() {
 		onDefer(err)
+		err = wrapError(ctx, c.cfg, err)
 	}()
 
 	var span trace.Span
 	if !c.cfg.SpanOptions.OmitConnResetSession && filterSpan(ctx, c.cfg.SpanOptions, method, "", nil) {
-		ctx, span = createSpan(ctx, c.cfg, method, false, "", nil)
-		defer span.End()
+		if c.cfg.SpanOptions.CompactSpans {
+			defer recordMethodEventDeferred(ctx, c.cfg.SpanOptions, method, time.Now(), &err)
+		} else {
+			ctx, span = createSpan(ctx, c.cfg, method, false, "", nil)
+			defer span.End()
+		}
 	}
 
 	err = sessionResetter.ResetSession(ctx)
 	if err != nil {
-		recordSpanError(span, c.cfg.SpanOptions, err)
+		recordSpanError(ctx, span, c.cfg, method, "", err)
 		return err
 	}
+	c.propagateSessionContext(ctx)
 	return nil
 }
 
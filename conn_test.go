@@ -16,6 +16,7 @@ package otelsql
 
 import (
 	"context"
+	"database/sql"
 	"database/sql/driver"
 	"errors"
 	"testing"
@@ -23,8 +24,10 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 	semconv "go.opentelemetry.io/otel/semconv/v1.18.0"
 	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
 )
 
 type MockConn interface {
@@ -302,57 +305,70 @@ func TestOtConn_ExecContext(t *testing.T) {
 			attrs:            expectedAttrs,
 		},
 	}
-	for _, spanFilterFn := range []SpanFilter{nil, omit, keep} {
-		testname := "spanFilterOmit"
-		if spanFilterFn == nil {
-			testname = "spanFilterNil"
-		} else if spanFilterFn(nil, "", "", []driver.NamedValue{}) {
-			testname = "spanFilterKeep"
+	for _, omitConnExec := range []bool{true, false} {
+		var testname string
+		if omitConnExec {
+			testname = "OmitConnExec"
 		}
 
 		t.Run(testname, func(t *testing.T) {
-			for _, tc := range testCases {
-				t.Run(tc.name, func(t *testing.T) {
-					// Prepare traces
-					ctx, sr, tracer, dummySpan := prepareTraces(tc.noParentSpan)
+			for _, spanFilterFn := range []SpanFilter{nil, omit, keep} {
+				testname := "spanFilterOmit"
+				if spanFilterFn == nil {
+					testname = "spanFilterNil"
+				} else if spanFilterFn(nil, "", "", []driver.NamedValue{}) {
+					testname = "spanFilterKeep"
+				}
 
-					// New conn
-					cfg := newMockConfig(t, tracer)
-					cfg.SpanOptions.DisableQuery = tc.disableQuery
-					cfg.SpanOptions.SpanFilter = spanFilterFn
-					cfg.AttributesGetter = tc.attributesGetter
-					cfg.InstrumentAttributesGetter = InstrumentAttributesGetter(tc.attributesGetter)
-					mc := newMockConn(tc.error)
-					otelConn := newConn(mc, cfg)
+				t.Run(testname, func(t *testing.T) {
+					for _, tc := range testCases {
+						t.Run(tc.name, func(t *testing.T) {
+							// Prepare traces
+							ctx, sr, tracer, dummySpan := prepareTraces(tc.noParentSpan)
 
-					_, err := otelConn.ExecContext(ctx, query, args)
-					if tc.error {
-						require.Error(t, err)
-					} else {
-						require.NoError(t, err)
-					}
+							// New conn
+							cfg := newMockConfig(t, tracer)
+							cfg.SpanOptions.DisableQuery = tc.disableQuery
+							cfg.SpanOptions.OmitConnExec = omitConnExec
+							cfg.SpanOptions.SpanFilter = spanFilterFn
+							cfg.AttributesGetter = tc.attributesGetter
+							cfg.InstrumentAttributesGetter = InstrumentAttributesGetter(tc.attributesGetter)
+							mc := newMockConn(tc.error)
+							otelConn := newConn(mc, cfg)
 
-					spanList := sr.Ended()
-					omit := !filterSpan(ctx, cfg.SpanOptions, MethodConnExec, query, args)
-					expectedSpanCount := getExpectedSpanCount(tc.noParentSpan, omit)
-					// One dummy span and one span created in ExecContext
-					require.Equal(t, expectedSpanCount, len(spanList))
-
-					assertSpanList(t, spanList, spanAssertionParameter{
-						parentSpan:         dummySpan,
-						error:              tc.error,
-						expectedAttributes: append(cfg.Attributes, tc.attrs...),
-						method:             MethodConnExec,
-						omitSpan:           omit,
-						noParentSpan:       tc.noParentSpan,
-						ctx:                mc.execContextCtx,
-						attributesGetter:   tc.attributesGetter,
-						query:              query,
-						args:               args,
-					})
-
-					assert.Equal(t, 1, mc.execContextCount)
-					assert.Equal(t, "query", mc.execContextQuery)
+							_, err := otelConn.ExecContext(ctx, query, args)
+							if tc.error {
+								require.Error(t, err)
+							} else {
+								require.NoError(t, err)
+							}
+
+							spanList := sr.Ended()
+							omit := omitConnExec
+							if !omit {
+								omit = !filterSpan(ctx, cfg.SpanOptions, MethodConnExec, query, args)
+							}
+							expectedSpanCount := getExpectedSpanCount(tc.noParentSpan, omit)
+							// One dummy span and one span created in ExecContext
+							require.Equal(t, expectedSpanCount, len(spanList))
+
+							assertSpanList(t, spanList, spanAssertionParameter{
+								parentSpan:         dummySpan,
+								error:              tc.error,
+								expectedAttributes: append(cfg.Attributes, tc.attrs...),
+								method:             MethodConnExec,
+								omitSpan:           omit,
+								noParentSpan:       tc.noParentSpan,
+								ctx:                mc.execContextCtx,
+								attributesGetter:   tc.attributesGetter,
+								query:              query,
+								args:               args,
+							})
+
+							assert.Equal(t, 1, mc.execContextCount)
+							assert.Equal(t, "query", mc.execContextQuery)
+						})
+					}
 				})
 			}
 		})
@@ -486,6 +502,26 @@ func TestOtConn_QueryContext(t *testing.T) {
 	}
 }
 
+func TestOtConn_BeginTxMetricAttributes(t *testing.T) {
+	mockLatency := &float64HistogramMock{}
+	cfg := newMockConfig(t, noop.NewTracerProvider().Tracer("test"))
+	cfg.Instruments = &instruments{latency: mockLatency}
+	otelConn := newConn(newMockConn(false), cfg)
+
+	_, err := otelConn.BeginTx(context.Background(), driver.TxOptions{
+		Isolation: driver.IsolationLevel(sql.LevelSerializable),
+		ReadOnly:  true,
+	})
+	require.NoError(t, err)
+
+	isolationLevel, ok := mockLatency.attributes.Value("db.sql.tx.isolation_level")
+	require.True(t, ok)
+	assert.Equal(t, "Serializable", isolationLevel.AsString())
+	readOnly, ok := mockLatency.attributes.Value("db.sql.tx.read_only")
+	require.True(t, ok)
+	assert.True(t, readOnly.AsBool())
+}
+
 func TestOtConn_PrepareContext(t *testing.T) {
 	query := "query"
 	expectedAttrs := []attribute.KeyValue{semconv.DBStatementKey.String(query)}
@@ -808,3 +844,91 @@ func TestOtConn_Raw(t *testing.T) {
 
 	assert.Equal(t, raw, conn.Raw())
 }
+
+func TestOtConn_RecordErrorWithContext(t *testing.T) {
+	ctx, sr, tracer, _ := prepareTraces(true)
+	cfg := newMockConfig(t, tracer)
+	var gotMethod Method
+	var gotQuery string
+	cfg.SpanOptions.RecordErrorWithContext = func(_ context.Context, method Method, query string, _ error) bool {
+		gotMethod, gotQuery = method, query
+		return false
+	}
+
+	_, err := newConn(newMockConn(true), cfg).ExecContext(ctx, "UPDATE t SET a = 1", nil)
+	require.Error(t, err)
+
+	assert.Equal(t, MethodConnExec, gotMethod)
+	assert.Equal(t, "UPDATE t SET a = 1", gotQuery)
+	spanList := sr.Ended()
+	require.Len(t, spanList, 1)
+	assert.Equal(t, codes.Unset, spanList[0].Status().Code)
+}
+
+func TestOtConn_QueryTransformer(t *testing.T) {
+	ctx, sr, tracer, _ := prepareTraces(false)
+	cfg := newMockConfig(t, tracer)
+	cfg.QueryTransformer = func(_ context.Context, _ Method, query string) string {
+		return "/*+ MAX_EXECUTION_TIME(1000) */ " + query
+	}
+	mc := newMockConn(false)
+	conn := newConn(mc, cfg)
+
+	rows, err := conn.QueryContext(ctx, "SELECT 1", nil)
+	require.NoError(t, err)
+	require.NoError(t, rows.Close())
+	_, err = conn.ExecContext(ctx, "DELETE FROM t", nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, "/*+ MAX_EXECUTION_TIME(1000) */ SELECT 1", mc.queryContextQuery)
+	assert.Equal(t, "/*+ MAX_EXECUTION_TIME(1000) */ DELETE FROM t", mc.execContextQuery)
+	// Spans keep the original query.
+	for _, span := range sr.Ended() {
+		for _, attr := range span.Attributes() {
+			if attr.Key == semconv.DBStatementKey {
+				assert.NotContains(t, attr.Value.AsString(), "MAX_EXECUTION_TIME")
+			}
+		}
+	}
+}
+
+type mockConnWithArgs struct {
+	*mockConn
+	args []driver.NamedValue
+}
+
+func (m *mockConnWithArgs) ExecContext(_ context.Context, _ string, args []driver.NamedValue) (driver.Result, error) {
+	m.args = args
+	return driver.RowsAffected(1), nil
+}
+
+func TestOtConn_ArgsRedactor(t *testing.T) {
+	ctx, _, tracer, _ := prepareTraces(false)
+	cfg := newMockConfig(t, tracer)
+	cfg.ArgsRedactor = func(args []driver.NamedValue) []driver.NamedValue {
+		for i := range args {
+			args[i].Value = "***"
+		}
+		return args
+	}
+	var getterArgs, filterArgs []driver.NamedValue
+	cfg.AttributesGetter = func(_ context.Context, _ Method, _ string, args []driver.NamedValue) []attribute.KeyValue {
+		getterArgs = args
+		return nil
+	}
+	cfg.SpanOptions.SpanFilter = func(_ context.Context, _ Method, _ string, args []driver.NamedValue) bool {
+		filterArgs = args
+		return true
+	}
+	mc := &mockConnWithArgs{mockConn: newMockConn(false)}
+
+	args := []driver.NamedValue{{Ordinal: 1, Value: "jane@example.com"}}
+	_, err := newConn(mc, cfg).ExecContext(ctx, "UPDATE users SET email = ?", args)
+	require.NoError(t, err)
+
+	redacted := []driver.NamedValue{{Ordinal: 1, Value: "***"}}
+	assert.Equal(t, redacted, getterArgs)
+	assert.Equal(t, redacted, filterArgs)
+	// The driver gets the original arguments.
+	assert.Equal(t, []driver.NamedValue{{Ordinal: 1, Value: "jane@example.com"}}, mc.args)
+}
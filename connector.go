@@ -17,8 +17,12 @@ package otelsql
 import (
 	"context"
 	"database/sql/driver"
+	"errors"
 	"io"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.18.0"
 	"go.opentelemetry.io/otel/trace"
 )
 
@@ -29,6 +33,15 @@ type otConnector struct {
 	driver.Connector
 	otDriver *otDriver
 	cfg      config
+
+	// dsn is the data source name the connector was opened with, if known.
+	dsn string
+	// tlsAttributes are the TLS attributes parsed from dsn, set on
+	// sql.connector.connect spans.
+	tlsAttributes []attribute.KeyValue
+	// registration is the registration of the oldest call metric of the
+	// connector, unregistered on Close, if the connector owns it.
+	registration metric.Registration
 }
 
 func newConnector(connector driver.Connector, otDriver *otDriver) *otConnector {
@@ -41,30 +54,72 @@ func newConnector(connector driver.Connector, otDriver *otDriver) *otConnector {
 
 func (c *otConnector) Connect(ctx context.Context) (connection driver.Conn, err error) {
 	method := MethodConnectorConnect
-	onDefer := recordMetric(ctx, c.cfg.Instruments, c.cfg, method, "", nil)
+	var (
+		connectAttrs []attribute.KeyValue
+		sanitizedDSN string
+	)
+	if c.cfg.ConnectAttributesGetter != nil || c.cfg.SpanOptions.RecordConnectionString {
+		sanitizedDSN = sanitizeDSN(c.dsn)
+	}
+	if c.cfg.ConnectAttributesGetter != nil {
+		connectAttrs = c.cfg.ConnectAttributesGetter(ctx, sanitizedDSN, c.Connector)
+	}
+	onDefer := recordMetric(ctx, c.cfg.Instruments, c.cfg, method, "", nil, connectAttrs...)
 	defer func() {
 		onDefer(err)
+		err = wrapError(ctx, c.cfg, err)
 	}()
 
 	var span trace.Span
 	if !c.cfg.SpanOptions.OmitConnectorConnect && filterSpan(ctx, c.cfg.SpanOptions, method, "", nil) {
-		ctx, span = createSpan(ctx, c.cfg, method, false, "", nil)
+		opts := []trace.SpanStartOption{
+			trace.WithAttributes(connectAttrs...), trace.WithAttributes(c.tlsAttributes...),
+		}
+		if sanitizedDSN != "" && c.cfg.SpanOptions.RecordConnectionString {
+			opts = append(opts, trace.WithAttributes(semconv.DBConnectionString(sanitizedDSN)))
+		}
+		ctx, span = createSpan(ctx, c.cfg, method, false, "", nil, opts...)
 		defer span.End()
 	}
 
-	connection, err = c.Connector.Connect(ctx)
+	connector, err := c.sessionConnector(ctx)
+	if err != nil {
+		recordSpanError(ctx, span, c.cfg, method, "", err)
+		return nil, err
+	}
+
+	connection, err = connector.Connect(ctx)
 	if err != nil {
-		recordSpanError(span, c.cfg.SpanOptions, err)
+		if span != nil && errors.Is(err, driver.ErrBadConn) {
+			span.AddEvent(string(EventConnectRetry))
+		}
+		recordSpanError(ctx, span, c.cfg, method, "", err)
 		return nil, err
 	}
+	c.cfg.serverProbe.run(ctx, connection)
 	return newConn(connection, c.cfg), nil
 }
 
+// sessionConnector returns the connector used to open a new connection.
+// If MySQL connection attributes propagation is enabled, the connector is
+// opened again with a DSN carrying the span context of ctx.
+func (c *otConnector) sessionConnector(ctx context.Context) (driver.Connector, error) {
+	if c.cfg.MySQLConnectionAttributes == nil || c.dsn == "" {
+		return c.Connector, nil
+	}
+	driverContext, ok := c.otDriver.driver.(driver.DriverContext)
+	if !ok {
+		return c.Connector, nil
+	}
+	return driverContext.OpenConnector(withMySQLConnectionAttributes(ctx, c.dsn, c.cfg.MySQLConnectionAttributes))
+}
+
 func (c *otConnector) Driver() driver.Driver {
 	return c.otDriver
 }
 
 func (c *otConnector) Close() error {
+	unregister(c.registration)
 	// database/sql uses a type assertion to check if connectors implement io.Closer.
 	// The type assertion does not pass through to otConnector.Connector, so we explicitly implement it here.
 	if closer, ok := c.Connector.(io.Closer); ok {
@@ -77,6 +132,9 @@ func (c *otConnector) Close() error {
 type dsnConnector struct {
 	dsn    string
 	driver driver.Driver
+	// registration is the registration of the oldest call metric of the
+	// connector, unregistered on Close.
+	registration metric.Registration
 }
 
 func (t dsnConnector) Connect(_ context.Context) (driver.Conn, error) {
@@ -86,3 +144,8 @@ func (t dsnConnector) Connect(_ context.Context) (driver.Conn, error) {
 func (t dsnConnector) Driver() driver.Driver {
 	return t.driver
 }
+
+func (t dsnConnector) Close() error {
+	unregister(t.registration)
+	return nil
+}
@@ -0,0 +1,134 @@
+// Copyright Sam Xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otelsql
+
+import (
+	"database/sql/driver"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/XSAM/otelsql/semconvutil"
+)
+
+// AttributesFromConnector returns attributes derived from a connector that
+// can be passed to WithAttributes, like AttributesFromDSN does for data
+// source names, for use with OpenDB.
+//
+// It reports the server address, port, and database name of the connectors
+// of well-known drivers: github.com/jackc/pgx (stdlib.GetConnector and
+// connectors opened from a DSN), github.com/go-sql-driver/mysql
+// (mysql.NewConnector), and github.com/microsoft/go-mssqldb
+// (mssql.NewConnector). It returns nil for other connectors.
+func AttributesFromConnector(connector driver.Connector) []attribute.KeyValue {
+	switch c := connector.(type) {
+	case *otConnector:
+		if c.dsn != "" {
+			return AttributesFromDSN(c.dsn)
+		}
+		return AttributesFromConnector(c.Connector)
+	case dsnConnector:
+		return AttributesFromDSN(c.dsn)
+	}
+
+	v := reflect.ValueOf(connector)
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+	info, ok := connectorInfo(v.Type().PkgPath(), v.Type().Name(), v)
+	if !ok {
+		return nil
+	}
+	return info.attributes(semconvutil.StabilityFromEnv())
+}
+
+// connectorInfo reads the server and the database of the connector v, of the
+// type name in the package pkgPath, from its fields.
+func connectorInfo(pkgPath, name string, v reflect.Value) (dsnInfo, bool) {
+	switch {
+	case strings.HasPrefix(pkgPath, "github.com/jackc/pgx/") && strings.HasSuffix(pkgPath, "/stdlib"):
+		switch name {
+		case "connector":
+			// The pgx.ConnConfig embeds pgconn.Config.
+			return dsnInfo{
+				host:     stringField(v, "Host"),
+				port:     intField(v, "Port"),
+				database: stringField(v, "Database"),
+			}, true
+		case "driverConnector":
+			return parseDSN(stringField(v, "name")), true
+		}
+	case pkgPath == "github.com/go-sql-driver/mysql" && name == "connector":
+		cfg := v.FieldByName("cfg")
+		if cfg.Kind() != reflect.Pointer || cfg.IsNil() {
+			return dsnInfo{}, false
+		}
+		cfg = cfg.Elem()
+
+		info := dsnInfo{database: stringField(cfg, "DBName")}
+		addr := stringField(cfg, "Addr")
+		if host, port, err := net.SplitHostPort(addr); err == nil {
+			info.host = host
+			info.port, _ = strconv.Atoi(port)
+		} else if stringField(cfg, "Net") != "unix" {
+			info.host = addr
+		}
+		return info, true
+	case (pkgPath == "github.com/microsoft/go-mssqldb" || pkgPath == "github.com/denisenkom/go-mssqldb") &&
+		name == "Connector":
+		params := v.FieldByName("params")
+		if params.Kind() != reflect.Struct {
+			return dsnInfo{}, false
+		}
+		return dsnInfo{
+			host:     stringField(params, "Host"),
+			port:     intField(params, "Port"),
+			database: stringField(params, "Database"),
+		}, true
+	}
+	return dsnInfo{}, false
+}
+
+// stringField returns the string field name of the struct v, or an empty
+// string if there is none. Unexported fields can be read as well.
+func stringField(v reflect.Value, name string) string {
+	f := v.FieldByName(name)
+	if f.Kind() != reflect.String {
+		return ""
+	}
+	return f.String()
+}
+
+// intField returns the integer field name of the struct v, or 0 if there is
+// none.
+func intField(v reflect.Value, name string) int {
+	f := v.FieldByName(name)
+	switch f.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return int(f.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int(f.Uint())
+	}
+	return 0
+}
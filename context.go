@@ -0,0 +1,163 @@
+// Copyright Sam Xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otelsql
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var dbOperationBatchSizeKey = attribute.Key("db.operation.batch.size")
+
+// The common values of the db.role attribute, see WithDatabaseRole.
+const (
+	DatabaseRolePrimary = "primary"
+	DatabaseRoleReplica = "replica"
+)
+
+type batchSizeContextKey struct{}
+
+// ContextWithBatchSize returns a copy of ctx carrying the number of operations
+// batched into a single statement, e.g. the number of rows in a multi-row
+// INSERT. Spans and measurements of calls made with the returned context have
+// the db.operation.batch.size attribute.
+//
+// Following the semantic conventions, sizes less than 2 are not considered a
+// batch and are ignored.
+func ContextWithBatchSize(ctx context.Context, size int) context.Context {
+	return context.WithValue(ctx, batchSizeContextKey{}, size)
+}
+
+// ContextWithRetryDetection returns a copy of ctx that tracks the driver calls
+// made with it, so that the calls database/sql retries after a
+// driver.ErrBadConn have spans with the db.operation.retry attribute set to
+// true.
+//
+// The returned context is meant for a single call through the database/sql
+// API, as every driver call made after the first driver.ErrBadConn is
+// considered a retry. Calls made through DB already track their retries.
+func ContextWithRetryDetection(ctx context.Context) context.Context {
+	return context.WithValue(ctx, apiCallContextKey{}, &apiCall{start: time.Now(), parent: ctx})
+}
+
+type databaseRoleContextKey struct{}
+
+// ContextWithDatabaseRole returns a copy of ctx carrying role, which overrides
+// the db.role attribute set by WithDatabaseRole on the spans and measurements
+// of calls made with the returned context, e.g. for a read routed to the
+// primary after a write.
+func ContextWithDatabaseRole(ctx context.Context, role string) context.Context {
+	return context.WithValue(ctx, databaseRoleContextKey{}, role)
+}
+
+type shardIDContextKey struct{}
+
+// ContextWithShardID returns a copy of ctx carrying id, which overrides the
+// db.shard.id attribute set by WithShardID on the spans and measurements of
+// calls made with the returned context.
+func ContextWithShardID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, shardIDContextKey{}, id)
+}
+
+type withoutSQLCommentContextKey struct{}
+
+// ContextWithoutSQLComment returns a copy of ctx that disables the comments
+// injected by WithSQLCommenter for calls made with it, e.g. for hot or
+// cache-sensitive statements, even when the commenter is enabled.
+func ContextWithoutSQLComment(ctx context.Context) context.Context {
+	return context.WithValue(ctx, withoutSQLCommentContextKey{}, true)
+}
+
+// sqlCommentDisabled reports whether ctx was returned by
+// ContextWithoutSQLComment.
+func sqlCommentDisabled(ctx context.Context) bool {
+	disabled, _ := ctx.Value(withoutSQLCommentContextKey{}).(bool)
+	return disabled
+}
+
+type txIDContextKey struct{}
+
+// contextWithTxID returns a copy of ctx carrying id, the ID of the
+// transaction the calls made with it belong to, see
+// SpanOptions.RecordTransactionID.
+func contextWithTxID(ctx context.Context, id string) context.Context {
+	if id == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, txIDContextKey{}, id)
+}
+
+// txIDFromContext returns the transaction ID carried by ctx, if any.
+func txIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(txIDContextKey{}).(string)
+	return id
+}
+
+type attributesContextKey struct{}
+
+// contextWithAttributes returns a copy of ctx carrying attrs, which are set on
+// the spans and measurements of the calls made with it, see DB.WithOptions.
+func contextWithAttributes(ctx context.Context, attrs []attribute.KeyValue) context.Context {
+	if len(attrs) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, attributesContextKey{}, attrs)
+}
+
+type namespaceContextKey struct{}
+
+// contextWithNamespace returns a copy of ctx carrying name, the database or
+// schema the connection of the calls made with it switched to, see
+// otConn.trackNamespaceDeferred.
+func contextWithNamespace(ctx context.Context, name string) context.Context {
+	if name == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, namespaceContextKey{}, name)
+}
+
+// namespaceFromContext returns the namespace carried by ctx, if any.
+func namespaceFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+
+	name, _ := ctx.Value(namespaceContextKey{}).(string)
+	return name
+}
+
+// contextAttributes returns the attributes carried by ctx through the
+// ContextWith* functions.
+func contextAttributes(ctx context.Context) []attribute.KeyValue {
+	if ctx == nil {
+		return nil
+	}
+
+	attrs, _ := ctx.Value(attributesContextKey{}).([]attribute.KeyValue)
+	attrs = slices.Clip(attrs)
+	if size, ok := ctx.Value(batchSizeContextKey{}).(int); ok && size >= 2 {
+		attrs = append(attrs, dbOperationBatchSizeKey.Int(size))
+	}
+	if role, ok := ctx.Value(databaseRoleContextKey{}).(string); ok && role != "" {
+		attrs = append(attrs, dbRoleKey.String(role))
+	}
+	if id, ok := ctx.Value(shardIDContextKey{}).(string); ok && id != "" {
+		attrs = append(attrs, dbShardIDKey.String(id))
+	}
+	return attrs
+}
@@ -0,0 +1,167 @@
+// Copyright Sam Xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otelsql
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+func TestContextAttributes(t *testing.T) {
+	testCases := []struct {
+		name     string
+		ctx      context.Context
+		expected []attribute.KeyValue
+	}{
+		{
+			name: "nil context",
+		},
+		{
+			name: "empty context",
+			ctx:  context.Background(),
+		},
+		{
+			name:     "batch size",
+			ctx:      ContextWithBatchSize(context.Background(), 10),
+			expected: []attribute.KeyValue{dbOperationBatchSizeKey.Int(10)},
+		},
+		{
+			name: "batch size of a single operation",
+			ctx:  ContextWithBatchSize(context.Background(), 1),
+		},
+		{
+			name:     "database role",
+			ctx:      ContextWithDatabaseRole(context.Background(), DatabaseRolePrimary),
+			expected: []attribute.KeyValue{dbRoleKey.String("primary")},
+		},
+		{
+			name:     "shard ID",
+			ctx:      ContextWithShardID(context.Background(), "shard-2"),
+			expected: []attribute.KeyValue{dbShardIDKey.String("shard-2")},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, contextAttributes(tc.ctx))
+		})
+	}
+}
+
+func TestOtConn_ExecContextWithBatchSize(t *testing.T) {
+	ctx, sr, tracer, _ := prepareTraces(true)
+	mockLatency := &float64HistogramMock{}
+	cfg := newMockConfig(t, tracer)
+	cfg.Instruments = &instruments{latency: mockLatency}
+	otelConn := newConn(newMockConn(false), cfg)
+
+	_, err := otelConn.ExecContext(ContextWithBatchSize(ctx, 3), "INSERT INTO t VALUES (1), (2), (3)", nil)
+	require.NoError(t, err)
+
+	spanList := sr.Ended()
+	require.Len(t, spanList, 1)
+	assert.Contains(t, spanList[0].Attributes(), dbOperationBatchSizeKey.Int(3))
+
+	batchSize, ok := mockLatency.attributes.Value(dbOperationBatchSizeKey)
+	require.True(t, ok)
+	assert.Equal(t, int64(3), batchSize.AsInt64())
+}
+
+func TestOtConn_ExecContextWithDatabaseRole(t *testing.T) {
+	ctx, sr, tracer, _ := prepareTraces(true)
+	mockLatency := &float64HistogramMock{}
+	cfg := newMockConfig(t, tracer)
+	cfg.Attributes = append(cfg.Attributes, dbRoleKey.String(DatabaseRoleReplica))
+	cfg.Instruments = &instruments{latency: mockLatency}
+	otelConn := newConn(newMockConn(false), cfg)
+
+	_, err := otelConn.ExecContext(ContextWithDatabaseRole(ctx, DatabaseRolePrimary), "UPDATE t SET a = 1", nil)
+	require.NoError(t, err)
+
+	spanList := sr.Ended()
+	require.Len(t, spanList, 1)
+	assert.Contains(t, spanList[0].Attributes(), dbRoleKey.String("primary"))
+	assert.NotContains(t, spanList[0].Attributes(), dbRoleKey.String("replica"))
+
+	role, ok := mockLatency.attributes.Value(dbRoleKey)
+	require.True(t, ok)
+	assert.Equal(t, "primary", role.AsString())
+}
+
+func TestOtConn_QueryContextWithShardID(t *testing.T) {
+	ctx, sr, tracer, _ := prepareTraces(true)
+	mockLatency := &float64HistogramMock{}
+	cfg := newMockConfig(t, tracer)
+	cfg.Instruments = &instruments{latency: mockLatency}
+	otelConn := newConn(newMockConn(false), cfg)
+
+	rows, err := otelConn.QueryContext(ContextWithShardID(ctx, "shard-2"), "SELECT 1", nil)
+	require.NoError(t, err)
+	require.NoError(t, rows.Close())
+
+	spanList := sr.Ended()
+	require.NotEmpty(t, spanList)
+	for _, span := range spanList {
+		assert.Contains(t, span.Attributes(), dbShardIDKey.String("shard-2"))
+	}
+
+	id, ok := mockLatency.attributes.Value(dbShardIDKey)
+	require.True(t, ok)
+	assert.Equal(t, "shard-2", id.AsString())
+}
+
+func TestContextWithoutSQLComment(t *testing.T) {
+	ctx := newTestSpanContext(t)
+	c := &commenter{enabled: true, propagator: propagation.TraceContext{}}
+
+	assert.False(t, sqlCommentDisabled(ctx))
+	assert.NotEqual(t, "SELECT 1", c.withComment(ctx, "SELECT 1"))
+
+	ctx = ContextWithoutSQLComment(ctx)
+	assert.True(t, sqlCommentDisabled(ctx))
+	assert.Equal(t, "SELECT 1", c.withComment(ctx, "SELECT 1"))
+}
+
+type badConn struct {
+	*mockConn
+}
+
+func (badConn) ExecContext(context.Context, string, []driver.NamedValue) (driver.Result, error) {
+	return nil, driver.ErrBadConn
+}
+
+func TestContextWithRetryDetection(t *testing.T) {
+	ctx, sr, tracer, _ := prepareTraces(true)
+	cfg := newMockConfig(t, tracer)
+	ctx = ContextWithRetryDetection(ctx)
+
+	// database/sql retries the call on another connection after
+	// driver.ErrBadConn.
+	_, err := newConn(badConn{newMockConn(false)}, cfg).ExecContext(ctx, "query", nil)
+	require.ErrorIs(t, err, driver.ErrBadConn)
+	_, err = newConn(newMockConn(false), cfg).ExecContext(ctx, "query", nil)
+	require.NoError(t, err)
+
+	spanList := sr.Ended()
+	require.Len(t, spanList, 2)
+	assert.NotContains(t, spanList[0].Attributes(), dbOperationRetryKey.Bool(true))
+	assert.Contains(t, spanList[1].Attributes(), dbOperationRetryKey.Bool(true))
+}
@@ -0,0 +1,205 @@
+// Copyright Sam Xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otelsql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	connectionWaitTimeKey = attribute.Key("db.sql.connection.wait_time")
+	retriesKey            = attribute.Key("db.sql.retries")
+	dbOperationRetryKey   = attribute.Key("db.operation.retry")
+)
+
+// DB wraps a *sql.DB to create spans for the calls made through the
+// database/sql API, in addition to the spans created by the driver.
+//
+// API-level spans cover what the driver cannot see: the time spent waiting
+// for a connection from the pool and the retries database/sql makes on
+// driver.ErrBadConn. They are recorded as the db.sql.connection.wait_time (in
+// seconds) and db.sql.retries attributes when the *sql.DB is opened with
+// github.com/XSAM/otelsql, e.g. by Open or OpenDB.
+// The driver-level spans of retried calls have the db.operation.retry
+// attribute set to true. The commit and rollback of a transaction begun by
+// BeginTx are not part of its call.
+//
+// Methods that are not overridden are those of the embedded *sql.DB.
+//
+// Notice: This type is EXPERIMENTAL and may be changed or removed in a
+// later release.
+type DB struct {
+	*sql.DB
+	cfg     config
+	options []Option
+
+	// derived reports whether the DB was returned by WithOptions, whose
+	// attributes are passed down to the driver-level spans and measurements.
+	derived bool
+}
+
+// NewDB returns a DB creating API-level spans for db with the given options.
+func NewDB(db *sql.DB, options ...Option) *DB {
+	return &DB{DB: db, cfg: newConfig(options...), options: options}
+}
+
+// WithOptions returns a DB sharing the pool of db, with the options of db
+// overridden by options. The attributes of the returned DB are also set on
+// the driver-level spans and measurements of the calls made through it, so
+// that the parts of an application sharing a pool, e.g. different
+// repositories, can be told apart, except on the commit and rollback of
+// transactions:
+//
+//	reports := db.WithOptions(otelsql.WithAttributes(attribute.String("repository", "reports")))
+func (db *DB) WithOptions(options ...Option) *DB {
+	options = append(slices.Clip(db.options), options...)
+	return &DB{DB: db.DB, cfg: newConfig(options...), options: options, derived: true}
+}
+
+// QueryContext calls QueryContext of the underlying *sql.DB in a
+// sql.db.query span.
+func (db *DB) QueryContext(ctx context.Context, query string, args ...any) (rows *sql.Rows, err error) {
+	ctx, end := db.startCall(ctx, MethodDBQuery, query, args)
+	defer func() { end(err) }()
+
+	return db.DB.QueryContext(ctx, query, args...)
+}
+
+// ExecContext calls ExecContext of the underlying *sql.DB in a sql.db.exec
+// span.
+func (db *DB) ExecContext(ctx context.Context, query string, args ...any) (result sql.Result, err error) {
+	ctx, end := db.startCall(ctx, MethodDBExec, query, args)
+	defer func() { end(err) }()
+
+	return db.DB.ExecContext(ctx, query, args...)
+}
+
+// QueryRowContext calls QueryRowContext of the underlying *sql.DB in a
+// sql.db.query_row span.
+func (db *DB) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	ctx, end := db.startCall(ctx, MethodDBQueryRow, query, args)
+
+	row := db.DB.QueryRowContext(ctx, query, args...)
+	end(row.Err())
+	return row
+}
+
+// BeginTx calls BeginTx of the underlying *sql.DB in a sql.db.begin_tx span.
+func (db *DB) BeginTx(ctx context.Context, opts *sql.TxOptions) (tx *sql.Tx, err error) {
+	ctx, end := db.startCall(ctx, MethodDBBeginTx, "", nil)
+	defer func() { end(err) }()
+
+	return db.DB.BeginTx(ctx, opts)
+}
+
+// startCall starts the span of an API call and returns the context to pass to
+// the underlying *sql.DB and a func ending the span.
+func (db *DB) startCall(ctx context.Context, method Method, query string, args []any) (context.Context, func(error)) {
+	if noopBuild {
+		return ctx, func(error) {}
+	}
+	parent := ctx
+	if db.derived {
+		ctx = contextWithAttributes(ctx, db.cfg.Attributes)
+	}
+	namedArgs := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		namedArgs[i] = driver.NamedValue{Ordinal: i + 1, Value: arg}
+	}
+	namedArgs = redactArgs(db.cfg, namedArgs)
+	if !filterSpan(ctx, db.cfg.SpanOptions, method, query, namedArgs) {
+		return ctx, func(error) {}
+	}
+
+	ctx, span := createSpan(ctx, db.cfg, method, query != "", query, namedArgs)
+	call := &apiCall{start: time.Now(), parent: parent}
+	ctx = context.WithValue(ctx, apiCallContextKey{}, call)
+
+	return ctx, func(err error) {
+		if acquired := call.acquired.Load(); acquired != 0 {
+			span.SetAttributes(connectionWaitTimeKey.Float64(time.Duration(acquired - call.start.UnixNano()).Seconds()))
+		}
+		span.SetAttributes(retriesKey.Int64(call.retries.Load()))
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			recordSpanError(ctx, span, db.cfg, method, query, err)
+		}
+		span.End()
+	}
+}
+
+type apiCallContextKey struct{}
+
+// apiCall tracks what happens in the driver during a call made through DB.
+type apiCall struct {
+	start time.Time
+	// parent is the context the call was made with.
+	parent context.Context
+	// acquired is the time in Unix nanoseconds of the first driver call,
+	// which is made once database/sql has got a connection from the pool.
+	acquired atomic.Int64
+	// retries is the number of driver calls that failed with
+	// driver.ErrBadConn, which database/sql retries.
+	retries atomic.Int64
+}
+
+// observeDriverCall records a driver call made with ctx in the apiCall of ctx,
+// if any. It returns a func to call with the result of the driver call.
+func observeDriverCall(ctx context.Context) func(error) {
+	if ctx == nil {
+		return nil
+	}
+	call, ok := ctx.Value(apiCallContextKey{}).(*apiCall)
+	if !ok {
+		return nil
+	}
+
+	call.acquired.CompareAndSwap(0, time.Now().UnixNano())
+	return func(err error) {
+		if errors.Is(err, driver.ErrBadConn) {
+			call.retries.Add(1)
+		}
+	}
+}
+
+// endAPICallScope returns ctx without what the call made through DB, or
+// with ContextWithRetryDetection, adds to it: the API-level span, the
+// attributes of DB.WithOptions, and the retry detection. It is used by the
+// transactions begun by the call, which outlive it, so that their calls are
+// not considered part of it.
+func endAPICallScope(ctx context.Context) context.Context {
+	call, ok := ctx.Value(apiCallContextKey{}).(*apiCall)
+	if !ok {
+		return ctx
+	}
+	ctx = trace.ContextWithSpan(ctx, trace.SpanFromContext(call.parent))
+	ctx = context.WithValue(ctx, attributesContextKey{}, call.parent.Value(attributesContextKey{}))
+	return context.WithValue(ctx, apiCallContextKey{}, nil)
+}
+
+// isRetry reports whether a driver call made with ctx is a retry of a call
+// that failed with driver.ErrBadConn in the same apiCall.
+func isRetry(ctx context.Context) bool {
+	call, ok := ctx.Value(apiCallContextKey{}).(*apiCall)
+	return ok && call.retries.Load() > 0
+}
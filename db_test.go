@@ -0,0 +1,225 @@
+// Copyright Sam Xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !otelsql_noop
+
+package otelsql
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.18.0"
+)
+
+func newTestDB(t *testing.T) (*DB, *tracetest.SpanRecorder) {
+	connector, err := newMockDriver(false).OpenConnector("")
+	require.NoError(t, err)
+
+	sr := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+	db := NewDB(OpenDB(connector, WithTracerProvider(tp)), WithTracerProvider(tp))
+	t.Cleanup(func() { _ = db.Close() })
+	return db, sr
+}
+
+// findSpan returns the ended span named name.
+func findSpan(t *testing.T, spans []sdktrace.ReadOnlySpan, name string) sdktrace.ReadOnlySpan {
+	for _, span := range spans {
+		if span.Name() == name {
+			return span
+		}
+	}
+	require.Failf(t, "span not found", "name: %s", name)
+	return nil
+}
+
+func TestDB(t *testing.T) {
+	testCases := []struct {
+		name          string
+		call          func(db *DB) error
+		method        Method
+		expectedQuery string
+	}{
+		{
+			name: "QueryContext",
+			call: func(db *DB) error {
+				rows, err := db.QueryContext(context.Background(), "SELECT 1", 1)
+				if err == nil {
+					err = rows.Close()
+				}
+				return err
+			},
+			method:        MethodDBQuery,
+			expectedQuery: "SELECT 1",
+		},
+		{
+			name: "QueryRowContext",
+			call: func(db *DB) error {
+				return db.QueryRowContext(context.Background(), "SELECT 1").Err()
+			},
+			method:        MethodDBQueryRow,
+			expectedQuery: "SELECT 1",
+		},
+		{
+			name: "ExecContext",
+			call: func(db *DB) error {
+				_, err := db.ExecContext(context.Background(), "UPDATE t SET a = 1")
+				return err
+			},
+			method:        MethodDBExec,
+			expectedQuery: "UPDATE t SET a = 1",
+		},
+		{
+			name: "BeginTx",
+			call: func(db *DB) error {
+				tx, err := db.BeginTx(context.Background(), nil)
+				if err == nil {
+					err = tx.Commit()
+				}
+				return err
+			},
+			method: MethodDBBeginTx,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, sr := newTestDB(t)
+			require.NoError(t, tc.call(db))
+
+			spans := sr.Ended()
+			span := findSpan(t, spans, string(tc.method))
+			assert.Equal(t, codes.Unset, span.Status().Code)
+
+			attrs := attribute.NewSet(span.Attributes()...)
+			if tc.expectedQuery != "" {
+				query, _ := attrs.Value(semconv.DBStatementKey)
+				assert.Equal(t, tc.expectedQuery, query.AsString())
+			}
+			retries, ok := attrs.Value(retriesKey)
+			assert.True(t, ok)
+			assert.Equal(t, int64(0), retries.AsInt64())
+			waitTime, ok := attrs.Value(connectionWaitTimeKey)
+			assert.True(t, ok)
+			assert.GreaterOrEqual(t, waitTime.AsFloat64(), float64(0))
+
+			// Driver-level spans are children of the API-level span.
+			connect := findSpan(t, spans, string(MethodConnectorConnect))
+			assert.Equal(t, span.SpanContext().SpanID(), connect.Parent().SpanID())
+		})
+	}
+}
+
+func TestDB_WithOptions(t *testing.T) {
+	db, sr := newTestDB(t)
+	repository := attribute.String("repository", "reports")
+	reports := db.WithOptions(WithAttributes(repository))
+	assert.Same(t, db.DB, reports.DB)
+
+	_, err := reports.ExecContext(context.Background(), "UPDATE t SET a = 1")
+	require.NoError(t, err)
+	_, err = db.ExecContext(context.Background(), "UPDATE t SET a = 2")
+	require.NoError(t, err)
+
+	var withRepository []string
+	for _, span := range sr.Ended() {
+		attrs := attribute.NewSet(span.Attributes()...)
+		if attrs.HasValue(repository.Key) {
+			query, _ := attrs.Value(semconv.DBStatementKey)
+			withRepository = append(withRepository, span.Name()+": "+query.AsString())
+		}
+	}
+	// Both the API-level and the driver-level spans of the derived DB have
+	// its attributes, including the one of the connection it opened.
+	assert.ElementsMatch(t, []string{
+		"sql.db.exec: UPDATE t SET a = 1",
+		"sql.connector.connect: ",
+		"sql.conn.exec: UPDATE t SET a = 1",
+	}, withRepository)
+}
+
+func TestDB_BeginTxScope(t *testing.T) {
+	db, sr := newTestDB(t)
+	repository := attribute.String("repository", "reports")
+	reports := db.WithOptions(WithAttributes(repository))
+
+	tx, err := reports.BeginTx(context.Background(), nil)
+	require.NoError(t, err)
+	require.NoError(t, tx.Commit())
+
+	// The transaction outlives the API-level call that began it.
+	spans := sr.Ended()
+	beginTx := findSpan(t, spans, string(MethodDBBeginTx))
+	commit := findSpan(t, spans, string(MethodTxCommit))
+	assert.NotEqual(t, beginTx.SpanContext().SpanID(), commit.Parent().SpanID())
+	assert.NotContains(t, commit.Attributes(), repository)
+}
+
+func TestEndAPICallScope(t *testing.T) {
+	ctx, sr, tracer, _ := prepareTraces(false)
+	cfg := newMockConfig(t, tracer)
+	retryCtx := ContextWithRetryDetection(ctx)
+	observeDriverCall(retryCtx)(driver.ErrBadConn)
+	require.True(t, isRetry(retryCtx))
+
+	tx, err := newConn(newMockConn(false), cfg).BeginTx(retryCtx, driver.TxOptions{})
+	require.NoError(t, err)
+	require.NoError(t, tx.Commit())
+
+	commit := findSpan(t, sr.Ended(), string(MethodTxCommit))
+	assert.NotContains(t, commit.Attributes(), dbOperationRetryKey.Bool(true))
+	assert.False(t, isRetry(endAPICallScope(retryCtx)))
+	assert.Equal(t, ctx, endAPICallScope(ctx))
+}
+
+func TestObserveDriverCall(t *testing.T) {
+	assert.Nil(t, observeDriverCall(nil)) // nolint
+	assert.Nil(t, observeDriverCall(context.Background()))
+
+	call := &apiCall{}
+	ctx := context.WithValue(context.Background(), apiCallContextKey{}, call)
+
+	end := observeDriverCall(ctx)
+	acquired := call.acquired.Load()
+	assert.NotZero(t, acquired)
+	end(driver.ErrBadConn)
+
+	// Only the first driver call sets the acquisition time.
+	end = observeDriverCall(ctx)
+	assert.Equal(t, acquired, call.acquired.Load())
+	end(errors.New("other"))
+	observeDriverCall(ctx)(nil)
+
+	assert.Equal(t, int64(1), call.retries.Load())
+}
+
+func TestDB_Error(t *testing.T) {
+	db, sr := newTestDB(t)
+	require.NoError(t, db.Close())
+
+	_, err := db.ExecContext(context.Background(), "UPDATE t SET a = 1")
+	require.Error(t, err)
+
+	span := findSpan(t, sr.Ended(), string(MethodDBExec))
+	assert.Equal(t, codes.Error, span.Status().Code)
+}
@@ -14,7 +14,10 @@
 
 package otelsql
 
-import "database/sql/driver"
+import (
+	"context"
+	"database/sql/driver"
+)
 
 var (
 	_ driver.Driver        = (*otDriver)(nil)
@@ -39,11 +42,19 @@ func newOtDriver(dri driver.Driver, cfg config) *otDriver {
 }
 
 func (d *otDriver) Open(name string) (driver.Conn, error) {
+	cfg := d.cfg
+	if cfg.ServerAttributesFromDSN {
+		cfg = withServerAttributesFromDSN(cfg, name)
+	}
+	if d.cfg.MySQLConnectionAttributes != nil {
+		name = withMySQLConnectionAttributes(context.Background(), name, d.cfg.MySQLConnectionAttributes)
+	}
 	rawConn, err := d.driver.Open(name)
 	if err != nil {
 		return nil, err
 	}
-	return newConn(rawConn, d.cfg), nil
+	d.cfg.serverProbe.run(context.Background(), rawConn)
+	return newConn(rawConn, cfg), nil
 }
 
 func (d *otDriver) OpenConnector(name string) (driver.Connector, error) {
@@ -51,5 +62,11 @@ func (d *otDriver) OpenConnector(name string) (driver.Connector, error) {
 	if err != nil {
 		return nil, err
 	}
-	return newConnector(rawConnector, d), err
+	connector := newConnector(rawConnector, d)
+	connector.dsn = name
+	connector.tlsAttributes = parseDSN(name).tlsAttributes()
+	if connector.cfg.ServerAttributesFromDSN {
+		connector.cfg = withServerAttributesFromDSN(connector.cfg, name)
+	}
+	return connector, err
 }
@@ -143,3 +143,25 @@ func TestOtDriver_OpenConnector(t *testing.T) {
 		})
 	}
 }
+
+func TestOtDriver_ServerAttributesFromDSN(t *testing.T) {
+	dsn := "postgres://localhost:5432/orders"
+	expected := []attribute.KeyValue{
+		serverAddressKey.String("localhost"),
+		serverPortKey.Int(5432),
+		semconv.NetPeerName("localhost"),
+		semconv.NetPeerPort(5432),
+	}
+	otelDriver := newOtDriver(newMockDriver(false), config{ServerAttributesFromDSN: true})
+
+	conn, err := otelDriver.Open(dsn)
+	require.NoError(t, err)
+	assert.Equal(t, expected, conn.(*otConn).cfg.Attributes)
+
+	connector, err := otelDriver.OpenConnector(dsn)
+	require.NoError(t, err)
+	assert.Equal(t, expected, connector.(*otConnector).cfg.Attributes)
+
+	// The driver config is not modified.
+	assert.Empty(t, otelDriver.cfg.Attributes)
+}
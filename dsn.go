@@ -0,0 +1,519 @@
+// Copyright Sam Xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otelsql
+
+import (
+	"net"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.18.0"
+
+	"github.com/XSAM/otelsql/semconvutil"
+)
+
+var (
+	tlsModeKey     = attribute.Key("db.connection.tls.mode")
+	tlsRequiredKey = attribute.Key("db.connection.tls.required")
+
+	serverAddressKey = attribute.Key("server.address")
+	serverPortKey    = attribute.Key("server.port")
+)
+
+// urlDSNRegexp matches the scheme of URL DSNs, like postgres://.
+var urlDSNRegexp = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]*://`)
+
+// mysqlAddrRegexp matches the [protocol[(address)]] part of the DSN format
+// used by github.com/go-sql-driver/mysql:
+// [username[:password]@][protocol[(address)]]/dbname[?param1=value1&...].
+var mysqlAddrRegexp = regexp.MustCompile(`^([\w-]*)(?:\((.*)\))?$`)
+
+// libpqKeyRegexp matches the keys of libpq key/value DSNs.
+var libpqKeyRegexp = regexp.MustCompile(`^[a-zA-Z_]+$`)
+
+// dsnInfo holds the parts of a data source name that are relevant to telemetry.
+type dsnInfo struct {
+	host     string
+	port     int
+	database string
+	// params holds the remaining DSN parameters with lower-cased keys.
+	params map[string]string
+}
+
+// parseDSN extracts the server host, port, and database name from a data
+// source name. It understands URL DSNs (postgres://, sqlserver://, ...),
+// go-sql-driver/mysql DSNs, and key/value DSNs separated by spaces (libpq) or
+// semicolons (ADO.NET).
+//
+// Unknown formats yield a zero dsnInfo, parsing never fails.
+func parseDSN(dsn string) dsnInfo {
+	dsn = strings.TrimSpace(dsn)
+	if dsn == "" {
+		return dsnInfo{}
+	}
+	if urlDSNRegexp.MatchString(dsn) {
+		return parseURLDSN(dsn)
+	}
+	if m, ok := splitMySQLDSN(dsn); ok {
+		return m.info()
+	}
+	if pairs, _, ok := splitKeyValueDSN(dsn); ok {
+		return keyValueDSNInfo(pairs)
+	}
+	return dsnInfo{}
+}
+
+func parseURLDSN(dsn string) dsnInfo {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return dsnInfo{}
+	}
+
+	info := dsnInfo{
+		host:     u.Hostname(),
+		database: strings.TrimPrefix(u.Path, "/"),
+		params:   make(map[string]string),
+	}
+	info.port, _ = strconv.Atoi(u.Port())
+	for k, v := range u.Query() {
+		if len(v) > 0 {
+			info.params[strings.ToLower(k)] = v[0]
+		}
+	}
+	if info.database == "" {
+		// SQL Server URLs carry the database as a query parameter.
+		info.database = info.params["database"]
+	}
+	return info
+}
+
+// mysqlDSN holds the parts of a go-sql-driver/mysql DSN.
+type mysqlDSN struct {
+	// userinfo is the username[:password] part, and rest the DSN following
+	// it and its "@".
+	userinfo string
+	rest     string
+
+	net      string
+	addr     string
+	database string
+	params   string
+}
+
+// splitMySQLDSN splits dsn like github.com/go-sql-driver/mysql: the database
+// follows the last "/", and the credentials precede the last "@" before it,
+// as both the password and the address may contain a "/" or an "@". ok is
+// false if dsn does not have this format, or if it is ambiguous with a
+// key/value DSN.
+func splitMySQLDSN(dsn string) (m mysqlDSN, ok bool) {
+	slash := strings.LastIndex(dsn, "/")
+	if slash < 0 {
+		return mysqlDSN{}, false
+	}
+	m.database, m.params, _ = strings.Cut(dsn[slash+1:], "?")
+	if strings.ContainsAny(m.database, "= \t\r\n") {
+		return mysqlDSN{}, false
+	}
+
+	m.rest = dsn
+	addr := dsn[:slash]
+	if at := strings.LastIndex(addr, "@"); at >= 0 {
+		m.userinfo, m.rest, addr = dsn[:at], dsn[at+1:], addr[at+1:]
+		if user, _, _ := strings.Cut(m.userinfo, ":"); strings.ContainsAny(user, "= \t\r\n") {
+			return mysqlDSN{}, false
+		}
+	}
+	sub := mysqlAddrRegexp.FindStringSubmatch(addr)
+	if sub == nil {
+		return mysqlDSN{}, false
+	}
+	m.net, m.addr = sub[1], sub[2]
+	return m, true
+}
+
+// info returns the dsnInfo of m.
+func (m mysqlDSN) info() dsnInfo {
+	info := dsnInfo{
+		database: m.database,
+		params:   map[string]string{"net": m.net},
+	}
+
+	if host, port, err := net.SplitHostPort(m.addr); err == nil {
+		info.host = host
+		info.port, _ = strconv.Atoi(port)
+	} else if m.net != "unix" {
+		info.host = m.addr
+	}
+
+	if values, err := url.ParseQuery(m.params); err == nil {
+		for k, v := range values {
+			if len(v) > 0 {
+				info.params[strings.ToLower(k)] = v[0]
+			}
+		}
+	}
+	return info
+}
+
+// keyValuePair is a pair of a key/value DSN. text is the pair as written in
+// the DSN, value is unquoted.
+type keyValuePair struct {
+	key   string
+	value string
+	text  string
+}
+
+// splitKeyValueDSN splits a key/value DSN into its pairs and returns the
+// separator of the pairs: ";" for ADO.NET DSNs, and " " for libpq ones. ok is
+// false if dsn is not a well-formed key/value DSN.
+func splitKeyValueDSN(dsn string) (pairs []keyValuePair, sep string, ok bool) {
+	if strings.Contains(dsn, ";") {
+		if pairs, ok := splitADODSN(dsn); ok {
+			return pairs, ";", true
+		}
+	}
+	pairs, ok = splitLibpqDSN(dsn)
+	return pairs, " ", ok
+}
+
+// splitLibpqDSN splits a libpq DSN, whose values are quoted with single
+// quotes if they contain whitespace, and escaped with backslashes.
+func splitLibpqDSN(dsn string) ([]keyValuePair, bool) {
+	var pairs []keyValuePair
+	i := 0
+	skipSpaces := func() {
+		for i < len(dsn) && isSpace(dsn[i]) {
+			i++
+		}
+	}
+	for {
+		skipSpaces()
+		if i == len(dsn) {
+			return pairs, len(pairs) > 0
+		}
+
+		start := i
+		for i < len(dsn) && dsn[i] != '=' && !isSpace(dsn[i]) {
+			i++
+		}
+		key := dsn[start:i]
+		skipSpaces()
+		if i == len(dsn) || dsn[i] != '=' || !libpqKeyRegexp.MatchString(key) {
+			return nil, false
+		}
+		i++
+		skipSpaces()
+
+		var value strings.Builder
+		if i < len(dsn) && dsn[i] == '\'' {
+			for i++; ; i++ {
+				if i == len(dsn) {
+					// Unterminated quoted value.
+					return nil, false
+				}
+				if dsn[i] == '\'' {
+					i++
+					break
+				}
+				if dsn[i] == '\\' && i+1 < len(dsn) {
+					i++
+				}
+				value.WriteByte(dsn[i])
+			}
+			if i < len(dsn) && !isSpace(dsn[i]) {
+				return nil, false
+			}
+		} else {
+			for ; i < len(dsn) && !isSpace(dsn[i]); i++ {
+				if dsn[i] == '\\' && i+1 < len(dsn) {
+					i++
+				}
+				value.WriteByte(dsn[i])
+			}
+		}
+		pairs = append(pairs, keyValuePair{key: key, value: value.String(), text: dsn[start:i]})
+	}
+}
+
+// splitADODSN splits an ADO.NET DSN, whose values are quoted with single or
+// double quotes if they contain a semicolon, and escaped by doubling the
+// quote. Unquoted values containing "=" are rejected, as they are ambiguous
+// with libpq DSNs.
+func splitADODSN(dsn string) ([]keyValuePair, bool) {
+	var pairs []keyValuePair
+	for i := 0; i < len(dsn); i++ {
+		start := i
+		eq := strings.IndexAny(dsn[i:], "=;")
+		if eq < 0 || dsn[i+eq] == ';' {
+			// Only empty segments are allowed without a key.
+			if eq < 0 {
+				eq = len(dsn) - i
+			}
+			if strings.TrimSpace(dsn[i:i+eq]) != "" {
+				return nil, false
+			}
+			i += eq
+			continue
+		}
+
+		key := strings.TrimSpace(dsn[i : i+eq])
+		if key == "" {
+			return nil, false
+		}
+		i += eq + 1
+		for i < len(dsn) && isSpace(dsn[i]) {
+			i++
+		}
+
+		var value string
+		if i < len(dsn) && (dsn[i] == '"' || dsn[i] == '\'') {
+			quote := dsn[i]
+			var b strings.Builder
+			for i++; ; i++ {
+				if i == len(dsn) {
+					// Unterminated quoted value.
+					return nil, false
+				}
+				if dsn[i] == quote {
+					if i+1 < len(dsn) && dsn[i+1] == quote {
+						i++
+					} else {
+						i++
+						break
+					}
+				}
+				b.WriteByte(dsn[i])
+			}
+			value = b.String()
+			for i < len(dsn) && isSpace(dsn[i]) {
+				i++
+			}
+			if i < len(dsn) && dsn[i] != ';' {
+				return nil, false
+			}
+		} else {
+			end := strings.IndexByte(dsn[i:], ';')
+			if end < 0 {
+				end = len(dsn) - i
+			}
+			value = strings.TrimSpace(dsn[i : i+end])
+			if strings.Contains(value, "=") {
+				return nil, false
+			}
+			i += end
+		}
+		pairs = append(pairs, keyValuePair{key: key, value: value, text: strings.TrimSpace(dsn[start:i])})
+	}
+	return pairs, len(pairs) > 0
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == '\v'
+}
+
+func keyValueDSNInfo(pairs []keyValuePair) dsnInfo {
+	info := dsnInfo{params: make(map[string]string)}
+	for _, pair := range pairs {
+		k := strings.ToLower(pair.key)
+		v := pair.value
+
+		switch k {
+		case "host", "server", "data source", "address", "addr":
+			info.host, info.port = splitKeyValueHost(v, info.port)
+		case "port":
+			info.port, _ = strconv.Atoi(v)
+		case "dbname", "database", "initial catalog":
+			info.database = v
+		default:
+			info.params[k] = v
+		}
+	}
+	return info
+}
+
+// splitKeyValueHost splits an ADO.NET style server value such as
+// "tcp:host,1433" or "host\instance" into its host and port.
+func splitKeyValueHost(v string, port int) (string, int) {
+	v = strings.TrimPrefix(v, "tcp:")
+	if host, p, ok := strings.Cut(v, ","); ok {
+		v = host
+		port, _ = strconv.Atoi(strings.TrimSpace(p))
+	}
+	if host, _, ok := strings.Cut(v, `\`); ok {
+		v = host
+	}
+	return v, port
+}
+
+// sanitizeDSN returns dsn without its password, parsed like parseDSN. DSNs
+// of unknown formats, or that cannot be parsed unambiguously, yield an empty
+// string, as their password cannot be removed.
+func sanitizeDSN(dsn string) string {
+	trimmed := strings.TrimSpace(dsn)
+	if trimmed == "" {
+		return ""
+	}
+
+	if urlDSNRegexp.MatchString(trimmed) {
+		u, err := url.Parse(trimmed)
+		if err != nil || u.Opaque != "" {
+			return ""
+		}
+		if u.User != nil {
+			u.User = url.User(u.User.Username())
+		} else if authority, _, _ := strings.Cut(trimmed[len(u.Scheme)+3:], "?"); strings.Contains(authority, "@") {
+			// The credentials were not parsed, e.g. because the password
+			// contains a "/".
+			return ""
+		}
+		q := u.Query()
+		for key := range q {
+			if isPasswordKey(key) {
+				q.Del(key)
+			}
+		}
+		u.RawQuery = q.Encode()
+		return u.String()
+	}
+
+	if m, ok := splitMySQLDSN(trimmed); ok {
+		if m.userinfo == "" {
+			return trimmed
+		}
+		user, _, _ := strings.Cut(m.userinfo, ":")
+		return user + "@" + m.rest
+	}
+
+	if pairs, sep, ok := splitKeyValueDSN(trimmed); ok {
+		kept := make([]string, 0, len(pairs))
+		for _, pair := range pairs {
+			if !isPasswordKey(pair.key) {
+				kept = append(kept, pair.text)
+			}
+		}
+		return strings.Join(kept, sep)
+	}
+	return ""
+}
+
+// isPasswordKey reports whether key is the key of a password DSN parameter.
+func isPasswordKey(key string) bool {
+	switch strings.ToLower(strings.TrimSpace(key)) {
+	case "password", "pwd", "passwd", "sslpassword":
+		return true
+	}
+	return false
+}
+
+// withServerAttributesFromDSN returns cfg with the server address and port
+// parsed from dsn added to its attributes, unless they are already set, e.g.
+// by AttributesFromDSN.
+func withServerAttributesFromDSN(cfg config, dsn string) config {
+	info := parseDSN(dsn)
+
+	host, port := info.host, info.port
+	if hasAttribute(cfg.Attributes, semconv.NetPeerNameKey) || hasAttribute(cfg.Attributes, serverAddressKey) {
+		host = ""
+	}
+	if hasAttribute(cfg.Attributes, semconv.NetPeerPortKey) || hasAttribute(cfg.Attributes, serverPortKey) {
+		port = 0
+	}
+	cfg.Attributes = append(slices.Clip(cfg.Attributes), semconvutil.ServerAttributes(host, port, cfg.semconvStability)...)
+	return cfg
+}
+
+// AttributesFromDSN returns attributes derived from a data source name that
+// can be passed to WithAttributes.
+//
+// It reports the server address, port, and database name when they can be
+// parsed, following the semantic conventions opted in with
+// OTEL_SEMCONV_STABILITY_OPT_IN, and enriches them with cloud attributes for
+// well-known managed database endpoints such as AWS RDS, Aurora, GCP Cloud
+// SQL, and Azure SQL. Credentials in the DSN are never included.
+func AttributesFromDSN(dsn string) []attribute.KeyValue {
+	return parseDSN(dsn).attributes(semconvutil.StabilityFromEnv())
+}
+
+// attributes returns the attributes of the server and the database of info
+// following the given semantic conventions.
+func (info dsnInfo) attributes(stability semconvutil.Stability) []attribute.KeyValue {
+	attrs := semconvutil.ServerAttributes(info.host, info.port, stability)
+	if info.database != "" {
+		attrs = append(attrs, semconvutil.DBNamespaceAttributes(info.database, stability)...)
+	}
+	return append(attrs, cloudAttributes(info)...)
+}
+
+// tlsParams are the DSN parameters setting whether connections are
+// encrypted: sslmode for PostgreSQL, tls for go-sql-driver/mysql, and encrypt
+// for SQL Server.
+var tlsParams = []string{"sslmode", "tls", "encrypt"}
+
+// tlsAttributes returns the TLS mode set by the parameters of info, and
+// whether the mode requires connections to be encrypted when it is known.
+// It returns nil if the DSN does not set the TLS mode, as the default depends
+// on the driver.
+func (info dsnInfo) tlsAttributes() []attribute.KeyValue {
+	for _, param := range tlsParams {
+		mode, ok := info.params[param]
+		if !ok || mode == "" {
+			continue
+		}
+		mode = strings.ToLower(mode)
+
+		attrs := []attribute.KeyValue{tlsModeKey.String(mode)}
+		if required, ok := tlsRequired(param, mode); ok {
+			attrs = append(attrs, tlsRequiredKey.Bool(required))
+		}
+		return attrs
+	}
+	return nil
+}
+
+// tlsRequired reports whether mode, the value of the DSN parameter param,
+// requires connections to be encrypted. The second result is false if mode
+// is not a well-known value.
+func tlsRequired(param, mode string) (required, ok bool) {
+	switch param {
+	case "sslmode":
+		switch mode {
+		case "require", "verify-ca", "verify-full":
+			return true, true
+		case "disable", "allow", "prefer":
+			return false, true
+		}
+		return false, false
+	case "tls":
+		switch mode {
+		case "false", "preferred":
+			return false, true
+		}
+		// Other values are true, skip-verify, or the name of a custom TLS
+		// config registered with mysql.RegisterTLSConfig.
+		return true, true
+	case "encrypt":
+		switch mode {
+		case "true", "strict", "mandatory", "yes":
+			return true, true
+		case "false", "disable", "optional", "no":
+			return false, true
+		}
+	}
+	return false, false
+}
@@ -0,0 +1,162 @@
+// Copyright Sam Xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otelsql
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"reflect"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var dbErrorCategoryKey = attribute.Key("db.error.category")
+
+// The categories of db.error.category, for errors that are worth retrying as
+// they come from conflicts with concurrent transactions.
+const (
+	errorCategoryDeadlock             = "deadlock"
+	errorCategorySerializationFailure = "serialization_failure"
+	errorCategoryLockTimeout          = "lock_timeout"
+)
+
+// tracedError is an error returned by the driver annotated with the span
+// context active when it was returned.
+type tracedError struct {
+	err         error
+	spanContext trace.SpanContext
+}
+
+func (e *tracedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *tracedError) Unwrap() error {
+	return e.err
+}
+
+// wrapError annotates err with the span context of ctx if error annotation
+// is enabled. Sentinel errors that database/sql compares directly, like
+// driver.ErrSkip and io.EOF, are returned as is.
+func wrapError(ctx context.Context, cfg config, err error) error {
+	if !cfg.ErrorTraceIDs || err == nil || err == driver.ErrSkip || err == io.EOF {
+		return err
+	}
+
+	var traced *tracedError
+	if errors.As(err, &traced) {
+		return err
+	}
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return err
+	}
+	return &tracedError{err: err, spanContext: sc}
+}
+
+// TraceIDFromError returns the trace ID of the span active when err, or an
+// error it wraps, was returned by github.com/XSAM/otelsql with
+// WithErrorTraceIDs enabled. The boolean is false if err carries no trace ID.
+func TraceIDFromError(err error) (trace.TraceID, bool) {
+	sc, ok := SpanContextFromError(err)
+	return sc.TraceID(), ok
+}
+
+// SpanContextFromError returns the span context active when err, or an error
+// it wraps, was returned by github.com/XSAM/otelsql with WithErrorTraceIDs
+// enabled. The boolean is false if err carries no span context.
+func SpanContextFromError(err error) (trace.SpanContext, bool) {
+	var traced *tracedError
+	if !errors.As(err, &traced) {
+		return trace.SpanContext{}, false
+	}
+	return traced.spanContext, true
+}
+
+// sqlStateError is implemented by the errors of github.com/jackc/pgx and
+// github.com/lib/pq.
+type sqlStateError interface {
+	SQLState() string
+}
+
+// sqlErrorNumberError is implemented by the errors of
+// github.com/microsoft/go-mssqldb.
+type sqlErrorNumberError interface {
+	SQLErrorNumber() int32
+}
+
+// errorCategory returns the category of err, or of an error it wraps, if it
+// is a deadlock, a serialization failure, or a lock timeout reported by a
+// well-known driver. It returns an empty string otherwise.
+func errorCategory(err error) string {
+	for ; err != nil; err = errors.Unwrap(err) {
+		if category := driverErrorCategory(err); category != "" {
+			return category
+		}
+	}
+	return ""
+}
+
+func driverErrorCategory(err error) string {
+	switch e := err.(type) {
+	case sqlErrorNumberError:
+		if e.SQLErrorNumber() == 1205 {
+			return errorCategoryDeadlock
+		}
+		return ""
+	case sqlStateError:
+		switch e.SQLState() {
+		case "40P01":
+			return errorCategoryDeadlock
+		case "40001":
+			return errorCategorySerializationFailure
+		case "55P03":
+			return errorCategoryLockTimeout
+		}
+		return ""
+	}
+
+	v := reflect.ValueOf(err)
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return ""
+	}
+	return errorNumberCategory(v.Type().PkgPath(), v.Type().Name(), v)
+}
+
+// errorNumberCategory returns the category of the error v of the type name
+// declared in pkgPath, from its error number. The error numbers of
+// github.com/go-sql-driver/mysql have no accessor, so they are read by
+// reflection.
+func errorNumberCategory(pkgPath, name string, v reflect.Value) string {
+	if pkgPath != "github.com/go-sql-driver/mysql" || name != "MySQLError" {
+		return ""
+	}
+	switch intField(v, "Number") {
+	case 1213:
+		return errorCategoryDeadlock
+	case 1205:
+		return errorCategoryLockTimeout
+	}
+	return ""
+}
@@ -0,0 +1,187 @@
+// Copyright Sam Xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otelsql
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestWrapError(t *testing.T) {
+	ctx := newTestSpanContext(t)
+	enabled := config{ErrorTraceIDs: true}
+	err := errors.New("boom")
+
+	testCases := []struct {
+		name        string
+		ctx         context.Context
+		cfg         config
+		err         error
+		expectTrace bool
+	}{
+		{name: "disabled", ctx: ctx, err: err},
+		{name: "nil error", ctx: ctx, cfg: enabled},
+		{name: "ErrSkip", ctx: ctx, cfg: enabled, err: driver.ErrSkip},
+		{name: "EOF", ctx: ctx, cfg: enabled, err: io.EOF},
+		{name: "no span context", ctx: context.Background(), cfg: enabled, err: err},
+		{name: "annotated", ctx: ctx, cfg: enabled, err: err, expectTrace: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			wrapped := wrapError(tc.ctx, tc.cfg, tc.err)
+			traceID, ok := TraceIDFromError(wrapped)
+			assert.Equal(t, tc.expectTrace, ok)
+			if !tc.expectTrace {
+				assert.Equal(t, tc.err, wrapped)
+				assert.False(t, traceID.IsValid())
+				return
+			}
+
+			assert.Equal(t, trace.SpanContextFromContext(ctx).TraceID(), traceID)
+			assert.ErrorIs(t, wrapped, err)
+			assert.EqualError(t, wrapped, "boom")
+		})
+	}
+}
+
+func TestWrapError_AlreadyAnnotated(t *testing.T) {
+	cfg := config{ErrorTraceIDs: true}
+	inner := wrapError(newTestSpanContext(t), cfg, errors.New("boom"))
+
+	other := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{1},
+		SpanID:  trace.SpanID{1},
+	}))
+	outer := wrapError(other, cfg, fmt.Errorf("wrapped: %w", inner))
+
+	// The innermost span context is kept.
+	sc, ok := SpanContextFromError(outer)
+	require.True(t, ok)
+	assert.Equal(t, trace.SpanContextFromContext(newTestSpanContext(t)), sc)
+}
+
+func TestOtConn_ExecContextWithErrorTraceIDs(t *testing.T) {
+	ctx, _, tracer, _ := prepareTraces(false)
+	cfg := newMockConfig(t, tracer)
+	cfg.ErrorTraceIDs = true
+
+	_, err := newConn(newMockConn(true), cfg).ExecContext(ctx, "UPDATE t SET a = 1", nil)
+	require.Error(t, err)
+
+	sc, ok := SpanContextFromError(err)
+	require.True(t, ok)
+	assert.Equal(t, trace.SpanContextFromContext(ctx).TraceID(), sc.TraceID())
+	// The span context is the one of the sql.conn.exec span.
+	assert.NotEqual(t, trace.SpanContextFromContext(ctx).SpanID(), sc.SpanID())
+}
+
+type mockSQLStateError string
+
+func (e mockSQLStateError) Error() string    { return "sqlstate " + string(e) }
+func (e mockSQLStateError) SQLState() string { return string(e) }
+
+type mockSQLErrorNumberError int32
+
+func (e mockSQLErrorNumberError) Error() string         { return fmt.Sprintf("mssql %d", e) }
+func (e mockSQLErrorNumberError) SQLErrorNumber() int32 { return int32(e) }
+
+type mysqlError struct {
+	Number uint16
+}
+
+func TestErrorCategory(t *testing.T) {
+	testCases := []struct {
+		name     string
+		err      error
+		expected string
+	}{
+		{name: "nil", err: nil},
+		{name: "unknown", err: errors.New("boom")},
+		{name: "postgres deadlock", err: mockSQLStateError("40P01"), expected: errorCategoryDeadlock},
+		{name: "postgres serialization failure", err: mockSQLStateError("40001"), expected: errorCategorySerializationFailure},
+		{name: "postgres lock not available", err: mockSQLStateError("55P03"), expected: errorCategoryLockTimeout},
+		{name: "postgres unique violation", err: mockSQLStateError("23505")},
+		{name: "mssql deadlock", err: mockSQLErrorNumberError(1205), expected: errorCategoryDeadlock},
+		{name: "mssql other", err: mockSQLErrorNumberError(2627)},
+		{name: "wrapped", err: fmt.Errorf("update: %w", mockSQLStateError("40P01")), expected: errorCategoryDeadlock},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, errorCategory(tc.err))
+		})
+	}
+}
+
+func TestErrorNumberCategory(t *testing.T) {
+	const mysqlPkgPath = "github.com/go-sql-driver/mysql"
+	testCases := []struct {
+		pkgPath  string
+		number   uint16
+		expected string
+	}{
+		{pkgPath: mysqlPkgPath, number: 1213, expected: errorCategoryDeadlock},
+		{pkgPath: mysqlPkgPath, number: 1205, expected: errorCategoryLockTimeout},
+		{pkgPath: mysqlPkgPath, number: 1062},
+		{pkgPath: "example.com/mysql", number: 1213},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%s %d", tc.pkgPath, tc.number), func(t *testing.T) {
+			v := reflect.ValueOf(mysqlError{Number: tc.number})
+			assert.Equal(t, tc.expected, errorNumberCategory(tc.pkgPath, "MySQLError", v))
+		})
+	}
+}
+
+func TestRecordSpanErrorCategory(t *testing.T) {
+	_, sr, tracer, _ := prepareTraces(true)
+	err := mockSQLStateError("40001")
+
+	_, span := tracer.Start(context.Background(), "recorded")
+	recordSpanError(context.Background(), span, config{}, MethodConnExec, "", err)
+	span.End()
+
+	// Expected errors are not classified.
+	cfg := config{SpanOptions: SpanOptions{
+		RecordError: withoutExpectedErrors(nil, func(error) bool { return true }),
+	}}
+	_, span = tracer.Start(context.Background(), "expected")
+	recordSpanError(context.Background(), span, cfg, MethodConnExec, "", err)
+	span.End()
+
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, span := range sr.Ended() {
+		spans[span.Name()] = span
+	}
+	require.Len(t, spans, 2)
+	assert.Contains(t, spans["recorded"].Attributes(), dbErrorCategoryKey.String(errorCategorySerializationFailure))
+	assert.Equal(t, codes.Unset, spans["expected"].Status().Code)
+	for _, attr := range spans["expected"].Attributes() {
+		assert.NotEqual(t, dbErrorCategoryKey, attr.Key)
+	}
+}
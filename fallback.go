@@ -0,0 +1,50 @@
+// Copyright Sam Xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otelsql
+
+import (
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var prepareFallbackKey = attribute.Key("db.sql.prepare_fallback")
+
+// markPrepareFallback records that database/sql is about to fall back to
+// Prepare and a statement call for query, because the connection returned
+// driver.ErrSkip for it. See SpanOptions.CollapsePrepareFallback.
+func (c *otConn) markPrepareFallback(query string) {
+	if c.cfg.SpanOptions.CollapsePrepareFallback {
+		c.fallbackQuery = &query
+	}
+}
+
+// takePrepareFallback reports whether preparing query is part of a fallback
+// marked by markPrepareFallback, and clears the mark.
+func (c *otConn) takePrepareFallback(query string) bool {
+	if c.fallbackQuery == nil {
+		return false
+	}
+	fallback := *c.fallbackQuery == query
+	c.fallbackQuery = nil
+	return fallback
+}
+
+// setPrepareFallbackAttribute marks span as the span of a statement prepared
+// by a fallback.
+func (s *otStmt) setPrepareFallbackAttribute(span trace.Span) {
+	if s.prepareFallback && span != nil {
+		span.SetAttributes(prepareFallbackKey.Bool(true))
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright Sam Xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otelsql
+
+import (
+	"database/sql/driver"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOtConn_CollapsePrepareFallback(t *testing.T) {
+	for _, collapse := range []bool{false, true} {
+		t.Run(map[bool]string{false: "disabled", true: "enabled"}[collapse], func(t *testing.T) {
+			ctx, sr, tracer, _ := prepareTraces(true)
+			cfg := newMockConfig(t, tracer)
+			cfg.SpanOptions.CollapsePrepareFallback = collapse
+			// The connection does not implement driver.QueryerContext.
+			otelConn := newConn(struct{ driver.Conn }{newMockConn(false)}, cfg)
+
+			_, err := otelConn.QueryContext(ctx, "SELECT 1", nil)
+			require.ErrorIs(t, err, driver.ErrSkip)
+
+			// database/sql falls back to Prepare and a statement call.
+			stmt, err := otelConn.PrepareContext(ctx, "SELECT 1")
+			require.NoError(t, err)
+			_, err = stmt.(*otStmt).QueryContext(ctx, nil)
+			require.NoError(t, err)
+
+			var names []string
+			for _, span := range sr.Ended() {
+				names = append(names, span.Name())
+				if span.Name() == string(MethodStmtQuery) {
+					if collapse {
+						assert.Contains(t, span.Attributes(), prepareFallbackKey.Bool(true))
+					} else {
+						assert.NotContains(t, span.Attributes(), prepareFallbackKey.Bool(true))
+					}
+				}
+			}
+			if collapse {
+				assert.Equal(t, []string{string(MethodStmtQuery)}, names)
+			} else {
+				assert.Equal(t, []string{string(MethodConnPrepare), string(MethodStmtQuery)}, names)
+			}
+
+			// Other prepares are not affected.
+			_, err = otelConn.PrepareContext(ctx, "SELECT 2")
+			require.NoError(t, err)
+			spanList := sr.Ended()
+			assert.Equal(t, string(MethodConnPrepare), spanList[len(spanList)-1].Name())
+		})
+	}
+}
@@ -0,0 +1,307 @@
+// Copyright Sam Xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package faultinject provides database/sql/driver wrappers injecting
+// latency and errors into database calls, to rehearse database incidents and
+// check the dashboards and alerts built on the otelsql telemetry.
+//
+// The wrapped driver or connector is meant to be instrumented by otelsql, so
+// that the injected faults are recorded like real ones:
+//
+//	db := otelsql.OpenDB(faultinject.WrapConnector(connector, faultinject.Fault{
+//		Probability: 0.1,
+//		Err:         driver.ErrBadConn,
+//	}))
+package faultinject // import "github.com/XSAM/otelsql/faultinject"
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"math/rand/v2"
+	"time"
+)
+
+// Fault is a fault injected into the Exec, Query, and Prepare calls of the
+// wrapped connections and statements.
+type Fault struct {
+	// Query, if set, restricts the fault to the calls of the queries it
+	// reports true for.
+	Query func(query string) bool
+	// Probability is the probability, between 0 and 1, of a matching call to
+	// be faulted.
+	Probability float64
+	// Latency is added to the faulted calls, before they are made or fail.
+	Latency time.Duration
+	// Err, if set, is returned by the faulted calls instead of making them,
+	// e.g. driver.ErrBadConn or a driver-specific error.
+	Err error
+}
+
+// WrapDriver returns d injecting faults into the connections it opens. The
+// first fault matching a call is applied.
+func WrapDriver(d driver.Driver, faults ...Fault) driver.Driver {
+	return faultDriver{Driver: d, faults: faults}
+}
+
+// WrapConnector returns c injecting faults into the connections it opens. The
+// first fault matching a call is applied.
+func WrapConnector(c driver.Connector, faults ...Fault) driver.Connector {
+	return faultConnector{Connector: c, faults: faults}
+}
+
+type faultDriver struct {
+	driver.Driver
+	faults []Fault
+}
+
+func (d faultDriver) Open(name string) (driver.Conn, error) {
+	c, err := d.Driver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &faultConn{Conn: c, faults: d.faults}, nil
+}
+
+type faultConnector struct {
+	driver.Connector
+	faults []Fault
+}
+
+func (c faultConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &faultConn{Conn: conn, faults: c.faults}, nil
+}
+
+// inject applies the first of faults matching query, if any, and returns the
+// error the call must fail with.
+func inject(ctx context.Context, faults []Fault, query string) error {
+	for _, fault := range faults {
+		if fault.Query != nil && !fault.Query(query) {
+			continue
+		}
+		if rand.Float64() >= fault.Probability {
+			return nil
+		}
+		if fault.Latency > 0 {
+			timer := time.NewTimer(fault.Latency)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			case <-timer.C:
+			}
+		}
+		return fault.Err
+	}
+	return nil
+}
+
+type faultConn struct {
+	driver.Conn
+	faults []Fault
+}
+
+var (
+	_ driver.ExecerContext      = (*faultConn)(nil)
+	_ driver.QueryerContext     = (*faultConn)(nil)
+	_ driver.ConnPrepareContext = (*faultConn)(nil)
+	_ driver.ConnBeginTx        = (*faultConn)(nil)
+	_ driver.Pinger             = (*faultConn)(nil)
+	_ driver.SessionResetter    = (*faultConn)(nil)
+	_ driver.Validator          = (*faultConn)(nil)
+	_ driver.NamedValueChecker  = (*faultConn)(nil)
+)
+
+func (c *faultConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	legacyExecer, legacyOK := c.Conn.(driver.Execer) //nolint:staticcheck
+	if !ok && !legacyOK {
+		// database/sql falls back to a prepared statement, which is faulted.
+		return nil, driver.ErrSkip
+	}
+	if err := inject(ctx, c.faults, query); err != nil {
+		return nil, err
+	}
+	if ok {
+		return execer.ExecContext(ctx, query, args)
+	}
+
+	// Copied from stdlib database/sql package: src/database/sql/ctxutil.go.
+	values, err := namedValueToValue(args)
+	if err != nil {
+		return nil, err
+	}
+	select {
+	default:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return legacyExecer.Exec(query, values)
+}
+
+func (c *faultConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	legacyQueryer, legacyOK := c.Conn.(driver.Queryer) //nolint:staticcheck
+	if !ok && !legacyOK {
+		return nil, driver.ErrSkip
+	}
+	if err := inject(ctx, c.faults, query); err != nil {
+		return nil, err
+	}
+	if ok {
+		return queryer.QueryContext(ctx, query, args)
+	}
+
+	// Copied from stdlib database/sql package: src/database/sql/ctxutil.go.
+	values, err := namedValueToValue(args)
+	if err != nil {
+		return nil, err
+	}
+	select {
+	default:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return legacyQueryer.Query(query, values)
+}
+
+func (c *faultConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if err := inject(ctx, c.faults, query); err != nil {
+		return nil, err
+	}
+
+	var stmt driver.Stmt
+	var err error
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = preparer.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &faultStmt{Stmt: stmt, conn: c, query: query, faults: c.faults}, nil
+}
+
+func (c *faultConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *faultConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+	// Copied from stdlib database/sql package: src/database/sql/ctxutil.go.
+	if opts.Isolation != driver.IsolationLevel(0) {
+		return nil, errors.New("sql: driver does not support non-default isolation level")
+	}
+	if opts.ReadOnly {
+		return nil, errors.New("sql: driver does not support read-only transactions")
+	}
+	return c.Conn.Begin() //nolint:staticcheck
+}
+
+func (c *faultConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *faultConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *faultConn) IsValid() bool {
+	if validator, ok := c.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
+
+func (c *faultConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if checker, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+type faultStmt struct {
+	driver.Stmt
+	conn   *faultConn
+	query  string
+	faults []Fault
+}
+
+var (
+	_ driver.StmtExecContext   = (*faultStmt)(nil)
+	_ driver.StmtQueryContext  = (*faultStmt)(nil)
+	_ driver.NamedValueChecker = (*faultStmt)(nil)
+)
+
+func (s *faultStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	if err := inject(ctx, s.faults, s.query); err != nil {
+		return nil, err
+	}
+	if execer, ok := s.Stmt.(driver.StmtExecContext); ok {
+		return execer.ExecContext(ctx, args)
+	}
+	values, err := namedValueToValue(args)
+	if err != nil {
+		return nil, err
+	}
+	return s.Stmt.Exec(values) //nolint:staticcheck
+}
+
+func (s *faultStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	if err := inject(ctx, s.faults, s.query); err != nil {
+		return nil, err
+	}
+	if queryer, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		return queryer.QueryContext(ctx, args)
+	}
+	values, err := namedValueToValue(args)
+	if err != nil {
+		return nil, err
+	}
+	return s.Stmt.Query(values) //nolint:staticcheck
+}
+
+func (s *faultStmt) CheckNamedValue(nv *driver.NamedValue) error {
+	if checker, ok := s.Stmt.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+	// database/sql only falls back to the checker of the connection if the
+	// statement has none, which faultStmt always has.
+	return s.conn.CheckNamedValue(nv)
+}
+
+// Copied from stdlib database/sql package: src/database/sql/ctxutil.go.
+func namedValueToValue(named []driver.NamedValue) ([]driver.Value, error) {
+	dargs := make([]driver.Value, len(named))
+	for n, param := range named {
+		if len(param.Name) > 0 {
+			return nil, errors.New("sql: driver does not support the use of Named Parameters")
+		}
+		dargs[n] = param.Value
+	}
+	return dargs, nil
+}
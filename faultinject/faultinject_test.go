@@ -0,0 +1,217 @@
+// Copyright Sam Xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package faultinject
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errInjected = errors.New("injected")
+
+type mockConnector struct {
+	withContext bool
+	legacy      bool
+	checker     bool
+}
+
+func (c mockConnector) Connect(context.Context) (driver.Conn, error) {
+	if c.withContext {
+		return mockContextConn{}, nil
+	}
+	if c.legacy {
+		return mockLegacyConn{}, nil
+	}
+	if c.checker {
+		return mockCheckerConn{}, nil
+	}
+	return mockConn{}, nil
+}
+
+func (c mockConnector) Driver() driver.Driver {
+	return mockDriver{}
+}
+
+type mockDriver struct{}
+
+func (mockDriver) Open(string) (driver.Conn, error) {
+	return mockConn{}, nil
+}
+
+// mockConn only implements the methods required by driver.Conn.
+type mockConn struct{}
+
+func (mockConn) Prepare(string) (driver.Stmt, error) { return mockStmt{}, nil }
+func (mockConn) Close() error                        { return nil }
+func (mockConn) Begin() (driver.Tx, error)           { return mockTx{}, nil }
+
+type mockContextConn struct {
+	mockConn
+}
+
+func (mockContextConn) ExecContext(context.Context, string, []driver.NamedValue) (driver.Result, error) {
+	return driver.RowsAffected(1), nil
+}
+
+func (mockContextConn) QueryContext(context.Context, string, []driver.NamedValue) (driver.Rows, error) {
+	return mockRows{}, nil
+}
+
+// mockLegacyConn only implements the legacy driver.Execer and driver.Queryer,
+// and fails to prepare statements.
+type mockLegacyConn struct {
+	mockConn
+}
+
+func (mockLegacyConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("unexpected prepare")
+}
+
+func (mockLegacyConn) Exec(string, []driver.Value) (driver.Result, error) {
+	return driver.RowsAffected(1), nil
+}
+
+func (mockLegacyConn) Query(string, []driver.Value) (driver.Rows, error) {
+	return mockRows{}, nil
+}
+
+// mockCheckerConn accepts any argument with its driver.NamedValueChecker,
+// which its statements do not implement.
+type mockCheckerConn struct {
+	mockConn
+}
+
+func (mockCheckerConn) CheckNamedValue(*driver.NamedValue) error { return nil }
+
+type mockStmt struct{}
+
+func (mockStmt) Close() error                               { return nil }
+func (mockStmt) NumInput() int                              { return -1 }
+func (mockStmt) Exec([]driver.Value) (driver.Result, error) { return driver.RowsAffected(1), nil }
+func (mockStmt) Query([]driver.Value) (driver.Rows, error)  { return mockRows{}, nil }
+
+type mockTx struct{}
+
+func (mockTx) Commit() error   { return nil }
+func (mockTx) Rollback() error { return nil }
+
+type mockRows struct{}
+
+func (mockRows) Columns() []string         { return nil }
+func (mockRows) Close() error              { return nil }
+func (mockRows) Next([]driver.Value) error { return io.EOF }
+
+func TestWrapConnector(t *testing.T) {
+	faults := []Fault{
+		{
+			Query:       func(query string) bool { return strings.HasPrefix(query, "DELETE") },
+			Probability: 1,
+			Err:         errInjected,
+		},
+		{
+			Query:       func(query string) bool { return strings.HasPrefix(query, "UPDATE") },
+			Probability: 0,
+			Err:         errInjected,
+		},
+	}
+
+	for _, withContext := range []bool{true, false} {
+		db := sql.OpenDB(WrapConnector(mockConnector{withContext: withContext}, faults...))
+
+		_, err := db.Exec("DELETE FROM t")
+		assert.ErrorIs(t, err, errInjected, "with context: %v", withContext)
+		_, err = db.Exec("UPDATE t SET a = 1")
+		assert.NoError(t, err, "with context: %v", withContext)
+
+		rows, err := db.Query("SELECT 1")
+		require.NoError(t, err, "with context: %v", withContext)
+		require.NoError(t, rows.Close())
+
+		stmt, err := db.Prepare("INSERT INTO t VALUES (1)")
+		require.NoError(t, err)
+		_, err = stmt.Exec()
+		assert.NoError(t, err)
+		require.NoError(t, stmt.Close())
+
+		tx, err := db.Begin()
+		require.NoError(t, err)
+		require.NoError(t, tx.Commit())
+		require.NoError(t, db.Close())
+	}
+}
+
+func TestWrapConnectorLegacyConn(t *testing.T) {
+	fault := Fault{
+		Query:       func(query string) bool { return strings.HasPrefix(query, "DELETE") },
+		Probability: 1,
+		Err:         errInjected,
+	}
+	db := sql.OpenDB(WrapConnector(mockConnector{legacy: true}, fault))
+	defer db.Close()
+
+	// The calls are passed to Exec and Query instead of falling back to
+	// prepared statements.
+	_, err := db.Exec("DELETE FROM t")
+	assert.ErrorIs(t, err, errInjected)
+	_, err = db.Exec("UPDATE t SET a = ?", 1)
+	assert.NoError(t, err)
+	rows, err := db.Query("SELECT 1")
+	require.NoError(t, err)
+	require.NoError(t, rows.Close())
+}
+
+func TestWrapConnectorConnChecker(t *testing.T) {
+	db := sql.OpenDB(WrapConnector(mockConnector{checker: true}))
+	defer db.Close()
+
+	stmt, err := db.Prepare("UPDATE t SET a = ?")
+	require.NoError(t, err)
+	defer stmt.Close()
+
+	// The default converter rejects structs, the checker of the conn accepts
+	// them.
+	_, err = stmt.Exec(struct{}{})
+	assert.NoError(t, err)
+}
+
+func TestWrapDriver(t *testing.T) {
+	d := WrapDriver(mockDriver{}, Fault{Probability: 1, Err: errInjected})
+	conn, err := d.Open("")
+	require.NoError(t, err)
+
+	_, err = conn.Prepare("SELECT 1")
+	assert.ErrorIs(t, err, errInjected)
+}
+
+func TestInjectLatency(t *testing.T) {
+	faults := []Fault{{Probability: 1, Latency: 10 * time.Millisecond}}
+
+	start := time.Now()
+	require.NoError(t, inject(context.Background(), faults, "SELECT 1"))
+	assert.GreaterOrEqual(t, time.Since(start), 10*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, inject(ctx, faults, "SELECT 1"), context.Canceled)
+}
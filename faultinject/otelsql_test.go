@@ -0,0 +1,52 @@
+// Copyright Sam Xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !otelsql_noop
+
+package faultinject
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/XSAM/otelsql"
+)
+
+func TestWrapConnectorWithOtelsql(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+
+	db := otelsql.OpenDB(
+		WrapConnector(mockConnector{withContext: true}, Fault{Probability: 1, Err: errInjected}),
+		otelsql.WithTracerProvider(tp),
+	)
+	defer db.Close()
+
+	_, err := db.Exec("DELETE FROM t")
+	require.ErrorIs(t, err, errInjected)
+
+	var found bool
+	for _, span := range sr.Ended() {
+		if span.Name() == string(otelsql.MethodConnExec) {
+			found = true
+			assert.Equal(t, codes.Error, span.Status().Code)
+		}
+	}
+	assert.True(t, found)
+}
@@ -0,0 +1,90 @@
+// Copyright Sam Xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otelsql
+
+import (
+	"context"
+	"database/sql/driver"
+	"regexp"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// FilterMethods returns a SpanFilter that keeps spans of the given methods.
+func FilterMethods(methods ...Method) SpanFilter {
+	set := make(map[Method]struct{}, len(methods))
+	for _, m := range methods {
+		set[m] = struct{}{}
+	}
+
+	return func(_ context.Context, method Method, _ string, _ []driver.NamedValue) bool {
+		_, ok := set[method]
+		return ok
+	}
+}
+
+// FilterQueryMatching returns a SpanFilter that keeps spans whose query
+// matches re. Methods without a query, like MethodTxCommit, never match.
+func FilterQueryMatching(re *regexp.Regexp) SpanFilter {
+	return func(_ context.Context, _ Method, query string, _ []driver.NamedValue) bool {
+		return query != "" && re.MatchString(query)
+	}
+}
+
+// FilterNot returns a SpanFilter that keeps the spans dropped by filter.
+func FilterNot(filter SpanFilter) SpanFilter {
+	return func(ctx context.Context, method Method, query string, args []driver.NamedValue) bool {
+		return !filter(ctx, method, query, args)
+	}
+}
+
+// FilterAll returns a SpanFilter that keeps spans kept by all filters.
+// Nil filters are ignored.
+func FilterAll(filters ...SpanFilter) SpanFilter {
+	return func(ctx context.Context, method Method, query string, args []driver.NamedValue) bool {
+		for _, f := range filters {
+			if f != nil && !f(ctx, method, query, args) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// FilterAny returns a SpanFilter that keeps spans kept by any of the filters.
+// Nil filters are ignored.
+func FilterAny(filters ...SpanFilter) SpanFilter {
+	return func(ctx context.Context, method Method, query string, args []driver.NamedValue) bool {
+		for _, f := range filters {
+			if f != nil && f(ctx, method, query, args) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// FilterParentSampled returns a ParentSpanFilter that keeps spans whose
+// parent span is sampled, so no spans are created outside of sampled traces.
+// If keepRoot is true, spans without a parent are kept as well, e.g. for
+// background jobs whose database calls start traces.
+func FilterParentSampled(keepRoot bool) ParentSpanFilter {
+	return func(_ context.Context, parent trace.SpanContext, _ Method, _ string, _ []driver.NamedValue) bool {
+		if !parent.IsValid() {
+			return keepRoot
+		}
+		return parent.IsSampled()
+	}
+}
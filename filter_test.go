@@ -0,0 +1,141 @@
+// Copyright Sam Xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otelsql
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestSpanFilters(t *testing.T) {
+	selectQuery := regexp.MustCompile(`(?i)^\s*SELECT`)
+
+	testCases := []struct {
+		name     string
+		filter   SpanFilter
+		method   Method
+		query    string
+		expected bool
+	}{
+		{
+			name:     "methods match",
+			filter:   FilterMethods(MethodConnQuery, MethodStmtQuery),
+			method:   MethodStmtQuery,
+			expected: true,
+		},
+		{
+			name:   "methods do not match",
+			filter: FilterMethods(MethodConnQuery, MethodStmtQuery),
+			method: MethodConnExec,
+		},
+		{
+			name:     "query matches",
+			filter:   FilterQueryMatching(selectQuery),
+			method:   MethodConnQuery,
+			query:    "select 1",
+			expected: true,
+		},
+		{
+			name:   "query does not match",
+			filter: FilterQueryMatching(selectQuery),
+			method: MethodConnExec,
+			query:  "INSERT INTO t VALUES (1)",
+		},
+		{
+			name:   "empty query",
+			filter: FilterQueryMatching(regexp.MustCompile(`.*`)),
+			method: MethodTxCommit,
+		},
+		{
+			name:     "not",
+			filter:   FilterNot(FilterMethods(MethodConnResetSession)),
+			method:   MethodConnQuery,
+			expected: true,
+		},
+		{
+			name:     "all",
+			filter:   FilterAll(FilterMethods(MethodConnQuery), nil, FilterQueryMatching(selectQuery)),
+			method:   MethodConnQuery,
+			query:    "SELECT 1",
+			expected: true,
+		},
+		{
+			name:   "all with a mismatch",
+			filter: FilterAll(FilterMethods(MethodConnQuery), FilterQueryMatching(selectQuery)),
+			method: MethodConnQuery,
+			query:  "UPDATE t SET a = 1",
+		},
+		{
+			name:     "all without filters",
+			filter:   FilterAll(),
+			method:   MethodConnQuery,
+			expected: true,
+		},
+		{
+			name:     "any",
+			filter:   FilterAny(FilterMethods(MethodConnExec), nil, FilterQueryMatching(selectQuery)),
+			method:   MethodConnQuery,
+			query:    "SELECT 1",
+			expected: true,
+		},
+		{
+			name:   "any without a match",
+			filter: FilterAny(FilterMethods(MethodConnExec), FilterQueryMatching(selectQuery)),
+			method: MethodConnQuery,
+			query:  "UPDATE t SET a = 1",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.filter(context.Background(), tc.method, tc.query, nil))
+		})
+	}
+}
+
+func TestFilterParentSampled(t *testing.T) {
+	sampled := newTestSpanContext(t)
+	notSampled := trace.ContextWithSpanContext(context.Background(),
+		trace.SpanContextFromContext(sampled).WithTraceFlags(0))
+
+	testCases := []struct {
+		name     string
+		ctx      context.Context
+		keepRoot bool
+		expected bool
+	}{
+		{name: "sampled parent", ctx: sampled, expected: true},
+		{name: "sampled parent keeping roots", ctx: sampled, keepRoot: true, expected: true},
+		{name: "parent not sampled", ctx: notSampled},
+		{name: "parent not sampled keeping roots", ctx: notSampled, keepRoot: true},
+		{name: "no parent", ctx: context.Background()},
+		{name: "no parent keeping roots", ctx: context.Background(), keepRoot: true, expected: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := SpanOptions{ParentSpanFilter: FilterParentSampled(tc.keepRoot)}
+			assert.Equal(t, tc.expected, filterSpan(tc.ctx, opts, MethodConnQuery, "", nil))
+
+			// SpanFilter applies as well.
+			opts.SpanFilter = FilterMethods(MethodConnExec)
+			assert.False(t, filterSpan(tc.ctx, opts, MethodConnQuery, "", nil))
+		})
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright Sam Xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package filters provides ready-made otelsql.SpanFilter funcs that drop
+// common noise, like health checks and migration tooling queries.
+//
+// Filters can be combined with otelsql.FilterAll:
+//
+//	otelsql.WithSpanOptions(otelsql.SpanOptions{
+//		SpanFilter: otelsql.FilterAll(
+//			filters.SkipHealthChecks(),
+//			filters.SkipResetSession(),
+//		),
+//	})
+package filters // import "github.com/XSAM/otelsql/filters"
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+
+	"github.com/XSAM/otelsql"
+)
+
+// healthCheckQueries are queries commonly used by connection pools and
+// load balancers to check that a database is alive.
+var healthCheckQueries = map[string]struct{}{
+	"SELECT 1":                 {},
+	"SELECT 1 FROM DUAL":       {},
+	"SELECT CURRENT_TIMESTAMP": {},
+	"SELECT NOW()":             {},
+	"SELECT VERSION()":         {},
+	"SELECT @@VERSION":         {},
+}
+
+// migrationTables are the bookkeeping tables of common migration tools.
+var migrationTables = []string{
+	"schema_migrations",
+	"goose_db_version",
+	"flyway_schema_history",
+	"atlas_schema_revisions",
+	"gorp_migrations",
+}
+
+// SkipHealthChecks returns a filter that drops spans of health check queries
+// like SELECT 1 and SELECT CURRENT_TIMESTAMP.
+func SkipHealthChecks() otelsql.SpanFilter {
+	return func(_ context.Context, _ otelsql.Method, query string, _ []driver.NamedValue) bool {
+		_, ok := healthCheckQueries[normalize(query)]
+		return !ok
+	}
+}
+
+// SkipResetSession returns a filter that drops sql.conn.reset_session spans.
+func SkipResetSession() otelsql.SpanFilter {
+	return SkipMethods(otelsql.MethodConnResetSession)
+}
+
+// SkipMethods returns a filter that drops spans of the given methods.
+func SkipMethods(methods ...otelsql.Method) otelsql.SpanFilter {
+	return otelsql.FilterNot(otelsql.FilterMethods(methods...))
+}
+
+// SkipQueryPrefixes returns a filter that drops spans whose query starts with
+// any of the prefixes. The comparison is case-insensitive and ignores leading
+// whitespace.
+func SkipQueryPrefixes(prefixes ...string) otelsql.SpanFilter {
+	upper := make([]string, len(prefixes))
+	for i, p := range prefixes {
+		upper[i] = strings.ToUpper(p)
+	}
+
+	return func(_ context.Context, _ otelsql.Method, query string, _ []driver.NamedValue) bool {
+		query = strings.ToUpper(strings.TrimSpace(query))
+		for _, p := range upper {
+			if strings.HasPrefix(query, p) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// SkipMigrationTools returns a filter that drops spans of queries touching the
+// bookkeeping tables of common migration tools, like golang-migrate, goose,
+// Flyway, and Atlas.
+func SkipMigrationTools() otelsql.SpanFilter {
+	return func(_ context.Context, _ otelsql.Method, query string, _ []driver.NamedValue) bool {
+		query = strings.ToLower(query)
+		for _, table := range migrationTables {
+			if strings.Contains(query, table) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// normalize upper-cases query, collapses whitespace, and removes a trailing
+// semicolon.
+func normalize(query string) string {
+	query = strings.Join(strings.Fields(strings.ToUpper(query)), " ")
+	return strings.TrimSuffix(query, ";")
+}
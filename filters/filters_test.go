@@ -0,0 +1,97 @@
+// Copyright Sam Xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filters
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/XSAM/otelsql"
+)
+
+func TestFilters(t *testing.T) {
+	testCases := []struct {
+		name     string
+		filter   otelsql.SpanFilter
+		method   otelsql.Method
+		query    string
+		expected bool
+	}{
+		{
+			name:   "health check",
+			filter: SkipHealthChecks(),
+			method: otelsql.MethodConnQuery,
+			query:  "select  1;",
+		},
+		{
+			name:   "health check with current timestamp",
+			filter: SkipHealthChecks(),
+			method: otelsql.MethodConnQuery,
+			query:  "SELECT CURRENT_TIMESTAMP",
+		},
+		{
+			name:     "not a health check",
+			filter:   SkipHealthChecks(),
+			method:   otelsql.MethodConnQuery,
+			query:    "SELECT 1 FROM users",
+			expected: true,
+		},
+		{
+			name:   "reset session",
+			filter: SkipResetSession(),
+			method: otelsql.MethodConnResetSession,
+		},
+		{
+			name:     "not reset session",
+			filter:   SkipResetSession(),
+			method:   otelsql.MethodConnQuery,
+			expected: true,
+		},
+		{
+			name:   "query prefix",
+			filter: SkipQueryPrefixes("set ", "show "),
+			method: otelsql.MethodConnExec,
+			query:  "  SET NAMES utf8mb4",
+		},
+		{
+			name:     "no query prefix",
+			filter:   SkipQueryPrefixes("set ", "show "),
+			method:   otelsql.MethodConnExec,
+			query:    "INSERT INTO settings VALUES (1)",
+			expected: true,
+		},
+		{
+			name:   "migration tool",
+			filter: SkipMigrationTools(),
+			method: otelsql.MethodConnQuery,
+			query:  "SELECT version, dirty FROM schema_migrations LIMIT 1",
+		},
+		{
+			name:     "not a migration tool",
+			filter:   SkipMigrationTools(),
+			method:   otelsql.MethodConnQuery,
+			query:    "SELECT * FROM users",
+			expected: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.filter(context.Background(), tc.method, tc.query, nil))
+		})
+	}
+}
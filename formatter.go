@@ -0,0 +1,62 @@
+// Copyright Sam Xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otelsql
+
+import (
+	"context"
+	"strings"
+)
+
+// Ready-made SpanNameFormatters to pass to WithSpanNameFormatter.
+var (
+	// MethodSpanNameFormatter names spans after the method, like
+	// sql.conn.query. It is the default.
+	MethodSpanNameFormatter SpanNameFormatter = defaultSpanNameFormatter
+
+	// OperationCollectionSpanNameFormatter names spans after the operation
+	// and the first table of the query, like "SELECT orders", and falls back
+	// to the method for calls without a query.
+	OperationCollectionSpanNameFormatter SpanNameFormatter = operationCollectionSpanNameFormatter
+)
+
+func operationCollectionSpanNameFormatter(ctx context.Context, method Method, query string) string {
+	operation := operationName(query)
+	if operation == "" {
+		return defaultSpanNameFormatter(ctx, method, query)
+	}
+	if collection := collectionName(query); collection != "" {
+		return operation + " " + collection
+	}
+	return operation
+}
+
+// QuerySpanNameFormatter returns a SpanNameFormatter naming spans after the
+// query, with whitespace collapsed and truncated to maxLength characters. It
+// falls back to the method for calls without a query.
+//
+// Queries may contain sensitive literals and make span names of high
+// cardinality, it should only be used with parameterized queries.
+func QuerySpanNameFormatter(maxLength int) SpanNameFormatter {
+	return func(ctx context.Context, method Method, query string) string {
+		name := strings.Join(strings.Fields(query), " ")
+		if name == "" {
+			return defaultSpanNameFormatter(ctx, method, query)
+		}
+		if runes := []rune(name); maxLength > 0 && len(runes) > maxLength {
+			name = string(runes[:maxLength])
+		}
+		return name
+	}
+}
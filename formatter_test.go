@@ -0,0 +1,93 @@
+// Copyright Sam Xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otelsql
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpanNameFormatters(t *testing.T) {
+	testCases := []struct {
+		name      string
+		formatter SpanNameFormatter
+		method    Method
+		query     string
+		expected  string
+	}{
+		{
+			name:      "method",
+			formatter: MethodSpanNameFormatter,
+			method:    MethodConnQuery,
+			query:     "SELECT * FROM orders",
+			expected:  "sql.conn.query",
+		},
+		{
+			name:      "operation and collection",
+			formatter: OperationCollectionSpanNameFormatter,
+			method:    MethodConnQuery,
+			query:     "select * from orders join customers",
+			expected:  "SELECT orders",
+		},
+		{
+			name:      "operation without collection",
+			formatter: OperationCollectionSpanNameFormatter,
+			method:    MethodConnQuery,
+			query:     "SELECT 1",
+			expected:  "SELECT",
+		},
+		{
+			name:      "operation without query",
+			formatter: OperationCollectionSpanNameFormatter,
+			method:    MethodTxCommit,
+			expected:  "sql.tx.commit",
+		},
+		{
+			name:      "query",
+			formatter: QuerySpanNameFormatter(30),
+			method:    MethodConnQuery,
+			query:     "SELECT id\n\tFROM orders",
+			expected:  "SELECT id FROM orders",
+		},
+		{
+			name:      "truncated query",
+			formatter: QuerySpanNameFormatter(9),
+			method:    MethodConnQuery,
+			query:     "SELECT id FROM orders",
+			expected:  "SELECT id",
+		},
+		{
+			name:      "query without limit",
+			formatter: QuerySpanNameFormatter(0),
+			method:    MethodConnQuery,
+			query:     "SELECT id FROM orders",
+			expected:  "SELECT id FROM orders",
+		},
+		{
+			name:      "query without query",
+			formatter: QuerySpanNameFormatter(9),
+			method:    MethodConnBeginTx,
+			expected:  "sql.conn.begin_tx",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.formatter(context.Background(), tc.method, tc.query))
+		})
+	}
+}
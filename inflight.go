@@ -0,0 +1,149 @@
+// Copyright Sam Xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otelsql
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+var (
+	oldestCallAgeInstrumentName = strings.Join([]string{namespace, "oldest_call", "age"}, ".")
+
+	dbQueryFingerprintKey = attribute.Key("db.query.fingerprint")
+)
+
+// inFlightCall is a driver call in progress.
+type inFlightCall struct {
+	method Method
+	query  string
+	start  time.Time
+}
+
+// inFlightRegistry keeps the driver calls in progress, so that the age of the
+// oldest one can be observed, see WithOldestCallMetric.
+type inFlightRegistry struct {
+	mu     sync.Mutex
+	nextID uint64
+	calls  map[uint64]inFlightCall
+}
+
+func newInFlightRegistry() *inFlightRegistry {
+	return &inFlightRegistry{calls: make(map[uint64]inFlightCall)}
+}
+
+// track registers a call of method with query until the returned func, which
+// calls next, is called at the end of the call.
+func (r *inFlightRegistry) track(method Method, query string, next func(error)) func(error) {
+	r.mu.Lock()
+	id := r.nextID
+	r.nextID++
+	r.calls[id] = inFlightCall{method: method, query: query, start: time.Now()}
+	r.mu.Unlock()
+
+	return func(err error) {
+		r.mu.Lock()
+		delete(r.calls, id)
+		r.mu.Unlock()
+		if next != nil {
+			next(err)
+		}
+	}
+}
+
+// oldest returns the call in progress that started first, if any.
+func (r *inFlightRegistry) oldest() (inFlightCall, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var oldest inFlightCall
+	found := false
+	for _, call := range r.calls {
+		if !found || call.start.Before(oldest.start) {
+			oldest, found = call, true
+		}
+	}
+	return oldest, found
+}
+
+// registerOldestCallMetric sets the registry of the calls in progress of cfg
+// and registers the callback observing the age of the oldest one, if
+// OldestCallMetric is set. It is only called for the drivers and connectors
+// making the calls, not for every config. The returned registration, nil if
+// nothing is registered, must be unregistered once the calls are no longer
+// made, e.g. when the connector is closed.
+func registerOldestCallMetric(cfg *config) metric.Registration {
+	if !cfg.OldestCallMetric || cfg.noopMeter || cfg.disabled {
+		return nil
+	}
+
+	registry := newInFlightRegistry()
+	registration, err := observeOldestCall(*cfg, registry)
+	if err != nil {
+		otel.Handle(fmt.Errorf("failed to register oldest call metric, %v", err))
+		return nil
+	}
+	cfg.inFlightCalls = registry
+	return registration
+}
+
+// unregister unregisters registration, if not nil, reporting the error to the
+// global error handler.
+func unregister(registration metric.Registration) {
+	if registration == nil {
+		return
+	}
+	if err := registration.Unregister(); err != nil {
+		otel.Handle(err)
+	}
+}
+
+// observeOldestCall registers the callback observing the age of the oldest
+// call in progress in registry. The age is 0 when no call is in progress.
+func observeOldestCall(cfg config, registry *inFlightRegistry) (metric.Registration, error) {
+	gauge, err := cfg.Meter.Float64ObservableGauge(
+		oldestCallAgeInstrumentName,
+		metric.WithDescription("The time elapsed since the start of the oldest call in progress"),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return cfg.Meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		call, ok := registry.oldest()
+		if !ok {
+			o.ObserveFloat64(gauge, 0, metric.WithAttributes(cfg.Attributes...))
+			return nil
+		}
+
+		attrs := append(slices.Clip(cfg.Attributes), queryMethodKey.String(string(call.method)))
+		if cfg.OldestCallFingerprint {
+			if fingerprint := queryFingerprint(call.query); fingerprint != "" {
+				attrs = append(attrs, dbQueryFingerprintKey.String(fingerprint))
+			}
+		}
+		o.ObserveFloat64(gauge, time.Since(call.start).Seconds(), metric.WithAttributes(attrs...))
+		return nil
+	}, gauge)
+}
@@ -0,0 +1,137 @@
+// Copyright Sam Xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !otelsql_noop
+
+package otelsql
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestInFlightRegistry(t *testing.T) {
+	r := newInFlightRegistry()
+	_, ok := r.oldest()
+	assert.False(t, ok)
+
+	var ended bool
+	endFirst := r.track(MethodConnExec, "UPDATE t SET a = 1", func(error) { ended = true })
+	endSecond := r.track(MethodConnQuery, "SELECT 1", nil)
+
+	call, ok := r.oldest()
+	require.True(t, ok)
+	assert.Equal(t, MethodConnExec, call.method)
+
+	endFirst(nil)
+	assert.True(t, ended)
+	call, ok = r.oldest()
+	require.True(t, ok)
+	assert.Equal(t, MethodConnQuery, call.method)
+
+	endSecond(nil)
+	_, ok = r.oldest()
+	assert.False(t, ok)
+}
+
+func TestOldestCallMetric(t *testing.T) {
+	r := sdkmetric.NewManualReader()
+	cfg := newConfig(
+		WithMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(r))),
+		WithOldestCallMetric(true),
+	)
+	registration := registerOldestCallMetric(&cfg)
+	require.NotNil(t, registration)
+	defer unregister(registration)
+	ctx := context.Background()
+
+	oldestCall := func() metricdata.DataPoint[float64] {
+		var rm metricdata.ResourceMetrics
+		require.NoError(t, r.Collect(ctx, &rm))
+		require.Len(t, rm.ScopeMetrics, 1)
+		for _, m := range rm.ScopeMetrics[0].Metrics {
+			if m.Name == oldestCallAgeInstrumentName {
+				gauge := m.Data.(metricdata.Gauge[float64])
+				require.Len(t, gauge.DataPoints, 1)
+				return gauge.DataPoints[0]
+			}
+		}
+		t.Fatalf("%s not recorded", oldestCallAgeInstrumentName)
+		return metricdata.DataPoint[float64]{}
+	}
+
+	assert.Zero(t, oldestCall().Value)
+
+	end := recordMetric(ctx, cfg.Instruments, cfg, MethodConnExec, "UPDATE users SET name = ? WHERE id = ?", nil)
+	// Rows are not calls waiting on the database.
+	endRows := recordMetric(ctx, cfg.Instruments, cfg, MethodRows, "", nil)
+	point := oldestCall()
+	assert.Positive(t, point.Value)
+	assert.Equal(t, attribute.NewSet(
+		queryMethodKey.String(string(MethodConnExec)),
+		dbQueryFingerprintKey.String(queryFingerprint("UPDATE users SET name = ? WHERE id = ?")),
+	), point.Attributes)
+
+	end(nil)
+	endRows(nil)
+	assert.Zero(t, oldestCall().Value)
+
+	// Queries with empty quoted strings are fingerprinted in the callback.
+	end = recordMetric(ctx, cfg.Instruments, cfg, MethodConnExec, `UPDATE users SET name = ""`, nil)
+	attrs := oldestCall().Attributes
+	fingerprint, _ := attrs.Value(dbQueryFingerprintKey)
+	assert.Equal(t, "UPDATE users SET name", fingerprint.AsString())
+	end(nil)
+}
+
+func TestOldestCallMetric_Registration(t *testing.T) {
+	r := sdkmetric.NewManualReader()
+	options := []Option{
+		WithMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(r))),
+		WithOldestCallMetric(false),
+	}
+	ctx := context.Background()
+	oldestCallPoints := func() int {
+		var rm metricdata.ResourceMetrics
+		require.NoError(t, r.Collect(ctx, &rm))
+		points := 0
+		for _, sm := range rm.ScopeMetrics {
+			for _, m := range sm.Metrics {
+				if m.Name == oldestCallAgeInstrumentName {
+					points += len(m.Data.(metricdata.Gauge[float64]).DataPoints)
+				}
+			}
+		}
+		return points
+	}
+
+	// Only the drivers and connectors making the calls register the metric.
+	_ = newConfig(options...)
+	assert.Zero(t, oldestCallPoints())
+
+	db := OpenDB(newMockConnector(newMockDriver(false), false), options...)
+	assert.Equal(t, 1, oldestCallPoints())
+	_ = NewDB(db, options...)
+	assert.Equal(t, 1, oldestCallPoints())
+
+	// The metric is unregistered when the DB is closed.
+	require.NoError(t, db.Close())
+	assert.Zero(t, oldestCallPoints())
+}
@@ -19,12 +19,36 @@ import (
 	"strings"
 
 	"go.opentelemetry.io/otel/metric"
+
+	"github.com/XSAM/otelsql/semconvutil"
 )
 
 const (
 	namespace = "db.sql"
 )
 
+var (
+	latencyInstrumentName             = strings.Join([]string{namespace, "latency"}, ".")
+	commenterTruncatedInstrumentName  = strings.Join([]string{namespace, "commenter", "truncated"}, ".")
+	commenterAddedBytesInstrumentName = strings.Join([]string{namespace, "commenter", "added_bytes"}, ".")
+	timeToFirstRowInstrumentName      = string(timeToFirstRowKey)
+	transactionsInstrumentName        = strings.Join([]string{namespace, "transactions"}, ".")
+	connectionErrorsInstrumentName    = "db.client.connection.errors"
+	inFlightInstrumentName            = strings.Join([]string{namespace, "in_flight"}, ".")
+	operationDurationInstrumentName   = "db.client.operation.duration"
+)
+
+// latencyBucketBoundaries are the advised bucket boundaries of db.sql.latency
+// in milliseconds. They match the default boundaries of the SDK, which are
+// millisecond-oriented, and are pinned so that they are kept if the SDK
+// defaults change.
+var latencyBucketBoundaries = []float64{0, 5, 10, 25, 50, 75, 100, 250, 500, 750, 1000, 2500, 5000, 7500, 10000}
+
+// latencySecondsBucketBoundaries are the advised bucket boundaries of
+// db.client.operation.duration, and of db.sql.latency in seconds, the ones
+// advised by the semantic conventions for database operation durations.
+var latencySecondsBucketBoundaries = []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5, 10}
+
 type dbStatsInstruments struct {
 	connectionMaxOpen                metric.Int64ObservableGauge
 	connectionOpen                   metric.Int64ObservableGauge
@@ -33,81 +57,333 @@ type dbStatsInstruments struct {
 	connectionClosedMaxIdleTotal     metric.Int64ObservableCounter
 	connectionClosedMaxIdleTimeTotal metric.Int64ObservableCounter
 	connectionClosedMaxLifetimeTotal metric.Int64ObservableCounter
+
+	// The instruments of the stable semantic conventions, see
+	// semconvStability.
+	connectionCount metric.Int64ObservableUpDownCounter
+	connectionMax   metric.Int64ObservableUpDownCounter
+
+	// The instruments of the pool limits, only set with WithPoolLimits.
+	// connectionIdleMax replaces connectionMaxIdle in the stable semantic
+	// conventions.
+	connectionMaxIdle     metric.Int64ObservableGauge
+	connectionIdleMax     metric.Int64ObservableUpDownCounter
+	connectionMaxLifetime metric.Float64ObservableGauge
+	connectionMaxIdleTime metric.Float64ObservableGauge
+}
+
+// observables returns the instruments observed by the callback of
+// RegisterDBStatsMetrics.
+func (i *dbStatsInstruments) observables() []metric.Observable {
+	var observables []metric.Observable
+	for _, instrument := range []metric.Observable{
+		i.connectionMaxOpen,
+		i.connectionOpen,
+		i.connectionWaitTotal,
+		i.connectionWaitDurationTotal,
+		i.connectionClosedMaxIdleTotal,
+		i.connectionClosedMaxIdleTimeTotal,
+		i.connectionClosedMaxLifetimeTotal,
+		i.connectionCount,
+		i.connectionMax,
+		i.connectionMaxIdle,
+		i.connectionIdleMax,
+		i.connectionMaxLifetime,
+		i.connectionMaxIdleTime,
+	} {
+		if instrument != nil {
+			observables = append(observables, instrument)
+		}
+	}
+	return observables
+}
+
+type stmtCacheInstruments struct {
+	hits      metric.Int64ObservableCounter
+	misses    metric.Int64ObservableCounter
+	evictions metric.Int64ObservableCounter
+	size      metric.Int64ObservableGauge
 }
 
 type instruments struct {
-	// The latency of calls in milliseconds
-	latency metric.Float64Histogram
+	// The latency of calls in milliseconds, or in seconds if latencySeconds
+	// is true. It is nil under the stable semantic conventions only.
+	latency        metric.Float64Histogram
+	latencySeconds bool
+
+	// The duration of calls in seconds, following the stable semantic
+	// conventions. It is only set when they are opted in.
+	operationDuration metric.Float64Histogram
+
+	// The number of SQL comments truncated due to the length limit
+	commenterTruncated metric.Int64Counter
+
+	// The number of bytes added to statements by SQL comments
+	commenterAddedBytes metric.Int64Histogram
+
+	// The time between a query returning rows and the first row read in seconds
+	timeToFirstRow metric.Float64Histogram
+
+	// The number of transactions ended, by outcome
+	transactions metric.Int64Counter
+
+	// The number of calls failed with driver.ErrBadConn
+	connectionErrors metric.Int64Counter
+
+	// The number of calls in progress
+	inFlight metric.Int64UpDownCounter
 }
 
-func newInstruments(meter metric.Meter) (*instruments, error) {
+func newInstruments(meter metric.Meter, unit DurationUnit, stability semconvutil.Stability) (*instruments, error) {
 	var instruments instruments
 	var err error
 
-	if instruments.latency, err = meter.Float64Histogram(
-		strings.Join([]string{namespace, "latency"}, "."),
+	if stability != semconvutil.StabilityOld {
+		if instruments.operationDuration, err = meter.Float64Histogram(
+			operationDurationInstrumentName,
+			metric.WithDescription("Duration of database client operations"),
+			metric.WithUnit("s"),
+			metric.WithExplicitBucketBoundaries(latencySecondsBucketBoundaries...),
+		); err != nil {
+			return nil, fmt.Errorf("failed to create operationDuration instrument, %v", err)
+		}
+	}
+
+	latencyOptions := []metric.Float64HistogramOption{
 		metric.WithDescription("The latency of calls in milliseconds"),
-		metric.WithUnit("ms"),
+		metric.WithUnit(string(DurationUnitMilliseconds)),
+		metric.WithExplicitBucketBoundaries(latencyBucketBoundaries...),
+	}
+	if unit == DurationUnitSeconds {
+		instruments.latencySeconds = true
+		latencyOptions = []metric.Float64HistogramOption{
+			metric.WithDescription("The latency of calls in seconds"),
+			metric.WithUnit(string(DurationUnitSeconds)),
+			metric.WithExplicitBucketBoundaries(latencySecondsBucketBoundaries...),
+		}
+	}
+	if stability != semconvutil.StabilityStable {
+		if instruments.latency, err = meter.Float64Histogram(latencyInstrumentName, latencyOptions...); err != nil {
+			return nil, fmt.Errorf("failed to create latency instrument, %v", err)
+		}
+	}
+
+	if instruments.commenterTruncated, err = meter.Int64Counter(
+		commenterTruncatedInstrumentName,
+		metric.WithDescription("The number of SQL comments truncated due to the length limit"),
+	); err != nil {
+		return nil, fmt.Errorf("failed to create commenterTruncated instrument, %v", err)
+	}
+
+	if instruments.commenterAddedBytes, err = meter.Int64Histogram(
+		commenterAddedBytesInstrumentName,
+		metric.WithDescription("The number of bytes added to statements by SQL comments"),
+		metric.WithUnit("By"),
+	); err != nil {
+		return nil, fmt.Errorf("failed to create commenterAddedBytes instrument, %v", err)
+	}
+
+	if instruments.timeToFirstRow, err = meter.Float64Histogram(
+		timeToFirstRowInstrumentName,
+		metric.WithDescription("The time between a query returning rows and the first row read"),
+		metric.WithUnit("s"),
+	); err != nil {
+		return nil, fmt.Errorf("failed to create timeToFirstRow instrument, %v", err)
+	}
+
+	if instruments.transactions, err = meter.Int64Counter(
+		transactionsInstrumentName,
+		metric.WithDescription("The number of transactions ended, by outcome"),
+	); err != nil {
+		return nil, fmt.Errorf("failed to create transactions instrument, %v", err)
+	}
+
+	if instruments.connectionErrors, err = meter.Int64Counter(
+		connectionErrorsInstrumentName,
+		metric.WithDescription("The number of calls failed with driver.ErrBadConn, which database/sql retries on another connection"),
 	); err != nil {
-		return nil, fmt.Errorf("failed to create latency instrument, %v", err)
+		return nil, fmt.Errorf("failed to create connectionErrors instrument, %v", err)
+	}
+
+	if instruments.inFlight, err = meter.Int64UpDownCounter(
+		inFlightInstrumentName,
+		metric.WithDescription("The number of calls in progress"),
+		metric.WithUnit("{call}"),
+	); err != nil {
+		return nil, fmt.Errorf("failed to create inFlight instrument, %v", err)
 	}
 	return &instruments, nil
 }
 
-func newDBStatsInstruments(meter metric.Meter) (*dbStatsInstruments, error) {
+func newDBStatsInstruments(cfg config) (*dbStatsInstruments, error) {
 	var instruments dbStatsInstruments
 	var err error
+	meter := cfg.Meter
 	subsystem := "connection"
+	name := func(suffix string, counter bool) string {
+		return dbStatsInstrumentName(cfg.PrometheusNaming, counter, namespace, subsystem, suffix)
+	}
 
-	if instruments.connectionMaxOpen, err = meter.Int64ObservableGauge(
-		strings.Join([]string{namespace, subsystem, "max_open"}, "."),
-		metric.WithDescription("Maximum number of open connections to the database"),
-	); err != nil {
-		return nil, fmt.Errorf("failed to create connectionMaxOpen instrument, %v", err)
+	if cfg.semconvStability != semconvutil.StabilityStable {
+		if instruments.connectionMaxOpen, err = meter.Int64ObservableGauge(
+			name("max_open", false),
+			metric.WithDescription("Maximum number of open connections to the database"),
+		); err != nil {
+			return nil, fmt.Errorf("failed to create connectionMaxOpen instrument, %v", err)
+		}
+
+		if instruments.connectionOpen, err = meter.Int64ObservableGauge(
+			name("open", false),
+			metric.WithDescription("The number of established connections both in use and idle"),
+		); err != nil {
+			return nil, fmt.Errorf("failed to create connectionOpen instrument, %v", err)
+		}
 	}
 
-	if instruments.connectionOpen, err = meter.Int64ObservableGauge(
-		strings.Join([]string{namespace, subsystem, "open"}, "."),
-		metric.WithDescription("The number of established connections both in use and idle"),
-	); err != nil {
-		return nil, fmt.Errorf("failed to create connectionOpen instrument, %v", err)
+	if cfg.semconvStability != semconvutil.StabilityOld {
+		if instruments.connectionCount, err = meter.Int64ObservableUpDownCounter(
+			dbStatsInstrumentName(cfg.PrometheusNaming, false, "db.client.connection", "count"),
+			metric.WithDescription("The number of connections that are currently in state described by the state attribute"),
+			metric.WithUnit("{connection}"),
+		); err != nil {
+			return nil, fmt.Errorf("failed to create connectionCount instrument, %v", err)
+		}
+
+		if instruments.connectionMax, err = meter.Int64ObservableUpDownCounter(
+			dbStatsInstrumentName(cfg.PrometheusNaming, false, "db.client.connection", "max"),
+			metric.WithDescription("The maximum number of open connections allowed"),
+			metric.WithUnit("{connection}"),
+		); err != nil {
+			return nil, fmt.Errorf("failed to create connectionMax instrument, %v", err)
+		}
 	}
 
 	if instruments.connectionWaitTotal, err = meter.Int64ObservableCounter(
-		strings.Join([]string{namespace, subsystem, "wait"}, "."),
+		name("wait", true),
 		metric.WithDescription("The total number of connections waited for"),
 	); err != nil {
 		return nil, fmt.Errorf("failed to create connectionWaitTotal instrument, %v", err)
 	}
 
+	unit := DurationUnitMilliseconds
+	if cfg.DurationUnit == DurationUnitSeconds {
+		unit = DurationUnitSeconds
+	}
 	if instruments.connectionWaitDurationTotal, err = meter.Float64ObservableCounter(
-		strings.Join([]string{namespace, subsystem, "wait_duration"}, "."),
+		name("wait_duration", true),
 		metric.WithDescription("The total time blocked waiting for a new connection"),
-		metric.WithUnit("ms"),
+		metric.WithUnit(string(unit)),
 	); err != nil {
 		return nil, fmt.Errorf("failed to create connectionWaitDurationTotal instrument, %v", err)
 	}
 
 	if instruments.connectionClosedMaxIdleTotal, err = meter.Int64ObservableCounter(
-		strings.Join([]string{namespace, subsystem, "closed_max_idle"}, "."),
+		name("closed_max_idle", true),
 		metric.WithDescription("The total number of connections closed due to SetMaxIdleConns"),
 	); err != nil {
 		return nil, fmt.Errorf("failed to create connectionClosedMaxIdleTotal instrument, %v", err)
 	}
 
 	if instruments.connectionClosedMaxIdleTimeTotal, err = meter.Int64ObservableCounter(
-		strings.Join([]string{namespace, subsystem, "closed_max_idle_time"}, "."),
+		name("closed_max_idle_time", true),
 		metric.WithDescription("The total number of connections closed due to SetConnMaxIdleTime"),
 	); err != nil {
 		return nil, fmt.Errorf("failed to create connectionClosedMaxIdleTimeTotal instrument, %v", err)
 	}
 
 	if instruments.connectionClosedMaxLifetimeTotal, err = meter.Int64ObservableCounter(
-		strings.Join([]string{namespace, subsystem, "closed_max_lifetime"}, "."),
+		name("closed_max_lifetime", true),
 		metric.WithDescription("The total number of connections closed due to SetConnMaxLifetime"),
 	); err != nil {
 		return nil, fmt.Errorf("failed to create connectionClosedMaxLifetimeTotal instrument, %v", err)
 	}
 
+	if cfg.PoolLimits != nil {
+		if cfg.semconvStability != semconvutil.StabilityStable {
+			if instruments.connectionMaxIdle, err = meter.Int64ObservableGauge(
+				name("max_idle", false),
+				metric.WithDescription("The maximum number of idle connections set with SetMaxIdleConns"),
+			); err != nil {
+				return nil, fmt.Errorf("failed to create connectionMaxIdle instrument, %v", err)
+			}
+		}
+
+		if cfg.semconvStability != semconvutil.StabilityOld {
+			if instruments.connectionIdleMax, err = meter.Int64ObservableUpDownCounter(
+				dbStatsInstrumentName(cfg.PrometheusNaming, false, "db.client.connection.idle", "max"),
+				metric.WithDescription("The maximum number of idle open connections allowed"),
+				metric.WithUnit("{connection}"),
+			); err != nil {
+				return nil, fmt.Errorf("failed to create connectionIdleMax instrument, %v", err)
+			}
+		}
+
+		if instruments.connectionMaxLifetime, err = meter.Float64ObservableGauge(
+			name("max_lifetime", false),
+			metric.WithDescription("The maximum lifetime of connections set with SetConnMaxLifetime, 0 if unlimited"),
+			metric.WithUnit("s"),
+		); err != nil {
+			return nil, fmt.Errorf("failed to create connectionMaxLifetime instrument, %v", err)
+		}
+
+		if instruments.connectionMaxIdleTime, err = meter.Float64ObservableGauge(
+			name("max_idle_time", false),
+			metric.WithDescription("The maximum idle time of connections set with SetConnMaxIdleTime, 0 if unlimited"),
+			metric.WithUnit("s"),
+		); err != nil {
+			return nil, fmt.Errorf("failed to create connectionMaxIdleTime instrument, %v", err)
+		}
+	}
+
+	return &instruments, nil
+}
+
+// dbStatsInstrumentName returns the name of a sql.DBStats instrument made of
+// parts. With Prometheus naming, the parts are joined with underscores and
+// the names of counters have the _total suffix, see WithPrometheusNaming.
+func dbStatsInstrumentName(prometheusNaming, counter bool, parts ...string) string {
+	if !prometheusNaming {
+		return strings.Join(parts, ".")
+	}
+	name := strings.ReplaceAll(strings.Join(parts, "_"), ".", "_")
+	if counter {
+		name += "_total"
+	}
+	return name
+}
+
+func newStmtCacheInstruments(meter metric.Meter) (*stmtCacheInstruments, error) {
+	var instruments stmtCacheInstruments
+	var err error
+	subsystem := "stmt_cache"
+
+	if instruments.hits, err = meter.Int64ObservableCounter(
+		strings.Join([]string{namespace, subsystem, "hits"}, "."),
+		metric.WithDescription("The total number of prepared statements found in the statement cache"),
+	); err != nil {
+		return nil, fmt.Errorf("failed to create stmtCacheHits instrument, %v", err)
+	}
+
+	if instruments.misses, err = meter.Int64ObservableCounter(
+		strings.Join([]string{namespace, subsystem, "misses"}, "."),
+		metric.WithDescription("The total number of prepared statements missing from the statement cache"),
+	); err != nil {
+		return nil, fmt.Errorf("failed to create stmtCacheMisses instrument, %v", err)
+	}
+
+	if instruments.evictions, err = meter.Int64ObservableCounter(
+		strings.Join([]string{namespace, subsystem, "evictions"}, "."),
+		metric.WithDescription("The total number of prepared statements evicted from the statement cache"),
+	); err != nil {
+		return nil, fmt.Errorf("failed to create stmtCacheEvictions instrument, %v", err)
+	}
+
+	if instruments.size, err = meter.Int64ObservableGauge(
+		strings.Join([]string{namespace, subsystem, "size"}, "."),
+		metric.WithDescription("The number of prepared statements in the statement cache"),
+	); err != nil {
+		return nil, fmt.Errorf("failed to create stmtCacheSize instrument, %v", err)
+	}
+
 	return &instruments, nil
 }
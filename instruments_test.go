@@ -15,23 +15,106 @@
 package otelsql
 
 import (
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.opentelemetry.io/otel/metric/noop"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	"github.com/XSAM/otelsql/semconvutil"
 )
 
 func TestNewInstruments(t *testing.T) {
-	instruments, err := newInstruments(noop.NewMeterProvider().Meter("test"))
+	instruments, err := newInstruments(noop.NewMeterProvider().Meter("test"), DurationUnitMilliseconds, semconvutil.StabilityOld)
 	require.NoError(t, err)
 
 	assert.NotNil(t, instruments)
 	assert.NotNil(t, instruments.latency)
+	assert.NotNil(t, instruments.commenterTruncated)
+}
+
+func TestNewInstruments_LatencyBucketBoundaries(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	meterProvider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	instruments, err := newInstruments(meterProvider.Meter("test"), DurationUnitMilliseconds, semconvutil.StabilityOld)
+	require.NoError(t, err)
+
+	instruments.latency.Record(context.Background(), 7)
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	require.Len(t, rm.ScopeMetrics, 1)
+	require.Len(t, rm.ScopeMetrics[0].Metrics, 1)
+	histogram, ok := rm.ScopeMetrics[0].Metrics[0].Data.(metricdata.Histogram[float64])
+	require.True(t, ok)
+	require.Len(t, histogram.DataPoints, 1)
+	assert.Equal(t, latencyBucketBoundaries, histogram.DataPoints[0].Bounds)
+}
+
+func TestNewInstruments_LatencySeconds(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	meterProvider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	instruments, err := newInstruments(meterProvider.Meter("test"), DurationUnitSeconds, semconvutil.StabilityOld)
+	require.NoError(t, err)
+	assert.True(t, instruments.latencySeconds)
+
+	instruments.latency.Record(context.Background(), 0.007)
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	require.Len(t, rm.ScopeMetrics, 1)
+	require.Len(t, rm.ScopeMetrics[0].Metrics, 1)
+	assert.Equal(t, "s", rm.ScopeMetrics[0].Metrics[0].Unit)
+	histogram, ok := rm.ScopeMetrics[0].Metrics[0].Data.(metricdata.Histogram[float64])
+	require.True(t, ok)
+	require.Len(t, histogram.DataPoints, 1)
+	assert.Equal(t, latencySecondsBucketBoundaries, histogram.DataPoints[0].Bounds)
+}
+
+func TestNewInstruments_OperationDuration(t *testing.T) {
+	testCases := []struct {
+		stability semconvutil.Stability
+		expected  []string
+	}{
+		{stability: semconvutil.StabilityOld, expected: []string{latencyInstrumentName}},
+		{stability: semconvutil.StabilityStable, expected: []string{operationDurationInstrumentName}},
+		{stability: semconvutil.StabilityDup, expected: []string{latencyInstrumentName, operationDurationInstrumentName}},
+	}
+
+	for _, tc := range testCases {
+		reader := sdkmetric.NewManualReader()
+		meterProvider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+		instruments, err := newInstruments(meterProvider.Meter("test"), DurationUnitMilliseconds, tc.stability)
+		require.NoError(t, err)
+
+		cfg := config{Instruments: instruments}
+		recordMetric(context.Background(), instruments, cfg, MethodConnQuery, "SELECT 1", nil)(nil)
+
+		var rm metricdata.ResourceMetrics
+		require.NoError(t, reader.Collect(context.Background(), &rm))
+		require.Len(t, rm.ScopeMetrics, 1)
+		var names []string
+		for _, m := range rm.ScopeMetrics[0].Metrics {
+			histogram, ok := m.Data.(metricdata.Histogram[float64])
+			if !ok {
+				continue
+			}
+			names = append(names, m.Name)
+			if m.Name == operationDurationInstrumentName {
+				assert.Equal(t, "s", m.Unit)
+				require.Len(t, histogram.DataPoints, 1)
+				assert.Equal(t, latencySecondsBucketBoundaries, histogram.DataPoints[0].Bounds)
+			}
+		}
+		assert.ElementsMatch(t, tc.expected, names)
+	}
 }
 
 func TestNewDBStatsInstruments(t *testing.T) {
-	instruments, err := newDBStatsInstruments(noop.NewMeterProvider().Meter("test"))
+	instruments, err := newDBStatsInstruments(config{Meter: noop.NewMeterProvider().Meter("test")})
 	require.NoError(t, err)
 
 	assert.NotNil(t, instruments)
@@ -43,3 +126,34 @@ func TestNewDBStatsInstruments(t *testing.T) {
 	assert.NotNil(t, instruments.connectionClosedMaxIdleTimeTotal)
 	assert.NotNil(t, instruments.connectionClosedMaxLifetimeTotal)
 }
+
+func TestDBStatsInstrumentName(t *testing.T) {
+	testCases := []struct {
+		name             string
+		prometheusNaming bool
+		counter          bool
+		expected         string
+	}{
+		{name: "gauge", expected: "db.sql.connection.open"},
+		{name: "counter", counter: true, expected: "db.sql.connection.open"},
+		{name: "prometheus gauge", prometheusNaming: true, expected: "db_sql_connection_open"},
+		{name: "prometheus counter", prometheusNaming: true, counter: true, expected: "db_sql_connection_open_total"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, dbStatsInstrumentName(tc.prometheusNaming, tc.counter, namespace, "connection", "open"))
+		})
+	}
+}
+
+func TestNewStmtCacheInstruments(t *testing.T) {
+	instruments, err := newStmtCacheInstruments(noop.NewMeterProvider().Meter("test"))
+	require.NoError(t, err)
+
+	assert.NotNil(t, instruments)
+	assert.NotNil(t, instruments.hits)
+	assert.NotNil(t, instruments.misses)
+	assert.NotNil(t, instruments.evictions)
+	assert.NotNil(t, instruments.size)
+}
@@ -0,0 +1,207 @@
+// Copyright Sam Xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otelsql
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"slices"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	metricnoop "go.opentelemetry.io/otel/metric/noop"
+
+	"github.com/XSAM/otelsql/semconvutil"
+)
+
+// Configuration is a read-only snapshot of the effective configuration of an
+// instrumented driver, after all options have been applied. It allows
+// asserting the instrumentation in integration tests or reporting it on
+// admin endpoints.
+//
+// Notice: This type is EXPERIMENTAL and may be changed or removed in a later
+// release.
+type Configuration struct {
+	// DBSystem is the db.system set by WithDBSystem.
+	DBSystem string
+	// Attributes are the attributes set on every span and measurement,
+	// including db.system. Attributes added per data source name, see
+	// WithServerAttributesFromDSN, are not included.
+	Attributes []attribute.KeyValue
+	// SpanOptions are the options of the created spans.
+	SpanOptions SpanOptions
+	// SemconvStability is the version of the semantic conventions opted in
+	// with OTEL_SEMCONV_STABILITY_OPT_IN.
+	SemconvStability semconvutil.Stability
+	// SchemaURL identifies the version of the semantic conventions followed
+	// by the recorded attributes.
+	SchemaURL string
+	// Instruments are the names of the metric instruments recorded on calls,
+	// including the db.sql.oldest_call.age gauge if enabled. It is empty if
+	// the instruments could not be created.
+	Instruments []string
+	// DBStatsInstruments are the names of the metric instruments recorded by
+	// RegisterDBStatsMetrics with the same options, including the pool limits
+	// set with WithPoolLimits.
+	DBStatsInstruments []string
+
+	SQLCommenter               bool
+	ApplicationNamePropagation bool
+	SessionContextPropagation  bool
+	MySQLConnectionAttributes  bool
+	ServerVersionProbe         bool
+	DatabaseNameProbe          bool
+	ServerAttributesFromDSN    bool
+	ErrorTraceIDs              bool
+	PprofLabels                bool
+	SavepointDetection         bool
+	DisableSkipErrMeasurement  bool
+	DisableRowsMeasurement     bool
+	QuerySummaryMetricLimit    int
+	CollectionNameOnMetrics    bool
+	TenantAttribute            bool
+	QueryAggregator            bool
+	OldestCallMetric           bool
+	AuditLog                   bool
+	DurationUnit               DurationUnit
+	TenantMetricLimit          int
+}
+
+// ConfigOf returns the configuration of db if it was opened with an
+// instrumented driver, e.g. by Open or OpenDB.
+func ConfigOf(db *sql.DB) (Configuration, bool) {
+	return DriverConfigOf(db.Driver())
+}
+
+// DriverConfigOf returns the configuration of d if it is an instrumented
+// driver, e.g. returned by WrapDriver.
+func DriverConfigOf(d driver.Driver) (Configuration, bool) {
+	if wrapped, ok := d.(struct{ driver.Driver }); ok {
+		d = wrapped.Driver
+	}
+	otDriver, ok := d.(*otDriver)
+	if !ok {
+		return Configuration{}, false
+	}
+	return otDriver.cfg.configuration(), true
+}
+
+// configuration returns the snapshot of cfg.
+func (cfg config) configuration() Configuration {
+	c := Configuration{
+		DBSystem:                   cfg.DBSystem,
+		Attributes:                 slices.Clone(cfg.Attributes),
+		SpanOptions:                cfg.SpanOptions,
+		SemconvStability:           cfg.semconvStability,
+		SchemaURL:                  semconvutil.SchemaURL(cfg.semconvStability),
+		SQLCommenter:               cfg.SQLCommenterEnabled,
+		ApplicationNamePropagation: cfg.ApplicationNamePropagation,
+		SessionContextPropagation:  cfg.SessionContextPropagation,
+		MySQLConnectionAttributes:  cfg.MySQLConnectionAttributes != nil,
+		ServerVersionProbe:         cfg.ServerVersionProbe,
+		DatabaseNameProbe:          cfg.DatabaseNameProbe,
+		ServerAttributesFromDSN:    cfg.ServerAttributesFromDSN,
+		ErrorTraceIDs:              cfg.ErrorTraceIDs,
+		PprofLabels:                cfg.PprofLabels,
+		SavepointDetection:         cfg.SavepointDetection,
+		DisableSkipErrMeasurement:  cfg.DisableSkipErrMeasurement,
+		DisableRowsMeasurement:     cfg.DisableRowsMeasurement,
+		QuerySummaryMetricLimit:    cfg.QuerySummaryMetricLimit,
+		CollectionNameOnMetrics:    cfg.CollectionNameOnMetrics,
+		TenantAttribute:            cfg.TenantGetter != nil,
+		TenantMetricLimit:          cfg.TenantMetricLimit,
+		QueryAggregator:            cfg.QueryAggregator != nil,
+		OldestCallMetric:           cfg.OldestCallMetric,
+		AuditLog:                   cfg.AuditRecorder != nil,
+		DurationUnit:               cfg.DurationUnit,
+	}
+	if cfg.Instruments != nil {
+		c.Instruments = instrumentNames(func(meter metric.Meter) error {
+			_, err := newInstruments(meter, cfg.DurationUnit, cfg.semconvStability)
+			return err
+		})
+		if cfg.OldestCallMetric {
+			c.Instruments = append(c.Instruments, oldestCallAgeInstrumentName)
+		}
+	}
+	c.DBStatsInstruments = instrumentNames(func(meter metric.Meter) error {
+		statsCfg := cfg
+		statsCfg.Meter = meter
+		_, err := newDBStatsInstruments(statsCfg)
+		return err
+	})
+	return c
+}
+
+// instrumentNames returns the names of the instruments created by create, or
+// nil if it fails.
+func instrumentNames(create func(metric.Meter) error) []string {
+	meter := &namesMeter{}
+	if err := create(meter); err != nil {
+		return nil
+	}
+	return meter.names
+}
+
+// namesMeter is a no-op meter keeping the names of the created instruments.
+type namesMeter struct {
+	metricnoop.Meter
+	names []string
+}
+
+func (m *namesMeter) Int64Counter(name string, opts ...metric.Int64CounterOption) (metric.Int64Counter, error) {
+	m.names = append(m.names, name)
+	return m.Meter.Int64Counter(name, opts...)
+}
+
+func (m *namesMeter) Int64UpDownCounter(name string, opts ...metric.Int64UpDownCounterOption) (metric.Int64UpDownCounter, error) {
+	m.names = append(m.names, name)
+	return m.Meter.Int64UpDownCounter(name, opts...)
+}
+
+func (m *namesMeter) Int64Histogram(name string, opts ...metric.Int64HistogramOption) (metric.Int64Histogram, error) {
+	m.names = append(m.names, name)
+	return m.Meter.Int64Histogram(name, opts...)
+}
+
+func (m *namesMeter) Int64ObservableCounter(name string, opts ...metric.Int64ObservableCounterOption) (metric.Int64ObservableCounter, error) {
+	m.names = append(m.names, name)
+	return m.Meter.Int64ObservableCounter(name, opts...)
+}
+
+func (m *namesMeter) Int64ObservableUpDownCounter(name string, opts ...metric.Int64ObservableUpDownCounterOption) (metric.Int64ObservableUpDownCounter, error) {
+	m.names = append(m.names, name)
+	return m.Meter.Int64ObservableUpDownCounter(name, opts...)
+}
+
+func (m *namesMeter) Int64ObservableGauge(name string, opts ...metric.Int64ObservableGaugeOption) (metric.Int64ObservableGauge, error) {
+	m.names = append(m.names, name)
+	return m.Meter.Int64ObservableGauge(name, opts...)
+}
+
+func (m *namesMeter) Float64Histogram(name string, opts ...metric.Float64HistogramOption) (metric.Float64Histogram, error) {
+	m.names = append(m.names, name)
+	return m.Meter.Float64Histogram(name, opts...)
+}
+
+func (m *namesMeter) Float64ObservableCounter(name string, opts ...metric.Float64ObservableCounterOption) (metric.Float64ObservableCounter, error) {
+	m.names = append(m.names, name)
+	return m.Meter.Float64ObservableCounter(name, opts...)
+}
+
+func (m *namesMeter) Float64ObservableGauge(name string, opts ...metric.Float64ObservableGaugeOption) (metric.Float64ObservableGauge, error) {
+	m.names = append(m.names, name)
+	return m.Meter.Float64ObservableGauge(name, opts...)
+}
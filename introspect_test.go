@@ -0,0 +1,107 @@
+// Copyright Sam Xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !otelsql_noop
+
+package otelsql
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.18.0"
+
+	"github.com/XSAM/otelsql/semconvutil"
+)
+
+func TestConfigOf(t *testing.T) {
+	options := []Option{
+		WithDBSystem("postgresql"),
+		WithSpanOptions(SpanOptions{OmitRows: true}),
+		WithQuerySummaryMetricAttribute(10),
+		WithSQLCommenter(true),
+	}
+
+	md := newMockDriver(false)
+	drivers := map[string]driver.Driver{
+		"WrapDriver":                       WrapDriver(md, options...),
+		"WrapDriver without DriverContext": WrapDriver(struct{ driver.Driver }{md}, options...),
+		"OpenDB":                           OpenDB(newMockConnector(md, false), options...).Driver(),
+	}
+	for name, d := range drivers {
+		t.Run(name, func(t *testing.T) {
+			c, ok := DriverConfigOf(d)
+			require.True(t, ok)
+			assert.Equal(t, "postgresql", c.DBSystem)
+			assert.Equal(t, []attribute.KeyValue{semconv.DBSystemKey.String("postgresql")}, c.Attributes)
+			assert.True(t, c.SpanOptions.OmitRows)
+			assert.Equal(t, semconvutil.StabilityOld, c.SemconvStability)
+			assert.Equal(t, semconv.SchemaURL, c.SchemaURL)
+			assert.Equal(t, []string{"db.sql.latency", "db.sql.commenter.truncated", "db.sql.commenter.added_bytes", "db.client.response.time_to_first_row", "db.sql.transactions", "db.client.connection.errors", "db.sql.in_flight"}, c.Instruments)
+			assert.True(t, c.SQLCommenter)
+			assert.Equal(t, 10, c.QuerySummaryMetricLimit)
+			assert.False(t, c.PprofLabels)
+
+			// The snapshot is read-only.
+			c.Attributes[0] = attribute.String("foo", "bar")
+			c, _ = DriverConfigOf(d)
+			assert.Equal(t, semconv.DBSystemKey.String("postgresql"), c.Attributes[0])
+		})
+	}
+
+	db := OpenDB(newMockConnector(md, false), options...)
+	defer db.Close()
+	c, ok := ConfigOf(db)
+	require.True(t, ok)
+	assert.Equal(t, "postgresql", c.DBSystem)
+}
+
+func TestConfigOf_NotInstrumented(t *testing.T) {
+	db := sql.OpenDB(newMockConnector(newMockDriver(false), false))
+	defer db.Close()
+
+	_, ok := ConfigOf(db)
+	assert.False(t, ok)
+	_, ok = DriverConfigOf(newMockDriver(false))
+	assert.False(t, ok)
+}
+
+func TestConfigOf_Instruments(t *testing.T) {
+	t.Setenv(semconvutil.OptInEnvKey, "database")
+	d := WrapDriver(newMockDriver(false), WithOldestCallMetric(false), WithPoolLimits(PoolLimits{MaxOpenConns: 10}))
+
+	c, ok := DriverConfigOf(d)
+	require.True(t, ok)
+	assert.Equal(t, semconvutil.StabilityStable, c.SemconvStability)
+	assert.Equal(t, semconvutil.SchemaURL(semconvutil.StabilityStable), c.SchemaURL)
+	assert.Contains(t, c.Instruments, "db.client.operation.duration")
+	assert.NotContains(t, c.Instruments, "db.sql.latency")
+	assert.Contains(t, c.Instruments, "db.sql.oldest_call.age")
+	assert.Equal(t, []string{
+		"db.client.connection.count",
+		"db.client.connection.max",
+		"db.sql.connection.wait",
+		"db.sql.connection.wait_duration",
+		"db.sql.connection.closed_max_idle",
+		"db.sql.connection.closed_max_idle_time",
+		"db.sql.connection.closed_max_lifetime",
+		"db.client.connection.idle.max",
+		"db.sql.connection.max_lifetime",
+		"db.sql.connection.max_idle_time",
+	}, c.DBStatsInstruments)
+}
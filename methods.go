@@ -33,8 +33,43 @@ const (
 	MethodStmtExec         Method = "sql.stmt.exec"
 	MethodStmtQuery        Method = "sql.stmt.query"
 	MethodRows             Method = "sql.rows"
+
+	// MethodTxSavepoint, MethodTxRollbackTo, and MethodTxReleaseSavepoint are
+	// only used when savepoint detection is enabled by WithSavepointDetection.
+	MethodTxSavepoint        Method = "sql.tx.savepoint"
+	MethodTxRollbackTo       Method = "sql.tx.rollback_to"
+	MethodTxReleaseSavepoint Method = "sql.tx.release_savepoint"
+
+	// MethodDBQuery, MethodDBQueryRow, MethodDBExec, and MethodDBBeginTx are
+	// only used by the API-level spans of DB.
+	MethodDBQuery    Method = "sql.db.query"
+	MethodDBQueryRow Method = "sql.db.query_row"
+	MethodDBExec     Method = "sql.db.exec"
+	MethodDBBeginTx  Method = "sql.db.begin_tx"
 )
 
 const (
-	EventRowsNext Event = "sql.rows.next"
+	EventRowsNext     Event = "sql.rows.next"
+	EventRowsProgress Event = "sql.rows.progress"
+
+	// EventLargeResultSet is added once to the rows span when more rows than
+	// SpanOptions.LargeResultSetRows are read.
+	EventLargeResultSet Event = "db.response.large_result_set"
+
+	// EventRowsCloseError is added to the rows span in place of the span
+	// error when closing the rows fails, if
+	// SpanOptions.RowsCloseErrorAsEvent is set.
+	EventRowsCloseError Event = "sql.rows.close.error"
+
+	// EventTxCommitStart and EventTxRollbackStart are added to the span of
+	// the context the transaction began with when the transaction ends,
+	// unless SpanOptions.CompactSpans is set, as the method events already
+	// mark the start.
+	EventTxCommitStart   Event = "sql.tx.commit.start"
+	EventTxRollbackStart Event = "sql.tx.rollback.start"
+
+	// EventConnectRetry is added to the sql.connector.connect span when the
+	// driver returns driver.ErrBadConn, which database/sql retries with a new
+	// connection.
+	EventConnectRetry Event = "sql.connector.connect.retry"
 )
@@ -0,0 +1,426 @@
+// Copyright Sam Xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !otelsql_noop
+
+package otelsql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric/noop"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	semconv "go.opentelemetry.io/otel/semconv/v1.18.0"
+
+	"github.com/XSAM/otelsql/semconvutil"
+)
+
+func TestRecordMetric(t *testing.T) {
+	type args struct {
+		ctx    context.Context
+		cfg    config
+		method Method
+		query  string
+		args   []driver.NamedValue
+	}
+	tests := []struct {
+		name           string
+		args           args
+		recordErr      error
+		expectedStatus string
+	}{
+		{
+			name: "metric with no error",
+			args: args{
+				cfg:    newConfig(),
+				method: MethodConnQuery,
+				query:  "example query",
+			},
+			recordErr:      nil,
+			expectedStatus: "ok",
+		},
+		{
+			name: "metric with an error",
+			args: args{
+				cfg:    newConfig(),
+				method: MethodConnQuery,
+				query:  "example query",
+			},
+			recordErr:      assert.AnError,
+			expectedStatus: "error",
+		},
+		{
+			name: "metric with skip error but not disabled",
+			args: args{
+				cfg:    newConfig(),
+				method: MethodConnQuery,
+				query:  "example query",
+			},
+			recordErr:      driver.ErrSkip,
+			expectedStatus: "error",
+		},
+		{
+			name: "metric with skip error but disabled",
+			args: args{
+				cfg:    newConfig(WithDisableSkipErrMeasurement(true)),
+				method: MethodConnQuery,
+				query:  "example query",
+			},
+			recordErr:      driver.ErrSkip,
+			expectedStatus: "ok",
+		},
+		{
+			name: "metric with an expected error",
+			args: args{
+				cfg:    newConfig(WithExpectedErrors(func(err error) bool { return err == assert.AnError })),
+				method: MethodConnQuery,
+				query:  "example query",
+			},
+			recordErr:      assert.AnError,
+			expectedStatus: "ok",
+		},
+		{
+			name: "metric with an unexpected error",
+			args: args{
+				cfg:    newConfig(WithExpectedErrors(func(err error) bool { return err == driver.ErrBadConn })),
+				method: MethodConnQuery,
+				query:  "example query",
+			},
+			recordErr:      assert.AnError,
+			expectedStatus: "error",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockLatency := &float64HistogramMock{}
+			mockInstruments := &instruments{
+				latency: mockLatency,
+			}
+			recordFunc := recordMetric(tt.args.ctx, mockInstruments, tt.args.cfg, tt.args.method, tt.args.query, tt.args.args)
+			recordFunc(tt.recordErr)
+			assert.Equal(t, tt.expectedStatus, mockLatency.status)
+		})
+	}
+}
+
+func TestRecordMetricExpectedErrorType(t *testing.T) {
+	t.Setenv(semconvutil.OptInEnvKey, "database/dup")
+	mockLatency := &float64HistogramMock{}
+	mockInstruments := &instruments{
+		latency: mockLatency,
+	}
+	cfg := newConfig(WithExpectedErrors(func(err error) bool { return err == sql.ErrNoRows }))
+
+	recordMetric(context.Background(), mockInstruments, cfg, MethodConnQuery, "", nil)(sql.ErrNoRows)
+	assert.False(t, mockLatency.attributes.HasValue("error.type"))
+
+	recordMetric(context.Background(), mockInstruments, cfg, MethodConnQuery, "", nil)(assert.AnError)
+	assert.True(t, mockLatency.attributes.HasValue("error.type"))
+}
+
+func TestRecordMetricWithExtraAttributes(t *testing.T) {
+	mockLatency := &float64HistogramMock{}
+	mockInstruments := &instruments{
+		latency: mockLatency,
+	}
+	cfg := config{Attributes: []attribute.KeyValue{attribute.String("foo", "bar")}}
+
+	recordFunc := recordMetric(context.Background(), mockInstruments, cfg, MethodConnBeginTx, "", nil,
+		attribute.String("extra", "value"),
+	)
+	recordFunc(nil)
+
+	assert.Equal(t, attribute.NewSet(
+		attribute.String("foo", "bar"),
+		attribute.String("extra", "value"),
+		queryStatusKey.String("ok"),
+		queryMethodKey.String(string(MethodConnBeginTx)),
+	), mockLatency.attributes)
+	assert.Len(t, cfg.Attributes, 1)
+}
+
+func TestRecordMetricWithQuerySummary(t *testing.T) {
+	mockLatency := &float64HistogramMock{}
+	mockInstruments := &instruments{
+		latency: mockLatency,
+	}
+	cfg := config{querySummaries: newQuerySummaryLimiter(1)}
+
+	recordMetric(context.Background(), mockInstruments, cfg, MethodConnQuery, "SELECT * FROM orders WHERE id = ?", nil)(nil)
+	summary, _ := mockLatency.attributes.Value(dbQuerySummaryKey)
+	assert.Equal(t, "SELECT orders", summary.AsString())
+
+	recordMetric(context.Background(), mockInstruments, cfg, MethodConnQuery, "DELETE FROM orders", nil)(nil)
+	summary, _ = mockLatency.attributes.Value(dbQuerySummaryKey)
+	assert.Equal(t, querySummaryOverflow, summary.AsString())
+
+	recordMetric(context.Background(), mockInstruments, cfg, MethodConnBeginTx, "", nil)(nil)
+	assert.False(t, mockLatency.attributes.HasValue(dbQuerySummaryKey))
+}
+
+func TestRecordMetricWithCollectionName(t *testing.T) {
+	mockLatency := &float64HistogramMock{}
+	mockInstruments := &instruments{
+		latency: mockLatency,
+	}
+	cfg := newConfig(WithCollectionNameOnMetrics(true))
+
+	recordMetric(context.Background(), mockInstruments, cfg, MethodConnQuery, "SELECT * FROM orders WHERE id = ?", nil)(nil)
+	name, _ := mockLatency.attributes.Value(dbCollectionNameKey)
+	assert.Equal(t, "orders", name.AsString())
+
+	recordMetric(context.Background(), mockInstruments, cfg, MethodConnBeginTx, "", nil)(nil)
+	assert.False(t, mockLatency.attributes.HasValue(dbCollectionNameKey))
+
+	// The stable duration metric has the name as well.
+	mockDuration := &float64HistogramMock{}
+	mockInstruments = &instruments{operationDuration: mockDuration}
+	recordMetric(context.Background(), mockInstruments, cfg, MethodConnQuery, "SELECT * FROM orders WHERE id = ?", nil)(nil)
+	name, _ = mockDuration.attributes.Value(dbCollectionNameKey)
+	assert.Equal(t, "orders", name.AsString())
+}
+
+func TestRecordMetricWithTenant(t *testing.T) {
+	mockLatency := &float64HistogramMock{}
+	mockInstruments := &instruments{
+		latency: mockLatency,
+	}
+	cfg := newConfig(WithTenantAttribute(tenantFromContext, 1))
+
+	for _, tc := range []struct {
+		tenant   string
+		expected string
+	}{
+		{tenant: "acme", expected: "acme"},
+		{tenant: "globex", expected: querySummaryOverflow},
+	} {
+		ctx := context.WithValue(context.Background(), tenantContextKey{}, tc.tenant)
+		recordMetric(ctx, mockInstruments, cfg, MethodConnQuery, "SELECT 1", nil)(nil)
+		tenant, _ := mockLatency.attributes.Value(tenantIDKey)
+		assert.Equal(t, tc.expected, tenant.AsString())
+	}
+
+	cfg = newConfig(WithTenantAttribute(tenantFromContext, 0))
+	ctx := context.WithValue(context.Background(), tenantContextKey{}, "acme")
+	recordMetric(ctx, mockInstruments, cfg, MethodConnQuery, "SELECT 1", nil)(nil)
+	assert.False(t, mockLatency.attributes.HasValue(tenantIDKey))
+}
+
+func TestRecordMetricWithErrorCategory(t *testing.T) {
+	mockLatency := &float64HistogramMock{}
+	mockInstruments := &instruments{
+		latency: mockLatency,
+	}
+
+	recordFunc := recordMetric(context.Background(), mockInstruments, config{}, MethodConnExec, "", nil)
+	recordFunc(mockSQLStateError("40P01"))
+
+	assert.Equal(t, attribute.NewSet(
+		queryStatusKey.String("error"),
+		dbErrorCategoryKey.String(errorCategoryDeadlock),
+		queryMethodKey.String(string(MethodConnExec)),
+	), mockLatency.attributes)
+}
+
+func TestRecordMetricWithNoopMeter(t *testing.T) {
+	cfg := newConfig(
+		WithMeterProvider(noop.NewMeterProvider()),
+		WithAttributes(attribute.String("foo", "bar")),
+	)
+	require.True(t, cfg.noopMeter)
+	ctx := context.Background()
+
+	allocs := testing.AllocsPerRun(100, func() {
+		recordMetric(ctx, cfg.Instruments, cfg, MethodConnQuery, "SELECT 1", nil)(nil)
+	})
+	assert.Zero(t, allocs)
+
+	// Driver calls made through DB are still observed.
+	call := &apiCall{}
+	recordMetric(context.WithValue(ctx, apiCallContextKey{}, call), cfg.Instruments, cfg, MethodConnQuery, "", nil)(driver.ErrBadConn)
+	assert.EqualValues(t, 1, call.retries.Load())
+}
+
+func TestRecordMetricConnectionErrors(t *testing.T) {
+	r := sdkmetric.NewManualReader()
+	cfg := newConfig(WithMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(r))))
+	ctx := context.Background()
+
+	recordMetric(ctx, cfg.Instruments, cfg, MethodConnQuery, "", nil)(driver.ErrBadConn)
+	recordMetric(ctx, cfg.Instruments, cfg, MethodConnQuery, "", nil)(fmt.Errorf("query: %w", driver.ErrBadConn))
+	recordMetric(ctx, cfg.Instruments, cfg, MethodConnExec, "", nil)(errors.New("error"))
+	recordMetric(ctx, cfg.Instruments, cfg, MethodConnExec, "", nil)(nil)
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, r.Collect(ctx, &rm))
+	require.Len(t, rm.ScopeMetrics, 1)
+	var found bool
+	for _, m := range rm.ScopeMetrics[0].Metrics {
+		if m.Name != connectionErrorsInstrumentName {
+			continue
+		}
+		found = true
+		sum := m.Data.(metricdata.Sum[int64])
+		require.Len(t, sum.DataPoints, 1)
+		assert.EqualValues(t, 2, sum.DataPoints[0].Value)
+		assert.Equal(t, attribute.NewSet(queryMethodKey.String(string(MethodConnQuery))), sum.DataPoints[0].Attributes)
+	}
+	assert.True(t, found)
+}
+
+func TestRecordMetricWithNamespace(t *testing.T) {
+	mockLatency := &float64HistogramMock{}
+	mockInstruments := &instruments{
+		latency: mockLatency,
+	}
+	cfg := newConfig(WithAttributes(semconv.DBName("app")))
+
+	recordMetric(context.Background(), mockInstruments, cfg, MethodConnQuery, "SELECT 1", nil)(nil)
+	name, _ := mockLatency.attributes.Value(semconv.DBNameKey)
+	assert.Equal(t, "app", name.AsString())
+
+	ctx := contextWithNamespace(context.Background(), "reporting")
+	recordMetric(ctx, mockInstruments, cfg, MethodConnQuery, "SELECT 1", nil)(nil)
+	name, _ = mockLatency.attributes.Value(semconv.DBNameKey)
+	assert.Equal(t, "reporting", name.AsString())
+
+	// The stable conventions record the namespace as db.namespace.
+	mockDuration := &float64HistogramMock{}
+	mockInstruments.operationDuration = mockDuration
+	cfg.semconvStability = semconvutil.StabilityStable
+	recordMetric(ctx, mockInstruments, cfg, MethodConnQuery, "SELECT 1", nil)(nil)
+	name, _ = mockDuration.attributes.Value(dbNamespaceKey)
+	assert.Equal(t, "reporting", name.AsString())
+}
+
+func TestRecordMetricOperationDuration(t *testing.T) {
+	mockLatency := &float64HistogramMock{}
+	mockDuration := &float64HistogramMock{}
+	cfg := config{
+		Attributes:       []attribute.KeyValue{defaultattribute},
+		semconvStability: semconvutil.StabilityDup,
+	}
+	mockInstruments := &instruments{latency: mockLatency, operationDuration: mockDuration}
+
+	recordMetric(context.Background(), mockInstruments, cfg, MethodConnQuery, "SELECT 1", nil)(errors.New("failed"))
+
+	assert.Equal(t, attribute.NewSet(
+		defaultattribute,
+		semconvutil.ErrorTypeAttributes(errors.New("failed"), semconvutil.StabilityDup)[0],
+		queryMethodKey.String(string(MethodConnQuery)),
+		queryStatusKey.String("error"),
+	), mockLatency.attributes)
+	// The stable histogram reports the failure with error.type only.
+	assert.Equal(t, attribute.NewSet(
+		defaultattribute,
+		semconvutil.ErrorTypeAttributes(errors.New("failed"), semconvutil.StabilityDup)[0],
+		queryMethodKey.String(string(MethodConnQuery)),
+		dbOperationNameKey.String("SELECT"),
+	), mockDuration.attributes)
+}
+
+func TestRecordMetricInFlight(t *testing.T) {
+	r := sdkmetric.NewManualReader()
+	cfg := newConfig(WithMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(r))))
+	ctx := context.Background()
+
+	inFlight := func() int64 {
+		var rm metricdata.ResourceMetrics
+		require.NoError(t, r.Collect(ctx, &rm))
+		require.Len(t, rm.ScopeMetrics, 1)
+		for _, m := range rm.ScopeMetrics[0].Metrics {
+			if m.Name != inFlightInstrumentName {
+				continue
+			}
+			sum := m.Data.(metricdata.Sum[int64])
+			require.Len(t, sum.DataPoints, 1)
+			assert.Equal(t, attribute.NewSet(queryMethodKey.String(string(MethodConnQuery))), sum.DataPoints[0].Attributes)
+			return sum.DataPoints[0].Value
+		}
+		t.Fatalf("%s not recorded", inFlightInstrumentName)
+		return 0
+	}
+
+	first := recordMetric(ctx, cfg.Instruments, cfg, MethodConnQuery, "", nil)
+	second := recordMetric(ctx, cfg.Instruments, cfg, MethodConnQuery, "", nil)
+	assert.EqualValues(t, 2, inFlight())
+
+	first(nil)
+	assert.EqualValues(t, 1, inFlight())
+	second(errors.New("error"))
+	assert.EqualValues(t, 0, inFlight())
+}
+
+func TestOtRows_CloseErrorAsEventMetric(t *testing.T) {
+	for _, asEvent := range []bool{false, true} {
+		t.Run(fmt.Sprintf("asEvent=%t", asEvent), func(t *testing.T) {
+			ctx, _, tracer, _ := prepareTraces(false)
+			mockLatency := &float64HistogramMock{}
+			cfg := newMockConfig(t, tracer)
+			cfg.Instruments = &instruments{latency: mockLatency}
+			cfg.SpanOptions.RowsCloseErrorAsEvent = asEvent
+
+			require.Error(t, newRows(ctx, newMockRows(true), cfg).Close())
+
+			expectedStatus := "error"
+			if asEvent {
+				expectedStatus = "ok"
+			}
+			assert.Equal(t, expectedStatus, mockLatency.status)
+		})
+	}
+}
+
+func TestOtTx_TransactionsMetric(t *testing.T) {
+	r := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(r))
+	cfg := newConfig(WithMeterProvider(mp), WithAttributes(semconv.DBSystemMySQL))
+	ctx := context.Background()
+
+	require.NoError(t, newTx(ctx, newMockTx(false), cfg).Commit())
+	require.NoError(t, newTx(ctx, newMockTx(false), cfg).Commit())
+	require.NoError(t, newTx(ctx, newMockTx(false), cfg).Rollback())
+	require.Error(t, newTx(ctx, newMockTx(true), cfg).Commit())
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, r.Collect(ctx, &rm))
+	require.Len(t, rm.ScopeMetrics, 1)
+
+	var sum metricdata.Sum[int64]
+	for _, m := range rm.ScopeMetrics[0].Metrics {
+		if m.Name == transactionsInstrumentName {
+			sum = m.Data.(metricdata.Sum[int64])
+		}
+	}
+	counts := make(map[string]int64)
+	for _, dp := range sum.DataPoints {
+		assert.True(t, dp.Attributes.HasValue(semconv.DBSystemKey))
+		outcome, _ := dp.Attributes.Value("db.sql.tx.outcome")
+		counts[outcome.AsString()] = dp.Value
+	}
+	assert.Equal(t, map[string]int64{
+		txOutcomeCommitted:  2,
+		txOutcomeRolledBack: 1,
+		txOutcomeAborted:    1,
+	}, counts)
+}
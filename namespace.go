@@ -0,0 +1,108 @@
+// Copyright Sam Xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otelsql
+
+import (
+	"strings"
+)
+
+// namespaceSwitch returns the database or schema that query switches the
+// session to, if it is a USE, SET search_path, or SET SCHEMA statement. For a
+// search path, it is its first schema other than "$user".
+func namespaceSwitch(query string) (string, bool) {
+	query = strings.TrimSuffix(strings.TrimSpace(query), ";")
+
+	var name string
+	switch {
+	case hasPrefixFold(query, "USE "):
+		name = query[len("USE "):]
+	case hasPrefixFold(query, "SET "):
+		rest := strings.TrimSpace(query[len("SET "):])
+		switch {
+		case hasPrefixFold(rest, "SCHEMA "):
+			name = rest[len("SCHEMA "):]
+		case hasPrefixFold(rest, "search_path"):
+			rest = strings.TrimSpace(rest[len("search_path"):])
+			switch {
+			case hasPrefixFold(rest, "TO "):
+				rest = rest[len("TO "):]
+			case strings.HasPrefix(rest, "="):
+				rest = rest[len("="):]
+			default:
+				return "", false
+			}
+			for _, schema := range strings.Split(rest, ",") {
+				if name = unquoteIdentifier(strings.TrimSpace(schema)); name != "$user" {
+					break
+				}
+			}
+		default:
+			return "", false
+		}
+	default:
+		return "", false
+	}
+
+	name = unquoteIdentifier(strings.TrimSpace(name))
+	if name == "" || name == "$user" || strings.ContainsAny(name, " \t\n") {
+		return "", false
+	}
+	return name, true
+}
+
+func hasPrefixFold(s, prefix string) bool {
+	return len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix)
+}
+
+// unquoteIdentifier removes the quotes, backticks, or brackets around name.
+func unquoteIdentifier(name string) string {
+	if len(name) < 2 {
+		return name
+	}
+	switch first, last := name[0], name[len(name)-1]; {
+	case first == '\'' && last == '\'', first == '"' && last == '"',
+		first == '`' && last == '`', first == '[' && last == ']':
+		return name[1 : len(name)-1]
+	}
+	return name
+}
+
+// trackNamespaceDeferred records the database or schema the session switched
+// to if query is a USE or SET search_path statement that succeeded, so that
+// the spans of the later calls on the connection have the current db.name.
+// It is nil-safe.
+func (c *otConn) trackNamespaceDeferred(query string, err *error) {
+	if c == nil || *err != nil {
+		return
+	}
+	if name, ok := namespaceSwitch(query); ok {
+		// Unlike USE, SET statements are reverted when the transaction is
+		// rolled back.
+		if c.inTx && c.namespaceBeforeTx == nil && hasPrefixFold(strings.TrimSpace(query), "SET ") {
+			namespace := c.namespace
+			c.namespaceBeforeTx = &namespace
+		}
+		c.namespace = name
+	}
+}
+
+// currentNamespace returns the database or schema the session switched to,
+// if any. It is nil-safe.
+func (c *otConn) currentNamespace() string {
+	if c == nil {
+		return ""
+	}
+	return c.namespace
+}
@@ -0,0 +1,129 @@
+// Copyright Sam Xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otelsql
+
+import (
+	"database/sql/driver"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.18.0"
+
+	"github.com/XSAM/otelsql/semconvutil"
+)
+
+func TestNamespaceSwitch(t *testing.T) {
+	testCases := []struct {
+		query    string
+		expected string
+	}{
+		{query: "USE orders", expected: "orders"},
+		{query: "use `orders`;", expected: "orders"},
+		{query: "USE [orders]", expected: "orders"},
+		{query: "SET search_path TO reports, public", expected: "reports"},
+		{query: `SET search_path = "$user", "Reports"`, expected: "Reports"},
+		{query: "set search_path to '$user'"},
+		{query: "SET SCHEMA 'reports'", expected: "reports"},
+		{query: "SET search_path"},
+		{query: "SET NAMES utf8mb4"},
+		{query: "SELECT * FROM users"},
+		{query: "USE"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.query, func(t *testing.T) {
+			name, ok := namespaceSwitch(tc.query)
+			assert.Equal(t, tc.expected != "", ok)
+			assert.Equal(t, tc.expected, name)
+		})
+	}
+}
+
+func TestOtConn_TrackNamespace(t *testing.T) {
+	ctx, sr, tracer, _ := prepareTraces(true)
+	cfg := newMockConfig(t, tracer)
+	otelConn := newConn(newMockConn(false), cfg)
+
+	_, err := otelConn.ExecContext(ctx, "USE reports", nil)
+	require.NoError(t, err)
+	_, err = otelConn.ExecContext(ctx, "DELETE FROM t", nil)
+	require.NoError(t, err)
+
+	// A failed switch does not change the namespace.
+	failingConn := newConn(newMockConn(true), cfg)
+	_, err = failingConn.ExecContext(ctx, "USE reports", nil)
+	require.Error(t, err)
+	assert.Empty(t, failingConn.currentNamespace())
+
+	spanList := sr.Ended()
+	require.Len(t, spanList, 3)
+	assert.NotContains(t, spanList[0].Attributes(), semconv.DBNameKey.String("reports"))
+	assert.Contains(t, spanList[1].Attributes(), semconv.DBNameKey.String("reports"))
+}
+
+func TestOtConn_TrackNamespaceStable(t *testing.T) {
+	ctx, sr, tracer, _ := prepareTraces(true)
+	cfg := newMockConfig(t, tracer)
+	cfg.semconvStability = semconvutil.StabilityStable
+	otelConn := newConn(newMockConn(false), cfg)
+
+	_, err := otelConn.ExecContext(ctx, "SET search_path TO reports", nil)
+	require.NoError(t, err)
+	_, err = otelConn.ExecContext(ctx, "DELETE FROM t", nil)
+	require.NoError(t, err)
+
+	spanList := sr.Ended()
+	require.Len(t, spanList, 2)
+	assert.Contains(t, spanList[1].Attributes(), attribute.String("db.namespace", "reports"))
+	assert.NotContains(t, spanList[1].Attributes(), semconv.DBNameKey.String("reports"))
+}
+
+func TestOtConn_TrackNamespaceInTx(t *testing.T) {
+	ctx, _, tracer, _ := prepareTraces(true)
+	cfg := newMockConfig(t, tracer)
+	otelConn := newConn(newMockConn(false), cfg)
+
+	_, err := otelConn.ExecContext(ctx, "SET search_path TO orders", nil)
+	require.NoError(t, err)
+
+	// A SET statement is reverted by a rollback.
+	tx, err := otelConn.BeginTx(ctx, driver.TxOptions{})
+	require.NoError(t, err)
+	_, err = otelConn.ExecContext(ctx, "SET search_path TO reports", nil)
+	require.NoError(t, err)
+	_, err = otelConn.ExecContext(ctx, "SET search_path TO archive", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "archive", otelConn.currentNamespace())
+	require.NoError(t, tx.Rollback())
+	assert.Equal(t, "orders", otelConn.currentNamespace())
+
+	// It is kept by a commit.
+	tx, err = otelConn.BeginTx(ctx, driver.TxOptions{})
+	require.NoError(t, err)
+	_, err = otelConn.ExecContext(ctx, "SET search_path TO reports", nil)
+	require.NoError(t, err)
+	require.NoError(t, tx.Commit())
+	assert.Equal(t, "reports", otelConn.currentNamespace())
+
+	// USE is not reverted by a rollback.
+	tx, err = otelConn.BeginTx(ctx, driver.TxOptions{})
+	require.NoError(t, err)
+	_, err = otelConn.ExecContext(ctx, "USE archive", nil)
+	require.NoError(t, err)
+	require.NoError(t, tx.Rollback())
+	assert.Equal(t, "archive", otelConn.currentNamespace())
+}
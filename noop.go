@@ -0,0 +1,23 @@
+// Copyright Sam Xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build otelsql_noop
+
+package otelsql
+
+// noopBuild reports whether the package is built with the otelsql_noop build
+// tag, under which drivers, connectors, and DBs are returned unwrapped and no
+// telemetry is recorded. As it is a constant, the instrumentation is left out
+// of the binary.
+const noopBuild = true
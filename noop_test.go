@@ -0,0 +1,47 @@
+// Copyright Sam Xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build otelsql_noop
+
+package otelsql
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestNoopBuild runs with the otelsql_noop build tag, see the test-noop
+// target of the Makefile.
+func TestNoopBuild(t *testing.T) {
+	md := newMockDriver(false)
+	assert.Same(t, md, WrapDriver(md))
+
+	db := OpenDB(newMockConnector(md, false))
+	defer db.Close()
+	_, ok := ConfigOf(db)
+	assert.False(t, ok)
+
+	registration, err := RegisterDBStatsMetricsWithShutdown(db)
+	require.NoError(t, err)
+	assert.NoError(t, registration.Shutdown(context.Background()))
+	assert.NoError(t, RegisterMultiDBStatsMetrics(map[string]*sql.DB{"main": db}))
+
+	ctx, end := NewDB(db).startCall(context.Background(), MethodDBQuery, "SELECT 1", nil)
+	end(nil)
+	assert.Equal(t, context.Background(), ctx)
+}
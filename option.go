@@ -15,6 +15,9 @@
 package otelsql
 
 import (
+	"slices"
+	"sync"
+
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/metric"
 	"go.opentelemetry.io/otel/trace"
@@ -35,6 +38,35 @@ func (f OptionFunc) Apply(c *config) {
 	f(c)
 }
 
+var (
+	defaultOptionsMu sync.RWMutex
+	defaultOptions   []Option
+)
+
+// SetDefaultOptions sets options applied before the options passed to Open,
+// OpenDB, Register, WrapDriver, and the other functions taking options, so
+// that settings shared by all the databases of an application can be set
+// once, e.g. in an init function. Options passed to these functions still
+// override the default ones, like options overriding the previous ones, e.g.
+// WithAttributes replaces the default attributes instead of adding to them.
+//
+// Each call replaces the default options set by the previous one. Calling
+// SetDefaultOptions without options removes the default options.
+// The default options only apply to the drivers and connectors wrapped after
+// the call.
+func SetDefaultOptions(opts ...Option) {
+	defaultOptionsMu.Lock()
+	defer defaultOptionsMu.Unlock()
+	defaultOptions = slices.Clone(opts)
+}
+
+// getDefaultOptions returns the options set by SetDefaultOptions.
+func getDefaultOptions() []Option {
+	defaultOptionsMu.RLock()
+	defer defaultOptionsMu.RUnlock()
+	return defaultOptions
+}
+
 // WithTracerProvider specifies a tracer provider to use for creating a tracer.
 // If none is specified, the global provider is used.
 func WithTracerProvider(provider trace.TracerProvider) Option {
@@ -50,6 +82,80 @@ func WithAttributes(attributes ...attribute.KeyValue) Option {
 	})
 }
 
+// WithInstrumentationAttributes specifies the attributes of the
+// instrumentation scope of the tracer and meter, e.g. the role of the library
+// in the application, so that processors can route the telemetry of otelsql
+// by scope.
+func WithInstrumentationAttributes(attributes ...attribute.KeyValue) Option {
+	return OptionFunc(func(cfg *config) {
+		cfg.InstrumentationAttributes = attributes
+	})
+}
+
+// WithDBSystem specifies the database management system that will be set to
+// each span and measurement, e.g. "mysql" or "postgresql". It is recorded as
+// db.system, as db.system.name when the stable semantic conventions are opted
+// in with OTEL_SEMCONV_STABILITY_OPT_IN=database, or as both with
+// database/dup.
+//
+// It is applied independently of WithAttributes, so the order of the two
+// options does not matter.
+func WithDBSystem(system string) Option {
+	return OptionFunc(func(cfg *config) {
+		cfg.DBSystem = system
+	})
+}
+
+// WithDatabaseRole specifies the db.role attribute that will be set to each
+// span and measurement, e.g. DatabaseRolePrimary or DatabaseRoleReplica, so
+// that the pools of an application splitting reads and writes can be told
+// apart. ContextWithDatabaseRole overrides it for a single call.
+//
+// Like WithDBSystem, it is applied independently of WithAttributes.
+func WithDatabaseRole(role string) Option {
+	return OptionFunc(func(cfg *config) {
+		cfg.DatabaseRole = role
+	})
+}
+
+// WithPoolName specifies the db.client.connection.pool.name attribute that
+// will be set to each span and measurement, including the ones of
+// RegisterDBStatsMetrics, so that the pools of an application connecting to
+// the same server, e.g. with different users or schemas, can be told apart.
+//
+// Like WithDBSystem, it is applied independently of WithAttributes.
+func WithPoolName(name string) Option {
+	return OptionFunc(func(cfg *config) {
+		cfg.PoolName = name
+	})
+}
+
+// WithPoolLimits makes RegisterDBStatsMetrics report the given limits of the
+// connection pool as the db.sql.connection.max_idle,
+// db.sql.connection.max_lifetime and db.sql.connection.max_idle_time gauges,
+// since database/sql only exposes the maximum number of open connections.
+// The maximum number of idle connections is reported as database/sql
+// applies it, and as db.client.connection.idle.max with the stable semantic
+// conventions.
+// The limits are not applied to the DB, use PoolLimits.Apply for that.
+func WithPoolLimits(limits PoolLimits) Option {
+	return OptionFunc(func(cfg *config) {
+		cfg.PoolLimits = &limits
+	})
+}
+
+// WithShardID specifies the db.shard.id attribute that will be set to each
+// span and measurement, for applications holding a pool per shard or
+// partition. ContextWithShardID overrides it for a single call, e.g. when
+// shards are routed by a proxy behind a single pool.
+//
+// Like WithDBSystem, it is applied independently of WithAttributes.
+func WithShardID(id string) Option {
+	return OptionFunc(func(cfg *config) {
+		cfg.ShardID = id
+	})
+}
+
 // WithSpanNameFormatter takes an interface that will be called on every
 // operation and the returned string will become the span name.
 func WithSpanNameFormatter(spanNameFormatter SpanNameFormatter) Option {
@@ -67,6 +173,10 @@ func WithSpanOptions(opts SpanOptions) Option {
 
 // WithMeterProvider specifies a tracer provider to use for creating a tracer.
 // If none is specified, the global provider is used.
+//
+// With the MeterProvider of go.opentelemetry.io/otel/metric/noop, calls skip
+// building the attributes of measurements. Other providers recording
+// nothing, like an SDK one without readers, are not detected.
 func WithMeterProvider(provider metric.MeterProvider) Option {
 	return OptionFunc(func(cfg *config) {
 		cfg.MeterProvider = provider
@@ -94,14 +204,115 @@ func WithSQLCommenter(enabled bool) Option {
 	})
 }
 
+// WithArgsRedactor redacts the arguments of calls with redactor before they
+// reach AttributesGetter, InstrumentAttributesGetter, SpanFilter,
+// ParentSpanFilter, and the other consumers of arguments, so that personal
+// data like emails can be masked in one place. The driver gets the original
+// arguments.
+func WithArgsRedactor(redactor ArgsRedactor) Option {
+	return OptionFunc(func(cfg *config) {
+		cfg.ArgsRedactor = redactor
+	})
+}
+
+// WithQueryTransformer rewrites the queries passed to the driver with
+// transformer, e.g. to add optimizer hints or routing comments for proxies,
+// or to enforce LIMIT policies. Spans and metrics keep the original query.
+//
+// The transformer is applied before the comment of WithSQLCommenter is added,
+// or after it if afterCommenter is true. Prepared statements are transformed
+// once, when they are prepared.
+//
+// Notice: This option is EXPERIMENTAL and may be changed or removed in a
+// later release.
+func WithQueryTransformer(transformer QueryTransformer, afterCommenter bool) Option {
+	return OptionFunc(func(cfg *config) {
+		cfg.QueryTransformer = transformer
+		cfg.QueryTransformerAfterCommenter = afterCommenter
+	})
+}
+
+// WithSQLCommenterIncludeKeys restricts the propagator fields injected by
+// WithSQLCommenter to the given keys, e.g. "traceparent".
+//
+// Notice: This option is EXPERIMENTAL and may be changed or removed in a
+// later release.
+func WithSQLCommenterIncludeKeys(keys ...string) Option {
+	return OptionFunc(func(cfg *config) {
+		cfg.SQLCommenterIncludeKeys = keys
+	})
+}
+
+// WithSQLCommenterExcludeKeys prevents the given propagator fields from being
+// injected by WithSQLCommenter, e.g. "baggage", which can carry user data that
+// must not be written into server-side query logs.
+//
+// Notice: This option is EXPERIMENTAL and may be changed or removed in a
+// later release.
+func WithSQLCommenterExcludeKeys(keys ...string) Option {
+	return OptionFunc(func(cfg *config) {
+		cfg.SQLCommenterExcludeKeys = keys
+	})
+}
+
+// WithSQLCommenterMaxLength limits the length of the comment content injected
+// by WithSQLCommenter. Fields exceeding the limit are dropped, starting from the
+// last one and keeping traceparent as long as possible. Each truncation is
+// counted by the db.sql.commenter.truncated metric.
+//
+// Notice: This option is EXPERIMENTAL and may be changed or removed in a
+// later release.
+func WithSQLCommenterMaxLength(maxLength int) Option {
+	return OptionFunc(func(cfg *config) {
+		cfg.SQLCommenterMaxLength = maxLength
+	})
+}
+
+// WithSQLCommenterDMLOnly restricts the comments injected by WithSQLCommenter
+// to INSERT, UPDATE, DELETE, and other data modification statements, leaving
+// SELECT and other statements untouched. This attributes writes while keeping
+// the read path free of commenter overhead.
+//
+// Notice: This option is EXPERIMENTAL and may be changed or removed in a
+// later release.
+func WithSQLCommenterDMLOnly() Option {
+	return OptionFunc(func(cfg *config) {
+		cfg.SQLCommenterDMLOnly = true
+	})
+}
+
 // WithAttributesGetter takes AttributesGetter that will be called on every
-// span creations.
+// span creations. It is not called for spans that are not recording, e.g.
+// sampled-out spans, so its attributes are set after the span starts and
+// are not visible to samplers.
 func WithAttributesGetter(attributesGetter AttributesGetter) Option {
 	return OptionFunc(func(cfg *config) {
 		cfg.AttributesGetter = attributesGetter
 	})
 }
 
+// WithUnwrappedRows returns the rows of the driver as is, without wrapping
+// them. Reading rows then creates neither sql.rows spans nor measurements,
+// and the options acting on rows, like SpanOptions.MergeRowsIntoQuerySpan or
+// WithErrorTraceIDs, do not apply to them. This is for applications that only
+// want spans of queries and measured the overhead of the wrapper on large
+// result sets.
+func WithUnwrappedRows() Option {
+	return OptionFunc(func(cfg *config) {
+		cfg.UnwrappedRows = true
+	})
+}
+
+// WithConnectAttributesGetter takes ConnectAttributesGetter that will be
+// called on every connect, with the data source name of the connector without
+// credentials, to add attributes to the sql.connector.connect span and
+// measurement.
+func WithConnectAttributesGetter(getter ConnectAttributesGetter) Option {
+	return OptionFunc(func(cfg *config) {
+		cfg.ConnectAttributesGetter = getter
+	})
+}
+
 // WithInstrumentAttributesGetter takes InstrumentAttributesGetter that will be called every time metric is recorded to instruments.
 func WithInstrumentAttributesGetter(instrumentAttributesGetter InstrumentAttributesGetter) Option {
 	return OptionFunc(func(cfg *config) {
@@ -116,3 +327,323 @@ func WithDisableSkipErrMeasurement(disable bool) Option {
 		cfg.DisableSkipErrMeasurement = disable
 	})
 }
+
+// WithDisableRowsMeasurement, if set to true, will suppress the measurements
+// of sql.rows in db.sql.latency and db.client.operation.duration, which
+// measure how long the application iterates over rows rather than the
+// latency of the database.
+// sql.rows spans are kept.
+func WithDisableRowsMeasurement(disable bool) Option {
+	return OptionFunc(func(cfg *config) {
+		cfg.DisableRowsMeasurement = disable
+	})
+}
+
+// WithStrictMode, if set to true, validates that required semantic convention
+// attributes are configured. Currently, the db.system attribute is required.
+//
+// Open and Register return an error if the validation fails, while OpenDB
+// and WrapDriver report it to the global OpenTelemetry error handler.
+func WithStrictMode(enabled bool) Option {
+	return OptionFunc(func(cfg *config) {
+		cfg.StrictMode = enabled
+	})
+}
+
+// WithSavepointDetection, if set to true, detects savepoint statements
+// (SAVEPOINT, ROLLBACK TO, RELEASE) executed through Exec and instruments them
+// with MethodTxSavepoint, MethodTxRollbackTo, and MethodTxReleaseSavepoint
+// instead of MethodConnExec or MethodStmtExec.
+func WithSavepointDetection(enabled bool) Option {
+	return OptionFunc(func(cfg *config) {
+		cfg.SavepointDetection = enabled
+	})
+}
+
+// WithExpectedErrors takes a func that reports whether an error is expected,
+// e.g. a unique constraint violation that is handled by an upsert flow.
+// Expected errors are still returned to the caller, but they do not set the
+// span status to error and are measured as status=ok.
+func WithExpectedErrors(expectedErrors func(err error) bool) Option {
+	return OptionFunc(func(cfg *config) {
+		cfg.ExpectedErrors = expectedErrors
+	})
+}
+
+// WithPostgreSQLApplicationName enables context propagation for PostgreSQL
+// by setting the application_name of the session, which is visible in
+// pg_stat_activity and server logs, to prefix followed by the traceparent of
+// the calling context, e.g.
+//
+//	orders/00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01
+//
+// Unlike WithSQLCommenter, it does not change statements, so it does not break
+// prepared statement caching. The application_name is only set when it differs
+// from the last value set on the connection, and again after a rollback,
+// which reverts it. It is not set again in a transaction in which setting it
+// failed, since PostgreSQL rejects the statements of a failed transaction.
+// The prefix is shortened to fit the 63 bytes limit of application_name.
+//
+// Notice: This option is EXPERIMENTAL and may be changed or removed in a
+// later release.
+func WithPostgreSQLApplicationName(prefix string) Option {
+	return OptionFunc(func(cfg *config) {
+		cfg.ApplicationNamePropagation = true
+		cfg.ApplicationNamePrefix = prefix
+	})
+}
+
+// WithServerVersionProbe queries the version of the database server once, on
+// the first connection, and adds it to all subsequent spans as the
+// db.system.version attribute, which helps during rolling upgrades.
+//
+// The query is chosen by the db.system or db.system.name given to
+// WithDBSystem or WithAttributes. It supports postgresql, cockroachdb,
+// mysql, mariadb, mssql (microsoft.sql_server), sqlite, and clickhouse, other
+// systems are not probed.
+//
+// Notice: This option is EXPERIMENTAL and may be changed or removed in a
+// later release.
+func WithServerVersionProbe() Option {
+	return OptionFunc(func(cfg *config) {
+		cfg.ServerVersionProbe = true
+	})
+}
+
+// WithDatabaseNameProbe queries the name of the current database once, on the
+// first connection, and adds it to all subsequent spans as the db.name or
+// db.namespace attribute, following OTEL_SEMCONV_STABILITY_OPT_IN. It is
+// useful when the database name cannot be parsed from the DSN, e.g. when it
+// is the default database of the user. Nothing is queried if db.name or
+// db.namespace is already given to WithAttributes.
+//
+// The query is chosen by the db.system or db.system.name given to
+// WithDBSystem or WithAttributes. It supports postgresql, cockroachdb,
+// mysql, mariadb, mssql (microsoft.sql_server), and clickhouse, other
+// systems are not probed.
+//
+// Notice: This option is EXPERIMENTAL and may be changed or removed in a
+// later release.
+func WithDatabaseNameProbe() Option {
+	return OptionFunc(func(cfg *config) {
+		cfg.DatabaseNameProbe = true
+	})
+}
+
+// WithErrorTraceIDs annotates the errors returned by the driver with the span
+// context active when they were returned, so errors logged far from the call
+// site can still be joined to the database span with TraceIDFromError or
+// SpanContextFromError.
+//
+// The annotated errors keep the message of the original error and unwrap to
+// it, so errors.Is and errors.As keep working. driver.ErrSkip and io.EOF are
+// never annotated as database/sql compares them directly.
+//
+// Notice: This option is EXPERIMENTAL and may be changed or removed in a
+// later release.
+func WithErrorTraceIDs() Option {
+	return OptionFunc(func(cfg *config) {
+		cfg.ErrorTraceIDs = true
+	})
+}
+
+// WithPprofLabels sets the otelsql.method and otelsql.query_summary pprof
+// labels on the calling goroutine during driver calls on connections,
+// statements, and rows, so CPU profiles can be attributed to SQL operations.
+// The query summary is the one described in WithQuerySummaryMetricAttribute.
+//
+// The labels of the goroutine are restored to the labels of the context of
+// the call afterwards, so callers that set their own labels should use
+// pprof.Do.
+//
+// Notice: This option is EXPERIMENTAL and may be changed or removed in a
+// later release.
+func WithPprofLabels() Option {
+	return OptionFunc(func(cfg *config) {
+		cfg.PprofLabels = true
+	})
+}
+
+// WithQuerySummaryMetricAttribute adds the db.query.summary attribute, a
+// summary of the query made of its operations and tables like
+// "SELECT orders customers", to the db.sql.latency metric. This allows
+// per-statement latency dashboards without a tracing backend.
+//
+// To bound the cardinality of the metric, at most limit distinct summaries
+// are recorded, later ones are recorded as "_OTHER". A limit less than 1
+// disables the attribute.
+//
+// Notice: This option is EXPERIMENTAL and may be changed or removed in a
+// later release.
+func WithQuerySummaryMetricAttribute(limit int) Option {
+	return OptionFunc(func(cfg *config) {
+		cfg.QuerySummaryMetricLimit = limit
+	})
+}
+
+// WithDurationUnit specifies the unit of the db.sql.latency and
+// db.sql.connection.wait_duration instruments, either
+// DurationUnitMilliseconds, the default, or DurationUnitSeconds, the unit of
+// the semantic conventions. Other units are ignored.
+func WithDurationUnit(unit DurationUnit) Option {
+	return OptionFunc(func(cfg *config) {
+		if unit == DurationUnitMilliseconds || unit == DurationUnitSeconds {
+			cfg.DurationUnit = unit
+		}
+	})
+}
+
+// WithPrometheusNaming, if set to true, names the instruments of
+// RegisterDBStatsMetrics following the Prometheus conventions, with
+// underscores instead of dots and the _total suffix for counters, e.g.
+// db_sql_connection_wait_total instead of db.sql.connection.wait.
+func WithPrometheusNaming(enabled bool) Option {
+	return OptionFunc(func(cfg *config) {
+		cfg.PrometheusNaming = enabled
+	})
+}
+
+// WithQueryRecorder captures the statements sent to the driver in recorder,
+// after the comments of WithSQLCommenter are injected, for golden tests on
+// the SQL an application issues.
+func WithQueryRecorder(recorder *QueryRecorder) Option {
+	return OptionFunc(func(cfg *config) {
+		cfg.QueryRecorder = recorder
+	})
+}
+
+// WithQueryAggregator aggregates the statistics of the statements executed
+// through Exec and Query calls in aggregator, to find the slowest and most
+// frequent ones in process, see QueryAggregator.
+//
+// Notice: This option is EXPERIMENTAL and may be changed or removed in a
+// later release.
+func WithQueryAggregator(aggregator *QueryAggregator) Option {
+	return OptionFunc(func(cfg *config) {
+		cfg.QueryAggregator = aggregator
+	})
+}
+
+// WithOldestCallMetric records the age of the oldest driver call in progress,
+// like a query stuck on a lock, with the db.sql.oldest_call.age gauge. If
+// withFingerprint is true, the gauge has the fingerprint of the query of the
+// call as db.query.fingerprint.
+//
+// The gauge is registered once per driver by Open, OpenDB, WrapDriver, and
+// Register; other functions accepting options ignore it. It stops being
+// observed when a DB returned by Open or OpenDB is closed.
+//
+// Notice: This option is EXPERIMENTAL and may be changed or removed in a
+// later release.
+func WithOldestCallMetric(withFingerprint bool) Option {
+	return OptionFunc(func(cfg *config) {
+		cfg.OldestCallMetric = true
+		cfg.OldestCallFingerprint = withFingerprint
+	})
+}
+
+// WithAuditRecorder calls recorder for each INSERT, UPDATE, DELETE, or other
+// data modification statement, whether executed with Exec or queried, like
+// INSERT ... RETURNING, to keep an audit trail without a separate database
+// proxy. It is called with the context of the call, so the records are
+// correlated with its trace.
+//
+// The otelsqlaudit module provides a recorder emitting OTel log records.
+//
+// Notice: This option is EXPERIMENTAL and may be changed or removed in a
+// later release.
+func WithAuditRecorder(recorder AuditRecorder) Option {
+	return OptionFunc(func(cfg *config) {
+		cfg.AuditRecorder = recorder
+	})
+}
+
+// WithTenantAttribute adds the tenant.id attribute, the tenant returned by
+// getter, to the spans of calls made for a tenant.
+//
+// If maxTenants is positive, the attribute is also added to the
+// db.sql.latency metric. To bound the cardinality of the metric, at most
+// maxTenants distinct tenants are recorded, later ones are recorded as
+// "_OTHER".
+func WithTenantAttribute(getter TenantGetter, maxTenants int) Option {
+	return OptionFunc(func(cfg *config) {
+		cfg.TenantGetter = getter
+		cfg.TenantMetricLimit = maxTenants
+	})
+}
+
+// WithCollectionNameOnMetrics adds the db.collection.name attribute, the first
+// table of the query like "orders" for "SELECT * FROM orders JOIN customers",
+// to the db.sql.latency and db.client.operation.duration metrics if enabled.
+// This allows per-table latency SLOs without processing traces.
+//
+// To bound the cardinality of the metric, at most 1000 distinct names are
+// recorded, later ones are recorded as "_OTHER".
+//
+// Notice: This option is EXPERIMENTAL and may be changed or removed in a
+// later release.
+func WithCollectionNameOnMetrics(enabled bool) Option {
+	return OptionFunc(func(cfg *config) {
+		cfg.CollectionNameOnMetrics = enabled
+	})
+}
+
+// WithSQLServerSessionContext enables context propagation for SQL Server by
+// setting the traceparent of the context acquiring a connection from the pool
+// in the SESSION_CONTEXT of the session with sp_set_session_context, e.g.
+//
+//	SELECT SESSION_CONTEXT(N'traceparent')
+//
+// The SESSION_CONTEXT is set when the session is reset, which
+// database/sql does before reusing a connection, so the first use of a new
+// connection is not covered. It requires a driver that implements
+// driver.SessionResetter, like github.com/microsoft/go-mssqldb. Unlike
+// WithSQLCommenter, it does not change statements.
+//
+// Notice: This option is EXPERIMENTAL and may be changed or removed in a
+// later release.
+func WithSQLServerSessionContext() Option {
+	return OptionFunc(func(cfg *config) {
+		cfg.SessionContextPropagation = true
+	})
+}
+
+// WithServerAttributesFromDSN sets whether the server address and port
+// parsed from the data source name the driver opens connections with are
+// added to the spans and metrics of the connections. They are recorded as
+// server.address and server.port, and as net.peer.name and net.peer.port
+// unless only the stable semantic conventions are opted in with
+// OTEL_SEMCONV_STABILITY_OPT_IN. Attributes already set by WithAttributes are
+// kept.
+//
+// It is disabled by default, as it raises the cardinality of metrics and
+// exposes host names. It has no effect with OpenDB, as the data source name
+// of the connector is unknown.
+func WithServerAttributesFromDSN(enabled bool) Option {
+	return OptionFunc(func(cfg *config) {
+		cfg.ServerAttributesFromDSN = enabled
+	})
+}
+
+// WithMySQLConnectionAttributes enables context propagation for MySQL through
+// connection attributes, which are visible in
+// performance_schema.session_connect_attrs. The given attributes, e.g. a
+// service name, and the traceparent of the context opening the connection are
+// added to the connectionAttributes parameter of the DSN.
+//
+// It requires github.com/XSAM/otelsql to know the DSN, so it works with Open,
+// Register, and WrapDriver, but not with OpenDB. The driver must support the
+// connectionAttributes parameter, like github.com/go-sql-driver/mysql v1.8+.
+// As connection attributes are sent once per connection, the traceparent
+// identifies the operation that opened the connection.
+//
+// Notice: This option is EXPERIMENTAL and may be changed or removed in a
+// later release.
+func WithMySQLConnectionAttributes(attrs map[string]string) Option {
+	return OptionFunc(func(cfg *config) {
+		if attrs == nil {
+			attrs = map[string]string{}
+		}
+		cfg.MySQLConnectionAttributes = attrs
+	})
+}
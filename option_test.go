@@ -12,12 +12,15 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
+//go:build !otelsql_noop
+
 package otelsql
 
 import (
 	"context"
 	"database/sql/driver"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"go.opentelemetry.io/otel/attribute"
@@ -28,10 +31,25 @@ import (
 func TestOptions(t *testing.T) {
 	tracerProvider := sdktrace.NewTracerProvider()
 	meterProvider := noop.NewMeterProvider()
+	dummyAuditRecorder := func(context.Context, AuditRecord) {}
 
 	dummyAttributesGetter := func(_ context.Context, _ Method, _ string, _ []driver.NamedValue) []attribute.KeyValue {
 		return []attribute.KeyValue{attribute.String("foo", "bar")}
 	}
+	queryAggregator := NewQueryAggregator(10)
+	queryRecorder := NewQueryRecorder()
+	dummyTenantGetter := func(_ context.Context) string {
+		return "acme"
+	}
+	dummyArgsRedactor := func(args []driver.NamedValue) []driver.NamedValue {
+		return args[:0]
+	}
+	dummyQueryTransformer := func(_ context.Context, _ Method, query string) string {
+		return query + " LIMIT 10"
+	}
+	dummyConnectAttributesGetter := func(_ context.Context, _ string, _ driver.Connector) []attribute.KeyValue {
+		return []attribute.KeyValue{attribute.String("shard", "1")}
+	}
 
 	testCases := []struct {
 		name           string
@@ -54,6 +72,88 @@ func TestOptions(t *testing.T) {
 				attribute.String("foo2", "bar2"),
 			}},
 		},
+		{
+			name:           "WithInstrumentationAttributes",
+			option:         WithInstrumentationAttributes(attribute.String("role", "primary")),
+			expectedConfig: config{InstrumentationAttributes: []attribute.KeyValue{attribute.String("role", "primary")}},
+		},
+		{
+			name:           "WithDBSystem",
+			option:         WithDBSystem("mysql"),
+			expectedConfig: config{DBSystem: "mysql"},
+		},
+		{
+			name:           "WithDatabaseRole",
+			option:         WithDatabaseRole(DatabaseRoleReplica),
+			expectedConfig: config{DatabaseRole: "replica"},
+		},
+		{
+			name:           "WithPoolName",
+			option:         WithPoolName("reports"),
+			expectedConfig: config{PoolName: "reports"},
+		},
+		{
+			name:   "WithPoolLimits",
+			option: WithPoolLimits(PoolLimits{MaxIdleConns: 2, ConnMaxLifetime: time.Hour}),
+			expectedConfig: config{
+				PoolLimits: &PoolLimits{MaxIdleConns: 2, ConnMaxLifetime: time.Hour},
+			},
+		},
+		{
+			name:           "WithShardID",
+			option:         WithShardID("shard-1"),
+			expectedConfig: config{ShardID: "shard-1"},
+		},
+		{
+			name:           "WithDurationUnit",
+			option:         WithDurationUnit(DurationUnitSeconds),
+			expectedConfig: config{DurationUnit: DurationUnitSeconds},
+		},
+		{
+			name:           "WithDurationUnit invalid",
+			option:         WithDurationUnit("us"),
+			expectedConfig: config{},
+		},
+		{
+			name:           "WithQueryRecorder",
+			option:         WithQueryRecorder(queryRecorder),
+			expectedConfig: config{QueryRecorder: queryRecorder},
+		},
+		{
+			name:           "WithQueryAggregator",
+			option:         WithQueryAggregator(queryAggregator),
+			expectedConfig: config{QueryAggregator: queryAggregator},
+		},
+		{
+			name:           "WithOldestCallMetric",
+			option:         WithOldestCallMetric(true),
+			expectedConfig: config{OldestCallMetric: true, OldestCallFingerprint: true},
+		},
+		{
+			name:           "WithAuditRecorder",
+			option:         WithAuditRecorder(dummyAuditRecorder),
+			expectedConfig: config{AuditRecorder: dummyAuditRecorder},
+		},
+		{
+			name:           "WithPrometheusNaming",
+			option:         WithPrometheusNaming(true),
+			expectedConfig: config{PrometheusNaming: true},
+		},
+		{
+			name:           "WithArgsRedactor",
+			option:         WithArgsRedactor(dummyArgsRedactor),
+			expectedConfig: config{ArgsRedactor: dummyArgsRedactor},
+		},
+		{
+			name:           "WithQueryTransformer",
+			option:         WithQueryTransformer(dummyQueryTransformer, true),
+			expectedConfig: config{QueryTransformer: dummyQueryTransformer, QueryTransformerAfterCommenter: true},
+		},
+		{
+			name:           "WithTenantAttribute",
+			option:         WithTenantAttribute(dummyTenantGetter, 10),
+			expectedConfig: config{TenantGetter: dummyTenantGetter, TenantMetricLimit: 10},
+		},
 		{
 			name:           "WithSpanNameFormatter",
 			option:         WithSpanNameFormatter(nil),
@@ -74,11 +174,36 @@ func TestOptions(t *testing.T) {
 			option:         WithSQLCommenter(true),
 			expectedConfig: config{SQLCommenterEnabled: true},
 		},
+		{
+			name:           "WithSQLCommenterIncludeKeys",
+			option:         WithSQLCommenterIncludeKeys("traceparent"),
+			expectedConfig: config{SQLCommenterIncludeKeys: []string{"traceparent"}},
+		},
+		{
+			name:           "WithSQLCommenterExcludeKeys",
+			option:         WithSQLCommenterExcludeKeys("baggage"),
+			expectedConfig: config{SQLCommenterExcludeKeys: []string{"baggage"}},
+		},
+		{
+			name:           "WithSQLCommenterMaxLength",
+			option:         WithSQLCommenterMaxLength(100),
+			expectedConfig: config{SQLCommenterMaxLength: 100},
+		},
+		{
+			name:           "WithSQLCommenterDMLOnly",
+			option:         WithSQLCommenterDMLOnly(),
+			expectedConfig: config{SQLCommenterDMLOnly: true},
+		},
 		{
 			name:           "WithAttributesGetter",
 			option:         WithAttributesGetter(dummyAttributesGetter),
 			expectedConfig: config{AttributesGetter: dummyAttributesGetter},
 		},
+		{
+			name:           "WithConnectAttributesGetter",
+			option:         WithConnectAttributesGetter(dummyConnectAttributesGetter),
+			expectedConfig: config{ConnectAttributesGetter: dummyConnectAttributesGetter},
+		},
 		{
 			name:           "WithInstrumentAttributesGetter",
 			option:         WithInstrumentAttributesGetter(dummyAttributesGetter),
@@ -89,6 +214,81 @@ func TestOptions(t *testing.T) {
 			option:         WithDisableSkipErrMeasurement(true),
 			expectedConfig: config{DisableSkipErrMeasurement: true},
 		},
+		{
+			name:           "WithSavepointDetection",
+			option:         WithSavepointDetection(true),
+			expectedConfig: config{SavepointDetection: true},
+		},
+		{
+			name:           "WithExpectedErrors",
+			option:         WithExpectedErrors(func(_ error) bool { return true }),
+			expectedConfig: config{ExpectedErrors: func(_ error) bool { return true }},
+		},
+		{
+			name:           "WithPostgreSQLApplicationName",
+			option:         WithPostgreSQLApplicationName("orders/"),
+			expectedConfig: config{ApplicationNamePropagation: true, ApplicationNamePrefix: "orders/"},
+		},
+		{
+			name:           "WithServerVersionProbe",
+			option:         WithServerVersionProbe(),
+			expectedConfig: config{ServerVersionProbe: true},
+		},
+		{
+			name:           "WithDatabaseNameProbe",
+			option:         WithDatabaseNameProbe(),
+			expectedConfig: config{DatabaseNameProbe: true},
+		},
+		{
+			name:           "WithErrorTraceIDs",
+			option:         WithErrorTraceIDs(),
+			expectedConfig: config{ErrorTraceIDs: true},
+		},
+		{
+			name:           "WithPprofLabels",
+			option:         WithPprofLabels(),
+			expectedConfig: config{PprofLabels: true},
+		},
+		{
+			name:           "WithQuerySummaryMetricAttribute",
+			option:         WithQuerySummaryMetricAttribute(100),
+			expectedConfig: config{QuerySummaryMetricLimit: 100},
+		},
+		{
+			name:           "WithCollectionNameOnMetrics",
+			option:         WithCollectionNameOnMetrics(true),
+			expectedConfig: config{CollectionNameOnMetrics: true},
+		},
+		{
+			name:           "WithServerAttributesFromDSN",
+			option:         WithServerAttributesFromDSN(true),
+			expectedConfig: config{ServerAttributesFromDSN: true},
+		},
+		{
+			name:           "WithDisableRowsMeasurement",
+			option:         WithDisableRowsMeasurement(true),
+			expectedConfig: config{DisableRowsMeasurement: true},
+		},
+		{
+			name:           "WithUnwrappedRows",
+			option:         WithUnwrappedRows(),
+			expectedConfig: config{UnwrappedRows: true},
+		},
+		{
+			name:           "WithSQLServerSessionContext",
+			option:         WithSQLServerSessionContext(),
+			expectedConfig: config{SessionContextPropagation: true},
+		},
+		{
+			name:           "WithMySQLConnectionAttributes",
+			option:         WithMySQLConnectionAttributes(nil),
+			expectedConfig: config{MySQLConnectionAttributes: map[string]string{}},
+		},
+		{
+			name:           "WithStrictMode",
+			option:         WithStrictMode(true),
+			expectedConfig: config{StrictMode: true},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -99,11 +299,49 @@ func TestOptions(t *testing.T) {
 
 			if tc.expectedConfig.AttributesGetter != nil {
 				assert.Equal(t, tc.expectedConfig.AttributesGetter(context.Background(), "", "", nil), cfg.AttributesGetter(context.Background(), "", "", nil))
+			} else if tc.expectedConfig.ExpectedErrors != nil {
+				assert.Equal(t, tc.expectedConfig.ExpectedErrors(assert.AnError), cfg.ExpectedErrors(assert.AnError))
+			} else if tc.expectedConfig.ConnectAttributesGetter != nil {
+				assert.Equal(t, tc.expectedConfig.ConnectAttributesGetter(context.Background(), "", nil), cfg.ConnectAttributesGetter(context.Background(), "", nil))
+			} else if tc.expectedConfig.TenantGetter != nil {
+				assert.Equal(t, tc.expectedConfig.TenantGetter(context.Background()), cfg.TenantGetter(context.Background()))
+				assert.Equal(t, tc.expectedConfig.TenantMetricLimit, cfg.TenantMetricLimit)
+			} else if tc.expectedConfig.ArgsRedactor != nil {
+				args := []driver.NamedValue{{Ordinal: 1, Value: "secret"}}
+				assert.Equal(t, tc.expectedConfig.ArgsRedactor(args), cfg.ArgsRedactor(args))
+			} else if tc.expectedConfig.QueryTransformer != nil {
+				assert.Equal(t, tc.expectedConfig.QueryTransformer(context.Background(), "", "SELECT 1"), cfg.QueryTransformer(context.Background(), "", "SELECT 1"))
+				assert.Equal(t, tc.expectedConfig.QueryTransformerAfterCommenter, cfg.QueryTransformerAfterCommenter)
 			} else if tc.expectedConfig.InstrumentAttributesGetter != nil {
 				assert.Equal(t, tc.expectedConfig.InstrumentAttributesGetter(context.Background(), "", "", nil), cfg.InstrumentAttributesGetter(context.Background(), "", "", nil))
+			} else if tc.expectedConfig.AuditRecorder != nil {
+				assert.NotNil(t, cfg.AuditRecorder)
 			} else {
 				assert.Equal(t, tc.expectedConfig, cfg)
 			}
 		})
 	}
 }
+
+func TestSetDefaultOptions(t *testing.T) {
+	t.Cleanup(func() { SetDefaultOptions() })
+
+	SetDefaultOptions(
+		WithAttributes(attribute.String("team", "platform")),
+		WithSpanOptions(SpanOptions{Ping: true}),
+	)
+
+	cfg := newConfig()
+	assert.Equal(t, []attribute.KeyValue{attribute.String("team", "platform")}, cfg.Attributes)
+	assert.True(t, cfg.SpanOptions.Ping)
+
+	// Options passed per call override the default ones.
+	cfg = newConfig(WithAttributes(attribute.String("service", "orders")))
+	assert.Equal(t, []attribute.KeyValue{attribute.String("service", "orders")}, cfg.Attributes)
+	assert.True(t, cfg.SpanOptions.Ping)
+
+	SetDefaultOptions()
+	cfg = newConfig()
+	assert.Empty(t, cfg.Attributes)
+	assert.False(t, cfg.SpanOptions.Ping)
+}
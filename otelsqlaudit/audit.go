@@ -0,0 +1,89 @@
+// Copyright Sam Xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package otelsqlaudit emits OTel log records auditing the data modification
+// statements run through drivers instrumented by
+// github.com/XSAM/otelsql. It is a separate module, as the OTel logs API is
+// still experimental.
+//
+// Notice: This package is EXPERIMENTAL and may be changed or removed in a
+// later release.
+package otelsqlaudit // import "github.com/XSAM/otelsql/otelsqlaudit"
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/log"
+
+	"github.com/XSAM/otelsql"
+	"github.com/XSAM/otelsql/semconvutil"
+)
+
+// instrumentationName is the name of the logger, the one of the
+// instrumentation emitting the spans and metrics of the calls.
+const instrumentationName = "github.com/XSAM/otelsql"
+
+// WithAuditLog emits an OTel log record with provider for each INSERT,
+// UPDATE, DELETE, or other data modification statement, whether executed
+// with Exec or queried, like INSERT ... RETURNING, see
+// otelsql.WithAuditRecorder. The records have the query, without its
+// arguments, as body, and the attributes of otelsql.AuditRecord as
+// attributes. They are emitted with the context of the call, so they are
+// correlated with its trace.
+func WithAuditLog(provider log.LoggerProvider) otelsql.Option {
+	return otelsql.WithAuditRecorder(NewRecorder(provider))
+}
+
+// NewRecorder returns an otelsql.AuditRecorder emitting the records of
+// WithAuditLog with provider.
+func NewRecorder(provider log.LoggerProvider) otelsql.AuditRecorder {
+	logger := provider.Logger(
+		instrumentationName,
+		log.WithInstrumentationVersion(otelsql.Version()),
+		log.WithSchemaURL(semconvutil.SchemaURL(semconvutil.StabilityFromEnv())),
+	)
+	return func(ctx context.Context, r otelsql.AuditRecord) {
+		var record log.Record
+		record.SetTimestamp(time.Now())
+		record.SetBody(log.StringValue(r.Query))
+		if r.Err != nil {
+			record.SetSeverity(log.SeverityWarn)
+		} else {
+			record.SetSeverity(log.SeverityInfo)
+		}
+		for _, attr := range r.Attributes {
+			record.AddAttributes(logKeyValue(attr))
+		}
+		logger.Emit(ctx, record)
+	}
+}
+
+// logKeyValue converts attr to a log attribute.
+func logKeyValue(attr attribute.KeyValue) log.KeyValue {
+	key := string(attr.Key)
+	switch attr.Value.Type() {
+	case attribute.BOOL:
+		return log.Bool(key, attr.Value.AsBool())
+	case attribute.INT64:
+		return log.Int64(key, attr.Value.AsInt64())
+	case attribute.FLOAT64:
+		return log.Float64(key, attr.Value.AsFloat64())
+	case attribute.STRING:
+		return log.String(key, attr.Value.AsString())
+	default:
+		return log.String(key, attr.Value.Emit())
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright Sam Xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otelsqlaudit
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/log/logtest"
+
+	"github.com/XSAM/otelsql"
+)
+
+func TestNewRecorder(t *testing.T) {
+	provider := logtest.NewRecorder()
+	recorder := NewRecorder(provider)
+	ctx := context.Background()
+
+	recorder(ctx, otelsql.AuditRecord{
+		Method: otelsql.MethodConnExec,
+		Query:  "UPDATE users SET name = ? WHERE id = ?",
+		Attributes: []attribute.KeyValue{
+			attribute.String("status", "ok"),
+			attribute.Int64("db.response.affected_rows", 3),
+			attribute.Bool("flag", true),
+			attribute.StringSlice("tags", []string{"a", "b"}),
+		},
+	})
+	recorder(ctx, otelsql.AuditRecord{
+		Method: otelsql.MethodConnExec,
+		Query:  "DELETE FROM users",
+		Err:    errors.New("failed"),
+	})
+
+	result := provider.Result()
+	require.Len(t, result, 1)
+	assert.Equal(t, instrumentationName, result[0].Name)
+	assert.Equal(t, otelsql.Version(), result[0].Version)
+	records := result[0].Records
+	require.Len(t, records, 2)
+
+	assert.Equal(t, "UPDATE users SET name = ? WHERE id = ?", records[0].Body().AsString())
+	assert.Equal(t, log.SeverityInfo, records[0].Severity())
+	var attrs []log.KeyValue
+	records[0].WalkAttributes(func(kv log.KeyValue) bool {
+		attrs = append(attrs, kv)
+		return true
+	})
+	assert.Equal(t, []log.KeyValue{
+		log.String("status", "ok"),
+		log.Int64("db.response.affected_rows", 3),
+		log.Bool("flag", true),
+		log.String("tags", `["a","b"]`),
+	}, attrs)
+
+	assert.Equal(t, "DELETE FROM users", records[1].Body().AsString())
+	assert.Equal(t, log.SeverityWarn, records[1].Severity())
+}
@@ -0,0 +1,23 @@
+module github.com/XSAM/otelsql/otelsqlaudit
+
+go 1.22.0
+
+replace github.com/XSAM/otelsql => ../
+
+require (
+	github.com/XSAM/otelsql v0.36.0
+	github.com/stretchr/testify v1.10.0
+	go.opentelemetry.io/otel v1.33.0
+	go.opentelemetry.io/otel/log v0.8.0
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/go-logr/logr v1.4.2 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	go.opentelemetry.io/auto/sdk v1.1.0 // indirect
+	go.opentelemetry.io/otel/metric v1.33.0 // indirect
+	go.opentelemetry.io/otel/trace v1.33.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
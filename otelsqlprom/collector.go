@@ -0,0 +1,141 @@
+// Copyright Sam Xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package otelsqlprom provides a prometheus.Collector exporting the
+// database/sql connection pool statistics recorded by
+// otelsql.RegisterDBStatsMetrics, for applications that do not run an
+// OpenTelemetry metrics SDK.
+//
+// The metrics have the names the OpenTelemetry Prometheus exporter gives to
+// the instruments of otelsql.RegisterDBStatsMetrics, so existing dashboards
+// keep working.
+package otelsqlprom // import "github.com/XSAM/otelsql/otelsqlprom"
+
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const namespace = "db_sql_connection"
+
+// DBStatser is implemented by *sql.DB.
+type DBStatser interface {
+	Stats() sql.DBStats
+}
+
+// Option configures a Collector.
+type Option interface {
+	apply(*config)
+}
+
+type optionFunc func(*config)
+
+func (f optionFunc) apply(cfg *config) {
+	f(cfg)
+}
+
+type config struct {
+	attributes []attribute.KeyValue
+}
+
+// WithAttributes specifies attributes added to all metrics as constant
+// labels, like otelsql.WithAttributes. Dots in attribute keys are replaced by
+// underscores.
+func WithAttributes(attributes ...attribute.KeyValue) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.attributes = append(cfg.attributes, attributes...)
+	})
+}
+
+// Collector is a prometheus.Collector exporting the statistics of a
+// connection pool.
+type Collector struct {
+	db DBStatser
+
+	maxOpen           *prometheus.Desc
+	open              *prometheus.Desc
+	wait              *prometheus.Desc
+	waitDuration      *prometheus.Desc
+	closedMaxIdle     *prometheus.Desc
+	closedMaxIdleTime *prometheus.Desc
+	closedMaxLifetime *prometheus.Desc
+}
+
+var _ prometheus.Collector = (*Collector)(nil)
+
+// NewCollector returns a Collector exporting the statistics of db.
+func NewCollector(db DBStatser, options ...Option) *Collector {
+	var cfg config
+	for _, opt := range options {
+		opt.apply(&cfg)
+	}
+
+	labels := make(prometheus.Labels, len(cfg.attributes))
+	for _, attr := range cfg.attributes {
+		labels[sanitizeLabelName(string(attr.Key))] = attr.Value.Emit()
+	}
+	desc := func(name, help string, variableLabels ...string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName("", namespace, name), help, variableLabels, labels)
+	}
+
+	return &Collector{
+		db:                db,
+		maxOpen:           desc("max_open", "Maximum number of open connections to the database"),
+		open:              desc("open", "The number of established connections both in use and idle", "status"),
+		wait:              desc("wait_total", "The total number of connections waited for"),
+		waitDuration:      desc("wait_duration_milliseconds_total", "The total time blocked waiting for a new connection"),
+		closedMaxIdle:     desc("closed_max_idle_total", "The total number of connections closed due to SetMaxIdleConns"),
+		closedMaxIdleTime: desc("closed_max_idle_time_total", "The total number of connections closed due to SetConnMaxIdleTime"),
+		closedMaxLifetime: desc("closed_max_lifetime_total", "The total number of connections closed due to SetConnMaxLifetime"),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.maxOpen
+	ch <- c.open
+	ch <- c.wait
+	ch <- c.waitDuration
+	ch <- c.closedMaxIdle
+	ch <- c.closedMaxIdleTime
+	ch <- c.closedMaxLifetime
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.db.Stats()
+
+	ch <- prometheus.MustNewConstMetric(c.maxOpen, prometheus.GaugeValue, float64(stats.MaxOpenConnections))
+	ch <- prometheus.MustNewConstMetric(c.open, prometheus.GaugeValue, float64(stats.InUse), "inuse")
+	ch <- prometheus.MustNewConstMetric(c.open, prometheus.GaugeValue, float64(stats.Idle), "idle")
+	ch <- prometheus.MustNewConstMetric(c.wait, prometheus.CounterValue, float64(stats.WaitCount))
+	ch <- prometheus.MustNewConstMetric(c.waitDuration, prometheus.CounterValue, float64(stats.WaitDuration.Nanoseconds())/1e6)
+	ch <- prometheus.MustNewConstMetric(c.closedMaxIdle, prometheus.CounterValue, float64(stats.MaxIdleClosed))
+	ch <- prometheus.MustNewConstMetric(c.closedMaxIdleTime, prometheus.CounterValue, float64(stats.MaxIdleTimeClosed))
+	ch <- prometheus.MustNewConstMetric(c.closedMaxLifetime, prometheus.CounterValue, float64(stats.MaxLifetimeClosed))
+}
+
+// sanitizeLabelName replaces the characters that are invalid in Prometheus
+// label names by underscores.
+func sanitizeLabelName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, name)
+}
@@ -0,0 +1,81 @@
+// Copyright Sam Xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otelsqlprom
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+type dbStatserFunc func() sql.DBStats
+
+func (f dbStatserFunc) Stats() sql.DBStats {
+	return f()
+}
+
+func TestCollector(t *testing.T) {
+	db := dbStatserFunc(func() sql.DBStats {
+		return sql.DBStats{
+			MaxOpenConnections: 10,
+			InUse:              3,
+			Idle:               2,
+			WaitCount:          4,
+			WaitDuration:       1500 * time.Microsecond,
+			MaxIdleClosed:      5,
+			MaxIdleTimeClosed:  6,
+			MaxLifetimeClosed:  7,
+		}
+	})
+	collector := NewCollector(db, WithAttributes(attribute.String("db.system", "mysql")))
+
+	expected := `
+# HELP db_sql_connection_closed_max_idle_time_total The total number of connections closed due to SetConnMaxIdleTime
+# TYPE db_sql_connection_closed_max_idle_time_total counter
+db_sql_connection_closed_max_idle_time_total{db_system="mysql"} 6
+# HELP db_sql_connection_closed_max_idle_total The total number of connections closed due to SetMaxIdleConns
+# TYPE db_sql_connection_closed_max_idle_total counter
+db_sql_connection_closed_max_idle_total{db_system="mysql"} 5
+# HELP db_sql_connection_closed_max_lifetime_total The total number of connections closed due to SetConnMaxLifetime
+# TYPE db_sql_connection_closed_max_lifetime_total counter
+db_sql_connection_closed_max_lifetime_total{db_system="mysql"} 7
+# HELP db_sql_connection_max_open Maximum number of open connections to the database
+# TYPE db_sql_connection_max_open gauge
+db_sql_connection_max_open{db_system="mysql"} 10
+# HELP db_sql_connection_open The number of established connections both in use and idle
+# TYPE db_sql_connection_open gauge
+db_sql_connection_open{db_system="mysql",status="idle"} 2
+db_sql_connection_open{db_system="mysql",status="inuse"} 3
+# HELP db_sql_connection_wait_duration_milliseconds_total The total time blocked waiting for a new connection
+# TYPE db_sql_connection_wait_duration_milliseconds_total counter
+db_sql_connection_wait_duration_milliseconds_total{db_system="mysql"} 1.5
+# HELP db_sql_connection_wait_total The total number of connections waited for
+# TYPE db_sql_connection_wait_total counter
+db_sql_connection_wait_total{db_system="mysql"} 4
+`
+	require.NoError(t, testutil.CollectAndCompare(collector, strings.NewReader(expected)))
+	assert.Equal(t, 8, testutil.CollectAndCount(collector))
+}
+
+func TestSanitizeLabelName(t *testing.T) {
+	assert.Equal(t, "db_system", sanitizeLabelName("db.system"))
+	assert.Equal(t, "service_name_1", sanitizeLabelName("service-name/1"))
+}
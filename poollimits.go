@@ -0,0 +1,67 @@
+// Copyright Sam Xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otelsql
+
+import (
+	"database/sql"
+	"time"
+)
+
+// PoolLimits are the limits of the connection pool of a DB, which
+// database/sql does not expose apart from the maximum number of open
+// connections. Set them with Apply and pass them to WithPoolLimits, so that
+// the metrics of RegisterDBStatsMetrics report the limits the pool runs
+// with.
+type PoolLimits struct {
+	// MaxOpenConns is passed to sql.DB.SetMaxOpenConns.
+	MaxOpenConns int
+	// MaxIdleConns is the maximum number of idle connections. 0 keeps the
+	// default of database/sql, which is 2, and a negative value retains no
+	// idle connections. Like database/sql, it is reduced to MaxOpenConns if
+	// that is lower.
+	MaxIdleConns int
+	// ConnMaxLifetime is passed to sql.DB.SetConnMaxLifetime.
+	ConnMaxLifetime time.Duration
+	// ConnMaxIdleTime is passed to sql.DB.SetConnMaxIdleTime.
+	ConnMaxIdleTime time.Duration
+}
+
+// defaultMaxIdleConns is the maximum number of idle connections of database/sql
+// when it is not set.
+const defaultMaxIdleConns = 2
+
+// Apply sets the limits of the connection pool of db to l.
+func (l PoolLimits) Apply(db *sql.DB) {
+	db.SetMaxOpenConns(l.MaxOpenConns)
+	db.SetMaxIdleConns(l.maxIdleConns())
+	db.SetConnMaxLifetime(l.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(l.ConnMaxIdleTime)
+}
+
+// maxIdleConns returns the maximum number of idle connections database/sql
+// runs with for l.
+func (l PoolLimits) maxIdleConns() int {
+	n := l.MaxIdleConns
+	switch {
+	case n < 0:
+		return 0
+	case n == 0:
+		n = defaultMaxIdleConns
+	}
+	if l.MaxOpenConns > 0 && n > l.MaxOpenConns {
+		n = l.MaxOpenConns
+	}
+	return n
+}
@@ -0,0 +1,55 @@
+// Copyright Sam Xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otelsql
+
+import (
+	"context"
+	"runtime/pprof"
+)
+
+const (
+	pprofMethodLabel       = "otelsql.method"
+	pprofQuerySummaryLabel = "otelsql.query_summary"
+)
+
+// pprofLabels returns the pprof labels of a driver call, or nil if pprof
+// labels are disabled.
+func pprofLabels(cfg config, method Method, query string) *pprof.LabelSet {
+	if !cfg.PprofLabels {
+		return nil
+	}
+
+	labels := []string{pprofMethodLabel, string(method)}
+	if summary := querySummary(query); summary != "" {
+		labels = append(labels, pprofQuerySummaryLabel, summary)
+	}
+	set := pprof.Labels(labels...)
+	return &set
+}
+
+// setPprofLabels adds labels to the labels of ctx and sets them on the
+// current goroutine. It returns a func restoring the labels of ctx, which
+// are the labels of the goroutine before the call if the caller uses
+// pprof.Do.
+func setPprofLabels(ctx context.Context, labels *pprof.LabelSet) func() {
+	if labels == nil {
+		return func() {}
+	}
+
+	pprof.SetGoroutineLabels(pprof.WithLabels(ctx, *labels))
+	return func() {
+		pprof.SetGoroutineLabels(ctx)
+	}
+}
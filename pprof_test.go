@@ -0,0 +1,87 @@
+// Copyright Sam Xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otelsql
+
+import (
+	"bytes"
+	"context"
+	"runtime/pprof"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPprofLabels(t *testing.T) {
+	assert.Nil(t, pprofLabels(config{}, MethodConnQuery, "SELECT * FROM orders"))
+
+	cfg := config{PprofLabels: true}
+	testCases := []struct {
+		query    string
+		expected map[string]string
+	}{
+		{
+			query: "SELECT * FROM orders",
+			expected: map[string]string{
+				pprofMethodLabel:       string(MethodConnQuery),
+				pprofQuerySummaryLabel: "SELECT orders",
+			},
+		},
+		{
+			expected: map[string]string{pprofMethodLabel: string(MethodConnQuery)},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.query, func(t *testing.T) {
+			labels := pprofLabels(cfg, MethodConnQuery, tc.query)
+			require.NotNil(t, labels)
+
+			actual := map[string]string{}
+			pprof.ForLabels(pprof.WithLabels(context.Background(), *labels), func(key, value string) bool {
+				actual[key] = value
+				return true
+			})
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+// goroutineLabels returns the goroutine profile, which lists the labels of
+// the goroutines.
+func goroutineLabels(t *testing.T) string {
+	var buf bytes.Buffer
+	require.NoError(t, pprof.Lookup("goroutine").WriteTo(&buf, 1))
+	return buf.String()
+}
+
+func TestSetPprofLabels(t *testing.T) {
+	ctx := pprof.WithLabels(context.Background(), pprof.Labels("caller", "test"))
+	pprof.SetGoroutineLabels(ctx)
+	defer pprof.SetGoroutineLabels(context.Background())
+
+	restore := setPprofLabels(ctx, pprofLabels(config{PprofLabels: true}, MethodConnExec, "DELETE FROM orders"))
+	profile := goroutineLabels(t)
+	assert.Contains(t, profile, `"otelsql.method":"sql.conn.exec"`)
+	assert.Contains(t, profile, `"otelsql.query_summary":"DELETE orders"`)
+
+	restore()
+	profile = goroutineLabels(t)
+	assert.NotContains(t, profile, `"otelsql.method":"sql.conn.exec"`)
+	assert.Contains(t, profile, `"caller":"test"`)
+
+	// Disabled
+	assert.NotPanics(t, setPprofLabels(ctx, nil))
+}
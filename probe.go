@@ -0,0 +1,216 @@
+// Copyright Sam Xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otelsql
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.18.0"
+
+	"github.com/XSAM/otelsql/semconvutil"
+)
+
+var (
+	dbSystemVersionKey = attribute.Key("db.system.version")
+	dbNamespaceKey     = attribute.Key("db.namespace")
+)
+
+// serverVersionQueries maps db.system and db.system.name values to the query
+// returning the version of the database server.
+var serverVersionQueries = map[string]string{
+	"postgresql":           "SHOW server_version",
+	"cockroachdb":          "SELECT version()",
+	"mysql":                "SELECT VERSION()",
+	"mariadb":              "SELECT VERSION()",
+	"mssql":                "SELECT CAST(SERVERPROPERTY('ProductVersion') AS NVARCHAR(128))",
+	"microsoft.sql_server": "SELECT CAST(SERVERPROPERTY('ProductVersion') AS NVARCHAR(128))",
+	"sqlite":               "SELECT sqlite_version()",
+	"clickhouse":           "SELECT version()",
+}
+
+// databaseNameQueries maps db.system and db.system.name values to the query
+// returning the name of the current database.
+var databaseNameQueries = map[string]string{
+	"postgresql":           "SELECT current_database()",
+	"cockroachdb":          "SELECT current_database()",
+	"mysql":                "SELECT DATABASE()",
+	"mariadb":              "SELECT DATABASE()",
+	"mssql":                "SELECT DB_NAME()",
+	"microsoft.sql_server": "SELECT DB_NAME()",
+	"clickhouse":           "SELECT currentDatabase()",
+}
+
+// hasAttribute reports whether attrs contains key.
+func hasAttribute(attrs []attribute.KeyValue, key attribute.Key) bool {
+	for _, attr := range attrs {
+		if attr.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// probeQueries returns the probe queries enabled by cfg.
+func probeQueries(cfg config) []probeQuery {
+	var queries []probeQuery
+	if query, ok := serverVersionQueries[cfg.dbSystem]; ok && cfg.ServerVersionProbe {
+		queries = append(queries, probeQuery{key: dbSystemVersionKey, query: query})
+	}
+	if query, ok := databaseNameQueries[cfg.dbSystem]; ok && cfg.DatabaseNameProbe &&
+		!hasAttribute(cfg.Attributes, semconv.DBNameKey) && !hasAttribute(cfg.Attributes, dbNamespaceKey) {
+		queries = append(queries, probeQuery{key: semconv.DBNameKey, query: query})
+	}
+	return queries
+}
+
+// probeQuery is a query whose single value is recorded as the attribute key.
+type probeQuery struct {
+	key   attribute.Key
+	query string
+}
+
+// serverProbe runs queries once on the first connection of a pool to
+// discover attributes of the database server, which are then added to the
+// spans of all connections.
+type serverProbe struct {
+	queries []probeQuery
+
+	once  sync.Once
+	attrs atomic.Pointer[[]attribute.KeyValue]
+}
+
+// newServerProbe returns nil if there are no queries to run.
+func newServerProbe(queries []probeQuery) *serverProbe {
+	if len(queries) == 0 {
+		return nil
+	}
+	return &serverProbe{queries: queries}
+}
+
+// run runs the probe queries on conn if they have not run yet. Failed queries
+// are reported to the global error handler and are not retried.
+func (p *serverProbe) run(ctx context.Context, conn driver.Conn) {
+	if p == nil {
+		return
+	}
+
+	p.once.Do(func() {
+		attrs := make([]attribute.KeyValue, 0, len(p.queries))
+		for _, q := range p.queries {
+			value, err := queryValue(ctx, conn, q.query)
+			if err != nil {
+				otel.Handle(fmt.Errorf("otelsql: probe %s: %w", q.key, err))
+				continue
+			}
+			if value != "" {
+				attrs = append(attrs, q.key.String(value))
+			}
+		}
+		p.attrs.Store(&attrs)
+	})
+}
+
+// systemAttributes returns the attributes discovered by the probe other than
+// the database name, which namespaceAttributes records, or nil if it has not
+// run yet.
+func (p *serverProbe) systemAttributes() []attribute.KeyValue {
+	var attrs []attribute.KeyValue
+	for _, attr := range p.attributes() {
+		if attr.Key != semconv.DBNameKey {
+			attrs = append(attrs, attr)
+		}
+	}
+	return attrs
+}
+
+// namespaceAttributes returns the database name attributes of a call that
+// are not part of the attributes of cfg, following the semantic conventions
+// of cfg: the namespace tracked by the connection, or else the name found by
+// the database name probe.
+func namespaceAttributes(ctx context.Context, cfg config) []attribute.KeyValue {
+	name := namespaceFromContext(ctx)
+	if name == "" {
+		for _, attr := range cfg.serverProbe.attributes() {
+			if attr.Key == semconv.DBNameKey {
+				name = attr.Value.AsString()
+			}
+		}
+	}
+	if name == "" {
+		return nil
+	}
+	return semconvutil.DBNamespaceAttributes(name, cfg.semconvStability)
+}
+
+// attributes returns the attributes discovered by the probe, or nil if it has
+// not run yet.
+func (p *serverProbe) attributes() []attribute.KeyValue {
+	if p == nil {
+		return nil
+	}
+	if attrs := p.attrs.Load(); attrs != nil {
+		return *attrs
+	}
+	return nil
+}
+
+// queryValue returns the first column of the first row returned by query.
+func queryValue(ctx context.Context, conn driver.Conn, query string) (string, error) {
+	var (
+		rows driver.Rows
+		err  error
+	)
+	if queryer, ok := conn.(driver.QueryerContext); ok {
+		rows, err = queryer.QueryContext(ctx, query, nil)
+	} else {
+		var stmt driver.Stmt
+		if stmt, err = conn.Prepare(query); err != nil {
+			return "", err
+		}
+		defer stmt.Close()
+		rows, err = stmt.Query(nil) // nolint
+	}
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	dest := make([]driver.Value, len(rows.Columns()))
+	if len(dest) == 0 {
+		return "", nil
+	}
+	if err = rows.Next(dest); err != nil {
+		if err == io.EOF {
+			return "", nil
+		}
+		return "", err
+	}
+
+	switch v := dest[0].(type) {
+	case nil:
+		return "", nil
+	case []byte:
+		return string(v), nil
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
@@ -0,0 +1,174 @@
+// Copyright Sam Xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !otelsql_noop
+
+package otelsql
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.18.0"
+)
+
+// probeConn answers queries with the single value registered for them.
+type probeConn struct {
+	*mockConn
+
+	values  map[string]driver.Value
+	queries []string
+}
+
+func (c *probeConn) QueryContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+	c.queries = append(c.queries, query)
+	value, ok := c.values[query]
+	if !ok {
+		return nil, errors.New("unknown query")
+	}
+	return &probeRows{value: value}, nil
+}
+
+type probeRows struct {
+	value driver.Value
+	done  bool
+}
+
+func (r *probeRows) Columns() []string { return []string{"value"} }
+
+func (r *probeRows) Close() error { return nil }
+
+func (r *probeRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = r.value
+	return nil
+}
+
+func TestServerProbe(t *testing.T) {
+	conn := &probeConn{
+		mockConn: newMockConn(false),
+		values: map[string]driver.Value{
+			"SELECT VERSION()": []byte("8.0.36"),
+			"SELECT 1":         int64(1),
+		},
+	}
+	probe := newServerProbe([]probeQuery{
+		{key: dbSystemVersionKey, query: "SELECT VERSION()"},
+		{key: "unknown", query: "SELECT unknown"},
+		{key: "one", query: "SELECT 1"},
+	})
+	assert.Nil(t, probe.attributes())
+
+	probe.run(context.Background(), conn)
+	assert.Equal(t, []attribute.KeyValue{
+		dbSystemVersionKey.String("8.0.36"),
+		attribute.String("one", "1"),
+	}, probe.attributes())
+
+	// Queries run only once.
+	probe.run(context.Background(), conn)
+	assert.Len(t, conn.queries, 3)
+}
+
+func TestServerProbe_Nil(t *testing.T) {
+	var probe *serverProbe
+	assert.Nil(t, newServerProbe(nil))
+	assert.NotPanics(t, func() {
+		probe.run(context.Background(), newMockConn(false))
+	})
+	assert.Nil(t, probe.attributes())
+}
+
+func TestWithServerVersionProbe(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+	cfg := newConfig(WithTracerProvider(tp), WithDBSystem("mysql"), WithServerVersionProbe())
+
+	conn := &probeConn{
+		mockConn: newMockConn(false),
+		values:   map[string]driver.Value{"SELECT VERSION()": "8.0.36"},
+	}
+	otelConnector := newConnector(newMockConnector(nil, false), &otDriver{cfg: cfg})
+	otelConnector.cfg.serverProbe.run(context.Background(), conn)
+
+	otelConn, err := otelConnector.Connect(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, otelConn.(*otConn).Ping(context.Background()))
+
+	spans := sr.Ended()
+	require.NotEmpty(t, spans)
+	assert.Contains(t, spans[len(spans)-1].Attributes(), dbSystemVersionKey.String("8.0.36"))
+
+	// Unknown systems are not probed.
+	assert.Nil(t, newConfig(WithDBSystem("unknown"), WithServerVersionProbe()).serverProbe)
+}
+
+func TestProbeQueries(t *testing.T) {
+	testCases := []struct {
+		name     string
+		options  []Option
+		expected []probeQuery
+	}{
+		{
+			name:    "disabled",
+			options: []Option{WithDBSystem("postgresql")},
+		},
+		{
+			name:    "all",
+			options: []Option{WithDBSystem("postgresql"), WithServerVersionProbe(), WithDatabaseNameProbe()},
+			expected: []probeQuery{
+				{key: dbSystemVersionKey, query: "SHOW server_version"},
+				{key: semconv.DBNameKey, query: "SELECT current_database()"},
+			},
+		},
+		{
+			name: "known database name",
+			options: []Option{
+				WithDBSystem("mysql"), WithDatabaseNameProbe(),
+				WithAttributes(semconv.DBName("orders")),
+			},
+		},
+		{
+			name: "system from attributes",
+			options: []Option{
+				WithAttributes(semconv.DBSystemPostgreSQL), WithServerVersionProbe(),
+			},
+			expected: []probeQuery{
+				{key: dbSystemVersionKey, query: "SHOW server_version"},
+			},
+		},
+		{
+			name:    "unknown system",
+			options: []Option{WithDBSystem("unknown"), WithServerVersionProbe(), WithDatabaseNameProbe()},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newConfig(tc.options...)
+			assert.Equal(t, tc.expected, probeQueries(cfg))
+		})
+	}
+}
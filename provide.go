@@ -0,0 +1,96 @@
+// Copyright Sam Xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otelsql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"slices"
+
+	"go.opentelemetry.io/otel"
+)
+
+// DBConfig holds everything needed to open an instrumented DB with ProvideDB, so
+// that it can be provided as a single value by dependency injection
+// frameworks like uber/fx and google/wire.
+type DBConfig struct {
+	// DriverName and DataSourceName are passed to Open, unless Connector is
+	// set.
+	DriverName     string
+	DataSourceName string
+
+	// Connector, if set, is passed to OpenDB in place of DriverName and
+	// DataSourceName.
+	Connector driver.Connector
+
+	// Options are used both to instrument the DB and to register its DBStats
+	// metrics.
+	Options []Option
+
+	// PoolLimits, if set, are applied to the DB and reported with its DBStats
+	// metrics, see WithPoolLimits.
+	PoolLimits *PoolLimits
+}
+
+// ProvideDB opens an instrumented DB and registers its DBStats metrics. The
+// returned func stops observing the metrics and closes the DB, errors are
+// reported to the global error handler. Its signatures fit the constructors
+// of uber/fx, e.g. with fx.StopHook, and google/wire, which takes the func as
+// the cleanup of the DB:
+//
+//	func provideDB(lc fx.Lifecycle, cfg otelsql.DBConfig) (*sql.DB, error) {
+//		db, shutdown, err := otelsql.ProvideDB(cfg)
+//		if err != nil {
+//			return nil, err
+//		}
+//		lc.Append(fx.StopHook(shutdown))
+//		return db, nil
+//	}
+func ProvideDB(cfg DBConfig) (*sql.DB, func(), error) {
+	options := cfg.Options
+	if cfg.PoolLimits != nil {
+		options = append(slices.Clip(options), WithPoolLimits(*cfg.PoolLimits))
+	}
+
+	var db *sql.DB
+	switch {
+	case cfg.Connector != nil:
+		db = OpenDB(cfg.Connector, options...)
+	case cfg.DriverName != "":
+		var err error
+		if db, err = Open(cfg.DriverName, cfg.DataSourceName, options...); err != nil {
+			return nil, nil, err
+		}
+	default:
+		return nil, nil, errors.New("otelsql: DBConfig has neither a connector nor a driver name")
+	}
+	if cfg.PoolLimits != nil {
+		cfg.PoolLimits.Apply(db)
+	}
+
+	registration, err := RegisterDBStatsMetricsWithShutdown(db, options...)
+	if err != nil {
+		_ = db.Close()
+		return nil, nil, err
+	}
+
+	return db, func() {
+		if err := errors.Join(registration.Shutdown(context.Background()), db.Close()); err != nil {
+			otel.Handle(err)
+		}
+	}, nil
+}
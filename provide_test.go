@@ -0,0 +1,89 @@
+// Copyright Sam Xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !otelsql_noop
+
+package otelsql
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestProvideDB(t *testing.T) {
+	connector, err := newMockDriver(false).OpenConnector("")
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name string
+		cfg  DBConfig
+	}{
+		{
+			name: "driver name",
+			cfg:  DBConfig{DriverName: testDriverWithoutContextName},
+		},
+		{
+			name: "connector",
+			cfg:  DBConfig{Connector: connector},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := sdkmetric.NewManualReader()
+			mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(r))
+			tc.cfg.Options = []Option{WithMeterProvider(mp)}
+			tc.cfg.PoolLimits = &PoolLimits{MaxOpenConns: 4, MaxIdleConns: 2, ConnMaxLifetime: time.Minute}
+
+			db, shutdown, err := ProvideDB(tc.cfg)
+			require.NoError(t, err)
+			assert.Len(t, tc.cfg.Options, 1)
+			assert.Equal(t, 4, db.Stats().MaxOpenConnections)
+			require.NoError(t, db.PingContext(context.Background()))
+
+			assert.Contains(t, collectMetricNames(t, r), "db.sql.connection.max_idle")
+
+			shutdown()
+			assert.Error(t, db.PingContext(context.Background()))
+			assert.NotContains(t, collectMetricNames(t, r), "db.sql.connection.max_idle")
+		})
+	}
+}
+
+func TestProvideDBWithoutDriver(t *testing.T) {
+	db, shutdown, err := ProvideDB(DBConfig{})
+	assert.Error(t, err)
+	assert.Nil(t, db)
+	assert.Nil(t, shutdown)
+}
+
+func collectMetricNames(t *testing.T, r sdkmetric.Reader) []string {
+	t.Helper()
+
+	got := &metricdata.ResourceMetrics{}
+	require.NoError(t, r.Collect(context.Background(), got))
+	var names []string
+	for _, sm := range got.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			names = append(names, m.Name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,253 @@
+// Copyright Sam Xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otelsql
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// querySamplesSize is the number of the latest durations of a statement kept
+// to compute its percentiles.
+const querySamplesSize = 256
+
+// QueryStats are the statistics of the statements sharing a fingerprint
+// aggregated by a QueryAggregator.
+type QueryStats struct {
+	// Fingerprint is the statement without its punctuation, literals and
+	// placeholders, e.g. "SELECT id FROM orders WHERE customer_id".
+	Fingerprint string
+	// Count is the number of calls.
+	Count int64
+	// Errors is the number of failed calls.
+	Errors int64
+	// Total is the sum of the durations of the calls.
+	Total time.Duration
+	// Max is the longest duration of the calls.
+	Max time.Duration
+	// P50 and P95 are the median and 95th percentile of the durations of
+	// the latest calls.
+	P50 time.Duration
+	P95 time.Duration
+	// LastError is the message of the error of the latest failed call.
+	LastError string
+	// LastSeen is the end time of the latest call.
+	LastSeen time.Time
+}
+
+// QueryAggregator aggregates the statements executed through the
+// instrumented drivers by fingerprint, like a client-side
+// pg_stat_statements, to find the slowest and most frequent ones without a
+// tracing backend. Pass it to WithQueryAggregator.
+//
+// At most maxStatements fingerprints are kept. Once full, the least frequent
+// fingerprint is evicted for a new one.
+//
+// Notice: This type is EXPERIMENTAL and may be changed or removed in a
+// later release.
+type QueryAggregator struct {
+	maxStatements int
+
+	mu      sync.Mutex
+	entries map[string]*queryStatsEntry
+}
+
+type queryStatsEntry struct {
+	stats   QueryStats
+	samples []time.Duration
+	next    int
+}
+
+// NewQueryAggregator returns a QueryAggregator keeping at most maxStatements
+// fingerprints. A maxStatements less than 1 is treated as 1.
+func NewQueryAggregator(maxStatements int) *QueryAggregator {
+	if maxStatements < 1 {
+		maxStatements = 1
+	}
+	return &QueryAggregator{
+		maxStatements: maxStatements,
+		entries:       make(map[string]*queryStatsEntry),
+	}
+}
+
+// Slowest returns the statistics of the n statements with the highest P95,
+// slowest first.
+func (a *QueryAggregator) Slowest(n int) []QueryStats {
+	return a.top(n, func(x, y QueryStats) int {
+		return compareDesc(x.P95, y.P95)
+	})
+}
+
+// MostFrequent returns the statistics of the n most called statements, most
+// frequent first.
+func (a *QueryAggregator) MostFrequent(n int) []QueryStats {
+	return a.top(n, func(x, y QueryStats) int {
+		return compareDesc(x.Count, y.Count)
+	})
+}
+
+// Reset drops the aggregated statistics.
+func (a *QueryAggregator) Reset() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	clear(a.entries)
+}
+
+// Report calls report with the n slowest statements every interval, e.g. to
+// log them, until ctx is done.
+func (a *QueryAggregator) Report(ctx context.Context, interval time.Duration, n int, report func([]QueryStats)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			report(a.Slowest(n))
+		}
+	}
+}
+
+func (a *QueryAggregator) top(n int, cmp func(x, y QueryStats) int) []QueryStats {
+	a.mu.Lock()
+	stats := make([]QueryStats, 0, len(a.entries))
+	for _, entry := range a.entries {
+		stats = append(stats, entry.snapshot())
+	}
+	a.mu.Unlock()
+
+	slices.SortFunc(stats, func(x, y QueryStats) int {
+		if c := cmp(x, y); c != 0 {
+			return c
+		}
+		return strings.Compare(x.Fingerprint, y.Fingerprint)
+	})
+	if n >= 0 && n < len(stats) {
+		stats = stats[:n]
+	}
+	return stats
+}
+
+// observe returns the func recording a call of query started now, which
+// then calls next, if any.
+func (a *QueryAggregator) observe(query string, next func(error)) func(error) {
+	start := time.Now()
+	return func(err error) {
+		if next != nil {
+			next(err)
+		}
+		a.record(queryFingerprint(query), time.Since(start), err)
+	}
+}
+
+func (a *QueryAggregator) record(fingerprint string, duration time.Duration, err error) {
+	if fingerprint == "" {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	entry, ok := a.entries[fingerprint]
+	if !ok {
+		if len(a.entries) >= a.maxStatements {
+			a.evict()
+		}
+		entry = &queryStatsEntry{stats: QueryStats{Fingerprint: fingerprint}}
+		a.entries[fingerprint] = entry
+	}
+
+	stats := &entry.stats
+	stats.Count++
+	stats.Total += duration
+	stats.Max = max(stats.Max, duration)
+	stats.LastSeen = time.Now()
+	if err != nil {
+		stats.Errors++
+		stats.LastError = err.Error()
+	}
+
+	if len(entry.samples) < querySamplesSize {
+		entry.samples = append(entry.samples, duration)
+	} else {
+		entry.samples[entry.next] = duration
+		entry.next = (entry.next + 1) % querySamplesSize
+	}
+}
+
+// evict drops the least frequent fingerprint, the least recently seen one
+// among equally frequent ones.
+func (a *QueryAggregator) evict() {
+	var victim *queryStatsEntry
+	for _, entry := range a.entries {
+		if victim == nil || entry.stats.Count < victim.stats.Count ||
+			entry.stats.Count == victim.stats.Count && entry.stats.LastSeen.Before(victim.stats.LastSeen) {
+			victim = entry
+		}
+	}
+	if victim != nil {
+		delete(a.entries, victim.stats.Fingerprint)
+	}
+}
+
+// snapshot returns the statistics of the entry with its percentiles.
+func (e *queryStatsEntry) snapshot() QueryStats {
+	stats := e.stats
+	samples := slices.Clone(e.samples)
+	slices.Sort(samples)
+	stats.P50 = percentile(samples, 50)
+	stats.P95 = percentile(samples, 95)
+	return stats
+}
+
+// percentile returns the p-th percentile of sorted with the nearest-rank
+// method.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := (p*len(sorted) + 99) / 100
+	return sorted[max(rank, 1)-1]
+}
+
+func compareDesc[T int64 | time.Duration](x, y T) int {
+	switch {
+	case x > y:
+		return -1
+	case x < y:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// queryFingerprint returns the words and identifiers of query, without its
+// comments, punctuation, literals and placeholders, so that the executions of
+// a statement with different arguments, or different placeholder styles,
+// share the same fingerprint.
+func queryFingerprint(query string) string {
+	tokens := sqlTokens(query)
+	words := tokens[:0]
+	for _, token := range tokens {
+		if isSQLIdentifier(token) {
+			words = append(words, token)
+		}
+	}
+	return strings.Join(words, " ")
+}
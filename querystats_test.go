@@ -0,0 +1,132 @@
+// Copyright Sam Xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otelsql
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryFingerprint(t *testing.T) {
+	testCases := []struct {
+		query    string
+		expected string
+	}{
+		{query: "SELECT * FROM orders WHERE id = 1", expected: "SELECT FROM orders WHERE id"},
+		{query: "SELECT * FROM orders WHERE id = ?", expected: "SELECT FROM orders WHERE id"},
+		{query: "select * from orders where id = $1 -- by id", expected: "select from orders where id"},
+		{query: "UPDATE t SET name = 'it''s' WHERE id IN (1, 2, 3)", expected: "UPDATE t SET name WHERE id IN"},
+		{query: "/* comment only */"},
+		{query: `SELECT id FROM users WHERE name = ""`, expected: "SELECT id FROM users WHERE name"},
+		{query: "SELECT id FROM [] WHERE name = ``", expected: "SELECT id FROM WHERE name"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.query, func(t *testing.T) {
+			assert.Equal(t, tc.expected, queryFingerprint(tc.query))
+		})
+	}
+}
+
+func TestQueryAggregator(t *testing.T) {
+	a := NewQueryAggregator(10)
+	for i := 1; i <= 100; i++ {
+		a.record("SELECT FROM orders", time.Duration(i)*time.Millisecond, nil)
+	}
+	a.record("SELECT FROM users", time.Second, errors.New("timeout"))
+
+	slowest := a.Slowest(1)
+	require.Len(t, slowest, 1)
+	assert.Equal(t, "SELECT FROM users", slowest[0].Fingerprint)
+	assert.Equal(t, int64(1), slowest[0].Errors)
+	assert.Equal(t, "timeout", slowest[0].LastError)
+
+	frequent := a.MostFrequent(-1)
+	require.Len(t, frequent, 2)
+	orders := frequent[0]
+	assert.Equal(t, "SELECT FROM orders", orders.Fingerprint)
+	assert.Equal(t, int64(100), orders.Count)
+	assert.Equal(t, 5050*time.Millisecond, orders.Total)
+	assert.Equal(t, 100*time.Millisecond, orders.Max)
+	assert.Equal(t, 50*time.Millisecond, orders.P50)
+	assert.Equal(t, 95*time.Millisecond, orders.P95)
+	assert.False(t, orders.LastSeen.IsZero())
+
+	a.Reset()
+	assert.Empty(t, a.MostFrequent(10))
+}
+
+func TestQueryAggregatorEviction(t *testing.T) {
+	a := NewQueryAggregator(2)
+	a.record("a", time.Millisecond, nil)
+	a.record("a", time.Millisecond, nil)
+	a.record("b", time.Millisecond, nil)
+	a.record("c", time.Millisecond, nil)
+
+	var fingerprints []string
+	for _, stats := range a.MostFrequent(10) {
+		fingerprints = append(fingerprints, stats.Fingerprint)
+	}
+	assert.Equal(t, []string{"a", "c"}, fingerprints)
+}
+
+func TestQueryAggregatorReport(t *testing.T) {
+	a := NewQueryAggregator(10)
+	a.record("a", time.Millisecond, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	reported := make(chan []QueryStats)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		a.Report(ctx, time.Millisecond, 5, func(stats []QueryStats) {
+			select {
+			case reported <- stats:
+			case <-ctx.Done():
+			}
+		})
+	}()
+
+	stats := <-reported
+	require.Len(t, stats, 1)
+	assert.Equal(t, "a", stats[0].Fingerprint)
+
+	cancel()
+	<-done
+}
+
+func TestOtConn_QueryAggregator(t *testing.T) {
+	ctx, _, tracer, _ := prepareTraces(true)
+	cfg := newMockConfig(t, tracer)
+	cfg.QueryAggregator = NewQueryAggregator(10)
+	otelConn := newConn(newMockConn(false), cfg)
+
+	for _, id := range []string{"1", "2"} {
+		_, err := otelConn.ExecContext(ctx, "DELETE FROM orders WHERE id = "+id, nil)
+		require.NoError(t, err)
+	}
+	_, err := otelConn.PrepareContext(ctx, "SELECT 1")
+	require.NoError(t, err)
+
+	stats := cfg.QueryAggregator.MostFrequent(10)
+	require.Len(t, stats, 1)
+	assert.Equal(t, "DELETE FROM orders WHERE id", stats[0].Fingerprint)
+	assert.Equal(t, int64(2), stats[0].Count)
+}
@@ -0,0 +1,106 @@
+// Copyright Sam Xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otelsql
+
+import (
+	"database/sql/driver"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// RecordedQuery is a statement captured by a QueryRecorder.
+type RecordedQuery struct {
+	// Method is the method the statement was sent to the driver with.
+	Method Method
+	// Query is the statement as sent to the driver, including the comment
+	// injected by WithSQLCommenter, if any. The statements executed through
+	// prepared statements are the prepared ones.
+	Query string
+	// Args are the redacted arguments of the statement, the name of named
+	// arguments prefixed by "@", and "?" for the others.
+	Args []string
+}
+
+// String returns q as a line for golden files, e.g.
+// "sql.conn.exec: INSERT INTO t VALUES (?, ?) [?, ?]".
+func (q RecordedQuery) String() string {
+	if len(q.Args) == 0 {
+		return string(q.Method) + ": " + q.Query
+	}
+	return string(q.Method) + ": " + q.Query + " [" + strings.Join(q.Args, ", ") + "]"
+}
+
+// QueryRecorder captures the statements sent to the driver through Exec,
+// Query, and Prepare calls in memory, with their arguments redacted, to
+// assert in tests on the SQL an application issues. Pass it to
+// WithQueryRecorder.
+//
+// Its methods are safe for concurrent use.
+type QueryRecorder struct {
+	mu      sync.Mutex
+	queries []RecordedQuery
+}
+
+// NewQueryRecorder returns an empty QueryRecorder.
+func NewQueryRecorder() *QueryRecorder {
+	return &QueryRecorder{}
+}
+
+// Queries returns the captured statements, in the order they were sent.
+func (r *QueryRecorder) Queries() []RecordedQuery {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.queries)
+}
+
+// String returns the captured statements, one per line, see
+// RecordedQuery.String.
+func (r *QueryRecorder) String() string {
+	var b strings.Builder
+	for _, q := range r.Queries() {
+		b.WriteString(q.String())
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// Reset drops the captured statements.
+func (r *QueryRecorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.queries = nil
+}
+
+// record captures query sent with method and args. It is a no-op on a nil
+// QueryRecorder.
+func (r *QueryRecorder) record(method Method, query string, args []driver.NamedValue) {
+	if r == nil {
+		return
+	}
+
+	var redacted []string
+	for _, arg := range args {
+		if arg.Name != "" {
+			redacted = append(redacted, "@"+arg.Name)
+		} else {
+			redacted = append(redacted, "?")
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.queries = append(r.queries, RecordedQuery{Method: method, Query: query, Args: redacted})
+}
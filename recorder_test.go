@@ -0,0 +1,73 @@
+// Copyright Sam Xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otelsql
+
+import (
+	"database/sql/driver"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+func TestQueryRecorder(t *testing.T) {
+	r := NewQueryRecorder()
+	r.record(MethodConnExec, "INSERT INTO t VALUES (?, ?)", []driver.NamedValue{
+		{Ordinal: 1, Value: "secret"},
+		{Name: "id", Ordinal: 2, Value: 1},
+	})
+	r.record(MethodConnPrepare, "SELECT 1", nil)
+
+	assert.Equal(t, []RecordedQuery{
+		{Method: MethodConnExec, Query: "INSERT INTO t VALUES (?, ?)", Args: []string{"?", "@id"}},
+		{Method: MethodConnPrepare, Query: "SELECT 1"},
+	}, r.Queries())
+	assert.Equal(t, "sql.conn.exec: INSERT INTO t VALUES (?, ?) [?, @id]\nsql.conn.prepare: SELECT 1\n", r.String())
+
+	r.Reset()
+	assert.Empty(t, r.Queries())
+
+	// A nil recorder is a no-op.
+	var nilRecorder *QueryRecorder
+	nilRecorder.record(MethodConnExec, "SELECT 1", nil)
+}
+
+func TestOtConn_QueryRecorder(t *testing.T) {
+	ctx := newTestSpanContext(t)
+	_, _, tracer, _ := prepareTraces(true)
+	cfg := newMockConfig(t, tracer)
+	cfg.QueryRecorder = NewQueryRecorder()
+	cfg.SQLCommenter = &commenter{enabled: true, propagator: propagation.TraceContext{}}
+	otelConn := newConn(newMockConn(false), cfg)
+
+	_, err := otelConn.ExecContext(ctx, "DELETE FROM t WHERE id = ?", []driver.NamedValue{{Ordinal: 1, Value: "secret"}})
+	require.NoError(t, err)
+	stmt, err := otelConn.PrepareContext(ctx, "SELECT * FROM t")
+	require.NoError(t, err)
+	_, err = stmt.(driver.StmtExecContext).ExecContext(ctx, nil)
+	require.NoError(t, err)
+
+	queries := cfg.QueryRecorder.Queries()
+	require.Len(t, queries, 3)
+	assert.Equal(t, MethodConnExec, queries[0].Method)
+	assert.True(t, strings.HasPrefix(queries[0].Query, "DELETE FROM t WHERE id = ? /*traceparent="), queries[0].Query)
+	assert.Equal(t, []string{"?"}, queries[0].Args)
+	assert.Equal(t, MethodConnPrepare, queries[1].Method)
+	assert.True(t, strings.HasPrefix(queries[1].Query, "SELECT * FROM t /*traceparent="), queries[1].Query)
+	assert.Equal(t, RecordedQuery{Method: MethodStmtExec, Query: queries[1].Query}, queries[2])
+	assert.NotContains(t, cfg.QueryRecorder.String(), "secret")
+}
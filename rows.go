@@ -18,127 +18,274 @@ import (
 	"context"
 	"database/sql/driver"
 	"io"
+	"reflect"
+	"runtime/pprof"
+	"slices"
+	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.18.0"
 	"go.opentelemetry.io/otel/trace"
 )
 
 var (
-	_ driver.Rows                           = (*otRows)(nil)
-	_ driver.RowsNextResultSet              = (*otRows)(nil)
-	_ driver.RowsColumnTypeDatabaseTypeName = (*otRows)(nil)
-	_ driver.RowsColumnTypeLength           = (*otRows)(nil)
-	_ driver.RowsColumnTypeNullable         = (*otRows)(nil)
-	_ driver.RowsColumnTypePrecisionScale   = (*otRows)(nil)
+	rowsReturnedKey   = attribute.Key("db.response.returned_rows")
+	timeToFirstRowKey = attribute.Key("db.client.response.time_to_first_row")
 )
 
+var _ driver.Rows = (*otRows)(nil)
+
 type otRows struct {
 	driver.Rows
 
-	span    trace.Span
-	cfg     config
-	onClose func(err error)
+	span     trace.Span
+	cfg      config
+	onClose  func(err error)
+	progress *rowsProgress
+	firstRow *firstRow
+	// merged reports whether span is the span of the query returning the
+	// rows, see SpanOptions.MergeRowsIntoQuerySpan.
+	merged bool
+
+	// ctx is the context of the query, and pprofLabels are the pprof labels
+	// set while reading rows, if enabled.
+	ctx         context.Context
+	pprofLabels *pprof.LabelSet
+}
+
+// firstRow tracks the time to the first row read.
+type firstRow struct {
+	start time.Time
+	read  bool
+}
+
+// rowsProgress tracks the rows read for sql.rows.progress and
+// db.response.large_result_set events.
+type rowsProgress struct {
+	count int
+	// countAtEvent and timeAtEvent are the row count and the time of the last
+	// event, or of the creation of the rows.
+	countAtEvent int
+	timeAtEvent  time.Time
 }
 
 func newRows(ctx context.Context, rows driver.Rows, cfg config) *otRows {
+	return newRowsWithSpan(ctx, rows, cfg, nil)
+}
+
+// newRowsWithSpan returns rows returned by the query of querySpan, which may
+// be nil. If SpanOptions.MergeRowsIntoQuerySpan is set, the rows record on
+// querySpan and end it on Close, with the number of rows read, instead of
+// creating a sql.rows span. Otherwise the sql.rows span relates to querySpan
+// as decided by SpanOptions.RowsSpanRelation.
+func newRowsWithSpan(ctx context.Context, rows driver.Rows, cfg config, querySpan trace.Span) *otRows {
 	var span trace.Span
+	merged := cfg.SpanOptions.MergeRowsIntoQuerySpan && querySpan != nil
+	if merged {
+		span = querySpan
+	}
 
 	method := MethodRows
-	onClose := recordMetric(ctx, cfg.Instruments, cfg, method, "", nil)
+	onClose := noopOnDefer
+	if !cfg.DisableRowsMeasurement {
+		onClose = recordMetric(ctx, cfg.Instruments, cfg, method, "", nil)
+	}
 
-	if !cfg.SpanOptions.OmitRows && filterSpan(ctx, cfg.SpanOptions, method, "", nil) {
-		_, span = createSpan(ctx, cfg, method, false, "", nil)
+	if span == nil && !cfg.SpanOptions.OmitRows && filterSpan(ctx, cfg.SpanOptions, method, "", nil) {
+		spanCtx := ctx
+		var opts []trace.SpanStartOption
+		if querySpan != nil {
+			switch cfg.SpanOptions.RowsSpanRelation {
+			case RowsSpanRelationLink:
+				opts = append(opts, trace.WithLinks(trace.Link{SpanContext: querySpan.SpanContext()}))
+			case RowsSpanRelationChild:
+				spanCtx = trace.ContextWithSpan(ctx, querySpan)
+			}
+		}
+		_, span = createSpan(spanCtx, cfg, method, false, "", nil, opts...)
+	}
+
+	var progress *rowsProgress
+	if span != nil && (merged || cfg.SpanOptions.LargeResultSetRows > 0 ||
+		cfg.SpanOptions.RowsProgressInterval > 0 || cfg.SpanOptions.RowsProgressRows > 0) {
+		progress = &rowsProgress{timeAtEvent: time.Now()}
 	}
 
 	return &otRows{
-		Rows:    rows,
-		span:    span,
-		cfg:     cfg,
-		onClose: onClose,
+		Rows:     rows,
+		span:     span,
+		cfg:      cfg,
+		onClose:  onClose,
+		progress: progress,
+		firstRow: &firstRow{start: time.Now()},
+		merged:   merged,
+		ctx:      ctx,
 	}
 }
 
-// HasNextResultSet calls the implements the driver.RowsNextResultSet for otRows.
-// It returns the the underlying result of HasNextResultSet from the otRows.parent
-// if the parent implements driver.RowsNextResultSet.
-func (r otRows) HasNextResultSet() bool {
-	if v, ok := r.Rows.(driver.RowsNextResultSet); ok {
-		return v.HasNextResultSet()
-	}
+// wrapRows returns r as driver.Rows implementing only the optional
+// interfaces that the rows of the driver implement, so database/sql behaves
+// as it does with the rows of the driver. The ColumnType* interfaces are
+// advertised together when the rows of the driver implement any of them, as
+// the fallbacks of rowsColumnType match the ones of database/sql.
+func wrapRows(r *otRows) driver.Rows {
+	_, nextResultSet := r.Rows.(driver.RowsNextResultSet)
+	columnType := implementsColumnType(r.Rows)
 
-	return false
+	switch {
+	case nextResultSet && columnType:
+		return struct {
+			*otRows
+			rowsNextResultSet
+			rowsColumnType
+		}{r, rowsNextResultSet{r.Rows}, rowsColumnType{r.Rows}}
+	case nextResultSet:
+		return struct {
+			*otRows
+			rowsNextResultSet
+		}{r, rowsNextResultSet{r.Rows}}
+	case columnType:
+		return struct {
+			*otRows
+			rowsColumnType
+		}{r, rowsColumnType{r.Rows}}
+	default:
+		return r
+	}
 }
 
-// NextResultSet calls the implements the driver.RowsNextResultSet for otRows.
-// It returns the the underlying result of NextResultSet from the otRows.parent
-// if the parent implements driver.RowsNextResultSet.
-func (r otRows) NextResultSet() error {
-	if v, ok := r.Rows.(driver.RowsNextResultSet); ok {
-		return v.NextResultSet()
+func implementsColumnType(rows driver.Rows) bool {
+	switch rows.(type) {
+	case driver.RowsColumnTypeDatabaseTypeName,
+		driver.RowsColumnTypeLength,
+		driver.RowsColumnTypeNullable,
+		driver.RowsColumnTypePrecisionScale,
+		driver.RowsColumnTypeScanType:
+		return true
+	default:
+		return false
 	}
+}
+
+// rowsNextResultSet implements driver.RowsNextResultSet for rows of drivers
+// implementing it.
+type rowsNextResultSet struct {
+	rows driver.Rows
+}
 
-	return io.EOF
+// HasNextResultSet returns the underlying result of HasNextResultSet.
+func (r rowsNextResultSet) HasNextResultSet() bool {
+	return r.rows.(driver.RowsNextResultSet).HasNextResultSet()
 }
 
-// ColumnTypeDatabaseTypeName calls the implements the driver.RowsColumnTypeDatabaseTypeName for otRows.
-// It returns the the underlying result of ColumnTypeDatabaseTypeName from the otRows.Rows
-// if the Rows implements driver.RowsColumnTypeDatabaseTypeName.
-func (r otRows) ColumnTypeDatabaseTypeName(index int) string {
-	if v, ok := r.Rows.(driver.RowsColumnTypeDatabaseTypeName); ok {
+// NextResultSet returns the underlying result of NextResultSet.
+func (r rowsNextResultSet) NextResultSet() error {
+	return r.rows.(driver.RowsNextResultSet).NextResultSet()
+}
+
+// rowsColumnType implements the driver.RowsColumnType* interfaces for rows of
+// drivers implementing any of them.
+type rowsColumnType struct {
+	rows driver.Rows
+}
+
+// ColumnTypeDatabaseTypeName returns the underlying result of
+// ColumnTypeDatabaseTypeName if the rows implement
+// driver.RowsColumnTypeDatabaseTypeName.
+func (r rowsColumnType) ColumnTypeDatabaseTypeName(index int) string {
+	if v, ok := r.rows.(driver.RowsColumnTypeDatabaseTypeName); ok {
 		return v.ColumnTypeDatabaseTypeName(index)
 	}
 
 	return ""
 }
 
-// ColumnTypeLength calls the implements the driver.RowsColumnTypeLength for otRows.
-// It returns the the underlying result of ColumnTypeLength from the otRows.Rows
-// if the Rows implements driver.RowsColumnTypeLength.
-func (r otRows) ColumnTypeLength(index int) (length int64, ok bool) {
-	if v, ok := r.Rows.(driver.RowsColumnTypeLength); ok {
+// ColumnTypeLength returns the underlying result of ColumnTypeLength if the
+// rows implement driver.RowsColumnTypeLength.
+func (r rowsColumnType) ColumnTypeLength(index int) (length int64, ok bool) {
+	if v, ok := r.rows.(driver.RowsColumnTypeLength); ok {
 		return v.ColumnTypeLength(index)
 	}
 
 	return 0, false
 }
 
-// ColumnTypeNullable calls the implements the driver.RowsColumnTypeNullable for otRows.
-// It returns the the underlying result of ColumnTypeNullable from the otRows.Rows
-// if the Rows implements driver.RowsColumnTypeNullable.
-func (r otRows) ColumnTypeNullable(index int) (nullable, ok bool) {
-	if v, ok := r.Rows.(driver.RowsColumnTypeNullable); ok {
+// ColumnTypeNullable returns the underlying result of ColumnTypeNullable if
+// the rows implement driver.RowsColumnTypeNullable.
+func (r rowsColumnType) ColumnTypeNullable(index int) (nullable, ok bool) {
+	if v, ok := r.rows.(driver.RowsColumnTypeNullable); ok {
 		return v.ColumnTypeNullable(index)
 	}
 
 	return false, false
 }
 
-// ColumnTypePrecisionScale calls the implements the driver.RowsColumnTypePrecisionScale for otRows.
-// It returns the the underlying result of ColumnTypePrecisionScale from the otRows.Rows
-// if the Rows implements driver.RowsColumnTypePrecisionScale.
-func (r otRows) ColumnTypePrecisionScale(index int) (precision, scale int64, ok bool) {
-	if v, ok := r.Rows.(driver.RowsColumnTypePrecisionScale); ok {
+// ColumnTypePrecisionScale returns the underlying result of
+// ColumnTypePrecisionScale if the rows implement
+// driver.RowsColumnTypePrecisionScale.
+func (r rowsColumnType) ColumnTypePrecisionScale(index int) (precision, scale int64, ok bool) {
+	if v, ok := r.rows.(driver.RowsColumnTypePrecisionScale); ok {
 		return v.ColumnTypePrecisionScale(index)
 	}
 
 	return 0, 0, false
 }
 
+// ColumnTypeScanType returns the underlying result of ColumnTypeScanType if
+// the rows implement driver.RowsColumnTypeScanType, and the type of an empty
+// interface otherwise, like database/sql does.
+func (r rowsColumnType) ColumnTypeScanType(index int) reflect.Type {
+	if v, ok := r.rows.(driver.RowsColumnTypeScanType); ok {
+		return v.ColumnTypeScanType(index)
+	}
+
+	return reflect.TypeOf(new(any)).Elem()
+}
+
 func (r otRows) Close() (err error) {
+	defer setPprofLabels(r.ctx, r.pprofLabels)()
+	// metricErr is the error recorded by the metrics, which is nil when the
+	// error is downgraded to an event.
+	var metricErr error
 	defer func() {
 		if r.span != nil {
+			if r.merged {
+				r.span.SetAttributes(rowsReturnedKey.Int(r.progress.count))
+			}
 			r.span.End()
 		}
-		r.onClose(err)
+		r.onClose(metricErr)
+		err = wrapError(r.ctx, r.cfg, err)
 	}()
 
 	err = r.Rows.Close()
 	if err != nil {
-		recordSpanError(r.span, r.cfg.SpanOptions, err)
+		if r.cfg.SpanOptions.RowsCloseErrorAsEvent {
+			r.recordCloseErrorEvent(err)
+		} else {
+			metricErr = err
+			recordSpanError(r.ctx, r.span, r.cfg, MethodRows, "", err)
+		}
 	}
 	return
 }
 
+// recordCloseErrorEvent adds err, returned by Close, to the span as an
+// EventRowsCloseError event, leaving the span status unset.
+func (r otRows) recordCloseErrorEvent(err error) {
+	if r.span == nil || !r.span.IsRecording() ||
+		!shouldRecordError(r.ctx, r.cfg.SpanOptions, MethodRows, "", err) {
+		return
+	}
+	r.span.AddEvent(string(EventRowsCloseError), trace.WithAttributes(
+		semconv.ExceptionType(reflect.TypeOf(err).String()),
+		semconv.ExceptionMessage(err.Error()),
+	))
+}
+
 func (r otRows) Next(dest []driver.Value) (err error) {
+	defer setPprofLabels(r.ctx, r.pprofLabels)()
 	if r.cfg.SpanOptions.RowsNext && r.span != nil {
 		r.span.AddEvent(string(EventRowsNext))
 	}
@@ -146,7 +293,65 @@ func (r otRows) Next(dest []driver.Value) (err error) {
 	err = r.Rows.Next(dest)
 	// io.EOF is not an error. It is expected to happen during iteration.
 	if err != nil && err != io.EOF {
-		recordSpanError(r.span, r.cfg.SpanOptions, err)
+		recordSpanError(r.ctx, r.span, r.cfg, MethodRows, "", err)
+		err = wrapError(r.ctx, r.cfg, err)
+	}
+	if err == nil && !r.firstRow.read {
+		r.recordFirstRow()
+	}
+	if err == nil && r.progress != nil {
+		r.recordProgress()
 	}
 	return
 }
+
+// recordFirstRow records the time between the query returning the rows and
+// the first row read, separating the latency of the query execution from the
+// one of reading its results.
+func (r otRows) recordFirstRow() {
+	r.firstRow.read = true
+	elapsed := time.Since(r.firstRow.start).Seconds()
+
+	if r.span != nil {
+		r.span.SetAttributes(timeToFirstRowKey.Float64(elapsed))
+	}
+	if r.cfg.Instruments != nil && r.cfg.Instruments.timeToFirstRow != nil {
+		attrs := append(slices.Clip(r.cfg.Attributes), contextAttributes(r.ctx)...)
+		r.cfg.Instruments.timeToFirstRow.Record(r.ctx, elapsed, metric.WithAttributes(attrs...))
+	}
+}
+
+// recordProgress counts a row read and adds a sql.rows.progress event if
+// enough rows or time have passed since the last one, and a
+// db.response.large_result_set event once the row count passes
+// SpanOptions.LargeResultSetRows.
+func (r otRows) recordProgress() {
+	p := r.progress
+	p.count++
+
+	opts := r.cfg.SpanOptions
+	if opts.LargeResultSetRows > 0 && p.count == opts.LargeResultSetRows+1 {
+		r.span.AddEvent(string(EventLargeResultSet),
+			trace.WithAttributes(rowsReturnedKey.Int(p.count)),
+		)
+	}
+	due := opts.RowsProgressRows > 0 && p.count-p.countAtEvent >= opts.RowsProgressRows
+	var now time.Time
+	if !due && opts.RowsProgressInterval > 0 {
+		now = time.Now()
+		due = now.Sub(p.timeAtEvent) >= opts.RowsProgressInterval
+	}
+	if !due {
+		return
+	}
+
+	if now.IsZero() {
+		now = time.Now()
+	}
+	r.span.AddEvent(string(EventRowsProgress),
+		trace.WithTimestamp(now),
+		trace.WithAttributes(rowsReturnedKey.Int(p.count)),
+	)
+	p.countAtEvent = p.count
+	p.timeAtEvent = now
+}
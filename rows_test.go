@@ -15,14 +15,20 @@
 package otelsql
 
 import (
+	"context"
 	"database/sql/driver"
 	"errors"
+	"fmt"
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.18.0"
 )
 
 type mockRows struct {
@@ -59,6 +65,16 @@ func newMockRows(shouldError bool) *mockRows {
 
 var _ driver.Rows = (*mockRows)(nil)
 
+type mockRowsWithScanType struct {
+	*mockRows
+}
+
+func (m mockRowsWithScanType) ColumnTypeScanType(int) reflect.Type {
+	return reflect.TypeOf(int64(0))
+}
+
+var _ driver.RowsColumnTypeScanType = mockRowsWithScanType{}
+
 func TestOtRows_Close(t *testing.T) {
 	testCases := []struct {
 		name  string
@@ -286,3 +302,370 @@ func TestNewRows(t *testing.T) {
 		})
 	}
 }
+
+func TestOtRows_NextProgress(t *testing.T) {
+	testCases := []struct {
+		name           string
+		interval       time.Duration
+		rows           int
+		expectedCounts []int64
+	}{
+		{
+			name: "disabled",
+		},
+		{
+			name:           "every 2 rows",
+			rows:           2,
+			expectedCounts: []int64{2, 4},
+		},
+		{
+			name:           "every nanosecond",
+			interval:       time.Nanosecond,
+			expectedCounts: []int64{1, 2, 3, 4, 5},
+		},
+		{
+			name:     "long interval",
+			interval: time.Hour,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, sr, tracer, _ := prepareTraces(false)
+
+			cfg := newMockConfig(t, tracer)
+			cfg.SpanOptions.RowsProgressInterval = tc.interval
+			cfg.SpanOptions.RowsProgressRows = tc.rows
+
+			rows := newRows(ctx, newMockRows(false), cfg)
+			for i := 0; i < 5; i++ {
+				time.Sleep(time.Microsecond)
+				require.NoError(t, rows.Next([]driver.Value{"test"}))
+			}
+
+			spanList := sr.Started()
+			require.Len(t, spanList, 2)
+			var counts []int64
+			for _, event := range spanList[1].Events() {
+				assert.Equal(t, string(EventRowsProgress), event.Name)
+				require.Len(t, event.Attributes, 1)
+				assert.Equal(t, rowsReturnedKey, event.Attributes[0].Key)
+				counts = append(counts, event.Attributes[0].Value.AsInt64())
+			}
+			assert.Equal(t, tc.expectedCounts, counts)
+		})
+	}
+}
+
+func TestOtRows_LargeResultSet(t *testing.T) {
+	ctx, sr, tracer, _ := prepareTraces(false)
+	cfg := newMockConfig(t, tracer)
+	cfg.SpanOptions.LargeResultSetRows = 2
+
+	rows := newRows(ctx, newMockRows(false), cfg)
+	for i := 0; i < 5; i++ {
+		require.NoError(t, rows.Next([]driver.Value{"test"}))
+	}
+	require.NoError(t, rows.Close())
+
+	spanList := sr.Ended()
+	require.Len(t, spanList, 2)
+	events := spanList[1].Events()
+	require.Len(t, events, 1)
+	assert.Equal(t, string(EventLargeResultSet), events[0].Name)
+	assert.Equal(t, []attribute.KeyValue{rowsReturnedKey.Int(3)}, events[0].Attributes)
+}
+
+func TestOtRows_TimeToFirstRow(t *testing.T) {
+	for _, shouldError := range []bool{false, true} {
+		t.Run(fmt.Sprintf("error=%t", shouldError), func(t *testing.T) {
+			ctx, sr, tracer, _ := prepareTraces(false)
+			mockTimeToFirstRow := &float64HistogramMock{}
+			cfg := newMockConfig(t, tracer)
+			cfg.Instruments = &instruments{latency: &float64HistogramMock{}, timeToFirstRow: mockTimeToFirstRow}
+
+			rows := newRows(ctx, newMockRows(shouldError), cfg)
+			for i := 0; i < 2; i++ {
+				_ = rows.Next([]driver.Value{"test"})
+			}
+			_ = rows.Close()
+
+			spanList := sr.Ended()
+			require.Len(t, spanList, 2)
+			var count int
+			for _, attr := range spanList[1].Attributes() {
+				if attr.Key == timeToFirstRowKey {
+					count++
+					assert.Positive(t, attr.Value.AsFloat64())
+				}
+			}
+			// Only a successful Next sets the attribute, once.
+			if shouldError {
+				assert.Zero(t, count)
+				assert.Zero(t, mockTimeToFirstRow.attributes.Len())
+			} else {
+				assert.Equal(t, 1, count)
+				assert.Equal(t, attribute.NewSet(cfg.Attributes...), mockTimeToFirstRow.attributes)
+			}
+		})
+	}
+}
+
+func TestNewRowsWithSpan_RowsSpanRelation(t *testing.T) {
+	for _, relation := range []RowsSpanRelation{RowsSpanRelationSibling, RowsSpanRelationLink, RowsSpanRelationChild} {
+		t.Run(fmt.Sprint(relation), func(t *testing.T) {
+			ctx, sr, tracer, dummySpan := prepareTraces(false)
+			cfg := newMockConfig(t, tracer)
+			cfg.SpanOptions.RowsSpanRelation = relation
+
+			_, querySpan := tracer.Start(ctx, "query")
+			querySpan.End()
+			rows := newRowsWithSpan(ctx, newMockRows(false), cfg, querySpan)
+			require.NoError(t, rows.Close())
+
+			spanList := sr.Ended()
+			rowsSpan := spanList[len(spanList)-1]
+			require.Equal(t, string(MethodRows), rowsSpan.Name())
+
+			switch relation {
+			case RowsSpanRelationChild:
+				assert.Equal(t, querySpan.SpanContext().SpanID(), rowsSpan.Parent().SpanID())
+				assert.Empty(t, rowsSpan.Links())
+			case RowsSpanRelationLink:
+				assert.Equal(t, dummySpan.SpanContext().SpanID(), rowsSpan.Parent().SpanID())
+				require.Len(t, rowsSpan.Links(), 1)
+				assert.Equal(t, querySpan.SpanContext(), rowsSpan.Links()[0].SpanContext)
+			default:
+				assert.Equal(t, dummySpan.SpanContext().SpanID(), rowsSpan.Parent().SpanID())
+				assert.Empty(t, rowsSpan.Links())
+			}
+		})
+	}
+}
+
+func TestOtRows_MergeRowsIntoQuerySpan(t *testing.T) {
+	for _, useStmt := range []bool{false, true} {
+		t.Run(fmt.Sprintf("stmt=%t", useStmt), func(t *testing.T) {
+			ctx, sr, tracer, _ := prepareTraces(true)
+			cfg := newMockConfig(t, tracer)
+			cfg.SpanOptions.MergeRowsIntoQuerySpan = true
+			otelConn := newConn(newMockConn(false), cfg)
+
+			var (
+				rows driver.Rows
+				err  error
+			)
+			expectedName := string(MethodConnQuery)
+			if useStmt {
+				expectedName = string(MethodStmtQuery)
+				var stmt driver.Stmt
+				stmt, err = otelConn.PrepareContext(ctx, "SELECT 1")
+				require.NoError(t, err)
+				rows, err = stmt.(*otStmt).QueryContext(ctx, nil)
+			} else {
+				rows, err = otelConn.QueryContext(ctx, "SELECT 1", nil)
+			}
+			require.NoError(t, err)
+
+			for _, span := range sr.Ended() {
+				assert.NotEqual(t, expectedName, span.Name(), "query span ended before rows are closed")
+			}
+
+			require.NoError(t, rows.Next([]driver.Value{"test"}))
+			require.NoError(t, rows.Next([]driver.Value{"test"}))
+			require.NoError(t, rows.Close())
+
+			spanList := sr.Ended()
+			querySpan := spanList[len(spanList)-1]
+			assert.Equal(t, expectedName, querySpan.Name())
+			assert.Contains(t, querySpan.Attributes(), rowsReturnedKey.Int(2))
+			for _, span := range spanList {
+				assert.NotEqual(t, string(MethodRows), span.Name())
+			}
+		})
+	}
+}
+
+func TestOtRows_CloseErrorAsEvent(t *testing.T) {
+	ctx, sr, tracer, _ := prepareTraces(false)
+	cfg := newMockConfig(t, tracer)
+	cfg.SpanOptions.RowsCloseErrorAsEvent = true
+
+	err := newRows(ctx, newMockRows(true), cfg).Close()
+	require.Error(t, err)
+
+	spanList := sr.Ended()
+	require.Len(t, spanList, 2)
+	span := spanList[1]
+	assert.Equal(t, codes.Unset, span.Status().Code)
+	require.Len(t, span.Events(), 1)
+	assert.Equal(t, string(EventRowsCloseError), span.Events()[0].Name)
+	assert.Contains(t, span.Events()[0].Attributes, semconv.ExceptionMessage(err.Error()))
+}
+
+func TestOtRows_MergeRowsIntoQuerySpanWithError(t *testing.T) {
+	ctx, sr, tracer, _ := prepareTraces(true)
+	cfg := newMockConfig(t, tracer)
+	cfg.SpanOptions.MergeRowsIntoQuerySpan = true
+
+	_, err := newConn(newMockConn(true), cfg).QueryContext(ctx, "SELECT 1", nil)
+	require.Error(t, err)
+
+	spanList := sr.Ended()
+	require.Len(t, spanList, 1)
+	assert.Equal(t, string(MethodConnQuery), spanList[0].Name())
+	assert.Equal(t, codes.Error, spanList[0].Status().Code)
+}
+
+func TestOtRows_UnwrappedRows(t *testing.T) {
+	for _, useStmt := range []bool{false, true} {
+		t.Run(fmt.Sprintf("stmt=%t", useStmt), func(t *testing.T) {
+			ctx, sr, tracer, _ := prepareTraces(true)
+			cfg := newMockConfig(t, tracer)
+			cfg.UnwrappedRows = true
+			cfg.SpanOptions.MergeRowsIntoQuerySpan = true
+			otelConn := newConn(newMockConn(false), cfg)
+
+			var (
+				rows driver.Rows
+				err  error
+			)
+			expectedName := string(MethodConnQuery)
+			if useStmt {
+				expectedName = string(MethodStmtQuery)
+				var stmt driver.Stmt
+				stmt, err = otelConn.PrepareContext(ctx, "SELECT 1")
+				require.NoError(t, err)
+				rows, err = stmt.(*otStmt).QueryContext(ctx, nil)
+			} else {
+				rows, err = otelConn.QueryContext(ctx, "SELECT 1", nil)
+			}
+			require.NoError(t, err)
+			assert.IsType(t, &mockRows{}, rows)
+
+			spanList := sr.Ended()
+			require.NotEmpty(t, spanList)
+			assert.Equal(t, expectedName, spanList[len(spanList)-1].Name())
+
+			require.NoError(t, rows.Close())
+			for _, span := range sr.Ended() {
+				assert.NotEqual(t, string(MethodRows), span.Name())
+			}
+		})
+	}
+}
+
+type mockRowsWithNextResultSet struct {
+	*mockRows
+}
+
+func (m mockRowsWithNextResultSet) HasNextResultSet() bool {
+	return true
+}
+
+func (m mockRowsWithNextResultSet) NextResultSet() error {
+	return nil
+}
+
+var _ driver.RowsNextResultSet = mockRowsWithNextResultSet{}
+
+type mockRowsWithAll struct {
+	*mockRows
+	mockRowsWithNextResultSet
+	mockRowsWithScanType
+}
+
+func TestWrapRows(t *testing.T) {
+	testCases := []struct {
+		name              string
+		rows              driver.Rows
+		nextResultSet     bool
+		columnType        bool
+		expectedScanType  reflect.Type
+		expectedTypeName  string
+		expectedHasResult bool
+	}{
+		{
+			name: "rows only",
+			rows: newMockRows(false),
+		},
+		{
+			name:              "next result set",
+			rows:              mockRowsWithNextResultSet{newMockRows(false)},
+			nextResultSet:     true,
+			expectedHasResult: true,
+		},
+		{
+			name:             "column type",
+			rows:             mockRowsWithScanType{newMockRows(false)},
+			columnType:       true,
+			expectedScanType: reflect.TypeOf(int64(0)),
+		},
+		{
+			name: "all",
+			rows: mockRowsWithAll{
+				newMockRows(false),
+				mockRowsWithNextResultSet{newMockRows(false)},
+				mockRowsWithScanType{newMockRows(false)},
+			},
+			nextResultSet:     true,
+			columnType:        true,
+			expectedScanType:  reflect.TypeOf(int64(0)),
+			expectedHasResult: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, _, tracer, _ := prepareTraces(true)
+			cfg := newMockConfig(t, tracer)
+
+			rows := wrapRows(newRows(context.Background(), tc.rows, cfg))
+
+			nextResultSet, ok := rows.(driver.RowsNextResultSet)
+			assert.Equal(t, tc.nextResultSet, ok)
+			if ok {
+				assert.Equal(t, tc.expectedHasResult, nextResultSet.HasNextResultSet())
+				assert.NoError(t, nextResultSet.NextResultSet())
+			}
+
+			scanType, ok := rows.(driver.RowsColumnTypeScanType)
+			assert.Equal(t, tc.columnType, ok)
+			if ok {
+				assert.Equal(t, tc.expectedScanType, scanType.ColumnTypeScanType(0))
+
+				// Interfaces missing from the rows of the driver fall back to
+				// the values database/sql uses.
+				typeName, ok := rows.(driver.RowsColumnTypeDatabaseTypeName)
+				require.True(t, ok)
+				assert.Equal(t, tc.expectedTypeName, typeName.ColumnTypeDatabaseTypeName(0))
+				_, ok = rows.(driver.RowsColumnTypeNullable).ColumnTypeNullable(0)
+				assert.False(t, ok)
+			}
+
+			// The rows still record on Close.
+			require.NoError(t, rows.Close())
+		})
+	}
+}
+
+func TestOtRows_DisableRowsMeasurement(t *testing.T) {
+	for _, disable := range []bool{false, true} {
+		t.Run(fmt.Sprintf("disable=%t", disable), func(t *testing.T) {
+			ctx, sr, tracer, _ := prepareTraces(true)
+			mockLatency := &float64HistogramMock{}
+			mockDuration := &float64HistogramMock{}
+			cfg := newMockConfig(t, tracer)
+			cfg.Instruments = &instruments{latency: mockLatency, operationDuration: mockDuration}
+			cfg.DisableRowsMeasurement = disable
+
+			rows := newRows(ctx, newMockRows(false), cfg)
+			require.NoError(t, rows.Close())
+
+			assert.Equal(t, !disable, mockLatency.attributes.HasValue(queryMethodKey))
+			assert.Equal(t, !disable, mockDuration.attributes.HasValue(queryMethodKey))
+			// The span is kept.
+			assert.Len(t, sr.Ended(), 1)
+		})
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright Sam Xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otelsql
+
+import "strings"
+
+// execMethod returns the method used to instrument an exec call of query.
+// If savepoint detection is enabled and query is a savepoint statement, the
+// corresponding savepoint method is returned instead of defaultMethod.
+func execMethod(cfg config, defaultMethod Method, query string) Method {
+	if !cfg.SavepointDetection {
+		return defaultMethod
+	}
+	if method, ok := savepointMethod(query); ok {
+		return method
+	}
+	return defaultMethod
+}
+
+// savepointMethod detects savepoint statements issued through Exec:
+//
+//	SAVEPOINT name
+//	SAVE TRAN[SACTION] name (SQL Server)
+//	ROLLBACK TO [SAVEPOINT] name
+//	ROLLBACK TRAN[SACTION] name (SQL Server)
+//	RELEASE [SAVEPOINT] name
+func savepointMethod(query string) (Method, bool) {
+	fields := strings.Fields(query)
+	if len(fields) < 2 {
+		return "", false
+	}
+
+	switch strings.ToUpper(fields[0]) {
+	case "SAVEPOINT":
+		return MethodTxSavepoint, true
+	case "SAVE":
+		if isTranKeyword(fields[1]) {
+			return MethodTxSavepoint, true
+		}
+	case "ROLLBACK":
+		if strings.EqualFold(fields[1], "TO") {
+			return MethodTxRollbackTo, true
+		}
+		// ROLLBACK TRANSACTION without a name rolls back the whole transaction.
+		if isTranKeyword(fields[1]) && len(fields) > 2 {
+			return MethodTxRollbackTo, true
+		}
+	case "RELEASE":
+		return MethodTxReleaseSavepoint, true
+	}
+	return "", false
+}
+
+func isTranKeyword(s string) bool {
+	return strings.EqualFold(s, "TRAN") || strings.EqualFold(s, "TRANSACTION")
+}
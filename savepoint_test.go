@@ -0,0 +1,75 @@
+// Copyright Sam Xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otelsql
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSavepointMethod(t *testing.T) {
+	testCases := []struct {
+		query          string
+		expectedMethod Method
+	}{
+		{query: "SAVEPOINT sp1", expectedMethod: MethodTxSavepoint},
+		{query: "  savepoint sp1", expectedMethod: MethodTxSavepoint},
+		{query: "SAVE TRANSACTION sp1", expectedMethod: MethodTxSavepoint},
+		{query: "ROLLBACK TO SAVEPOINT sp1", expectedMethod: MethodTxRollbackTo},
+		{query: "rollback to sp1", expectedMethod: MethodTxRollbackTo},
+		{query: "ROLLBACK TRAN sp1", expectedMethod: MethodTxRollbackTo},
+		{query: "RELEASE SAVEPOINT sp1", expectedMethod: MethodTxReleaseSavepoint},
+		{query: "ROLLBACK"},
+		{query: "ROLLBACK TRANSACTION"},
+		{query: "SELECT 1"},
+		{query: ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.query, func(t *testing.T) {
+			method, ok := savepointMethod(tc.query)
+			assert.Equal(t, tc.expectedMethod != "", ok)
+			assert.Equal(t, tc.expectedMethod, method)
+		})
+	}
+}
+
+func TestOtConn_ExecContextWithSavepointDetection(t *testing.T) {
+	for _, enabled := range []bool{true, false} {
+		ctx, sr, tracer, _ := prepareTraces(true)
+		cfg := newMockConfig(t, tracer)
+		cfg.SavepointDetection = enabled
+		otelConn := newConn(newMockConn(false), cfg)
+
+		_, err := otelConn.ExecContext(ctx, "SAVEPOINT sp1", nil)
+		require.NoError(t, err)
+
+		spanList := sr.Ended()
+		require.Len(t, spanList, 1)
+		if enabled {
+			assert.Equal(t, string(MethodTxSavepoint), spanList[0].Name())
+		} else {
+			assert.Equal(t, string(MethodConnExec), spanList[0].Name())
+		}
+	}
+}
+
+func TestExecMethod(t *testing.T) {
+	assert.Equal(t, MethodStmtExec, execMethod(config{}, MethodStmtExec, "SAVEPOINT sp1"))
+	assert.Equal(t, MethodTxSavepoint, execMethod(config{SavepointDetection: true}, MethodStmtExec, "SAVEPOINT sp1"))
+	assert.Equal(t, MethodStmtExec, execMethod(config{SavepointDetection: true}, MethodStmtExec, "INSERT INTO t VALUES (1)"))
+}
@@ -0,0 +1,171 @@
+// Copyright Sam Xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package semconvutil provides the semantic convention helpers used by
+// otelsql, so that companion integrations, like ORM adapters or custom
+// drivers, can emit the same attributes.
+package semconvutil // import "github.com/XSAM/otelsql/semconvutil"
+
+import (
+	"os"
+	"reflect"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.18.0"
+)
+
+// OptInEnvKey is the environment variable opting in to the stable semantic
+// conventions, see StabilityFromEnv.
+const OptInEnvKey = "OTEL_SEMCONV_STABILITY_OPT_IN"
+
+// stableSchemaURL is the schema URL of the version of the semantic
+// conventions in which the database conventions became stable.
+const stableSchemaURL = "https://opentelemetry.io/schemas/1.33.0"
+
+var (
+	dbSystemNameKey = attribute.Key("db.system.name")
+	dbQueryTextKey  = attribute.Key("db.query.text")
+	dbNamespaceKey  = attribute.Key("db.namespace")
+	errorTypeKey    = attribute.Key("error.type")
+
+	serverAddressKey = attribute.Key("server.address")
+	serverPortKey    = attribute.Key("server.port")
+)
+
+// Stability is the version of the database semantic conventions to follow.
+type Stability int
+
+const (
+	// StabilityOld follows the conventions used so far.
+	StabilityOld Stability = iota
+	// StabilityStable follows the stable conventions only.
+	StabilityStable
+	// StabilityDup follows both the conventions used so far and the stable
+	// ones.
+	StabilityDup
+)
+
+// ParseStability returns the conventions opted in by optIn, a
+// comma-separated list in the format of OTEL_SEMCONV_STABILITY_OPT_IN, with
+// "database" for the stable ones and "database/dup" for both.
+func ParseStability(optIn string) Stability {
+	stability := StabilityOld
+	for _, v := range strings.Split(optIn, ",") {
+		switch strings.TrimSpace(v) {
+		case "database/dup":
+			return StabilityDup
+		case "database":
+			stability = StabilityStable
+		}
+	}
+	return stability
+}
+
+// StabilityFromEnv returns the conventions opted in by the
+// OTEL_SEMCONV_STABILITY_OPT_IN environment variable, see ParseStability.
+func StabilityFromEnv() Stability {
+	return ParseStability(os.Getenv(OptInEnvKey))
+}
+
+// SchemaURL returns the schema URL of the semantic conventions followed with
+// stability. The conventions used so far are kept in the dup mode, as they
+// are still emitted.
+func SchemaURL(stability Stability) string {
+	if stability == StabilityStable {
+		return stableSchemaURL
+	}
+	return semconv.SchemaURL
+}
+
+// DBSystemAttributes returns the attributes recording the database
+// management system: db.system for the conventions used so far and
+// db.system.name for the stable ones.
+func DBSystemAttributes(system string, stability Stability) []attribute.KeyValue {
+	switch stability {
+	case StabilityStable:
+		return []attribute.KeyValue{dbSystemNameKey.String(system)}
+	case StabilityDup:
+		return []attribute.KeyValue{semconv.DBSystemKey.String(system), dbSystemNameKey.String(system)}
+	default:
+		return []attribute.KeyValue{semconv.DBSystemKey.String(system)}
+	}
+}
+
+// DBQueryTextAttributes returns the attributes recording query: db.statement
+// for the conventions used so far and db.query.text for the stable ones.
+func DBQueryTextAttributes(query string, stability Stability) []attribute.KeyValue {
+	switch stability {
+	case StabilityStable:
+		return []attribute.KeyValue{dbQueryTextKey.String(query)}
+	case StabilityDup:
+		return []attribute.KeyValue{semconv.DBStatementKey.String(query), dbQueryTextKey.String(query)}
+	default:
+		return []attribute.KeyValue{semconv.DBStatementKey.String(query)}
+	}
+}
+
+// DBNamespaceAttributes returns the attributes recording the name of the
+// database: db.name for the conventions used so far and db.namespace for the
+// stable ones.
+func DBNamespaceAttributes(name string, stability Stability) []attribute.KeyValue {
+	switch stability {
+	case StabilityStable:
+		return []attribute.KeyValue{dbNamespaceKey.String(name)}
+	case StabilityDup:
+		return []attribute.KeyValue{semconv.DBNameKey.String(name), dbNamespaceKey.String(name)}
+	default:
+		return []attribute.KeyValue{semconv.DBNameKey.String(name)}
+	}
+}
+
+// ServerAttributes returns the attributes recording the address and port of
+// the database server: server.address and server.port, together with
+// net.peer.name and net.peer.port unless only the stable conventions are
+// followed. An empty host or a non-positive port is omitted.
+func ServerAttributes(host string, port int, stability Stability) []attribute.KeyValue {
+	var attrs []attribute.KeyValue
+	if host != "" {
+		attrs = append(attrs, serverAddressKey.String(host))
+	}
+	if port > 0 {
+		attrs = append(attrs, serverPortKey.Int(port))
+	}
+	if stability == StabilityStable {
+		return attrs
+	}
+	if host != "" {
+		attrs = append(attrs, semconv.NetPeerName(host))
+	}
+	if port > 0 {
+		attrs = append(attrs, semconv.NetPeerPort(port))
+	}
+	return attrs
+}
+
+// ErrorTypeAttributes returns the error.type attribute of err, the fully
+// qualified name of its type, for the stable conventions. It returns nil if
+// err is nil or only the conventions used so far are followed.
+func ErrorTypeAttributes(err error, stability Stability) []attribute.KeyValue {
+	if err == nil || stability == StabilityOld {
+		return nil
+	}
+
+	t := reflect.TypeOf(err)
+	value := t.String()
+	if t.PkgPath() != "" && t.Name() != "" {
+		value = t.PkgPath() + "." + t.Name()
+	}
+	return []attribute.KeyValue{errorTypeKey.String(value)}
+}
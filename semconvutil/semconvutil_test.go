@@ -0,0 +1,113 @@
+// Copyright Sam Xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package semconvutil
+
+import (
+	"database/sql/driver"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.18.0"
+)
+
+func TestParseStability(t *testing.T) {
+	for _, tc := range []struct {
+		value    string
+		expected Stability
+	}{
+		{value: "", expected: StabilityOld},
+		{value: "http", expected: StabilityOld},
+		{value: "database", expected: StabilityStable},
+		{value: "http, database", expected: StabilityStable},
+		{value: "database/dup", expected: StabilityDup},
+		{value: "database,database/dup", expected: StabilityDup},
+	} {
+		t.Run(tc.value, func(t *testing.T) {
+			assert.Equal(t, tc.expected, ParseStability(tc.value))
+		})
+	}
+}
+
+func TestStabilityFromEnv(t *testing.T) {
+	t.Setenv(OptInEnvKey, "database")
+	assert.Equal(t, StabilityStable, StabilityFromEnv())
+}
+
+func TestDBQueryTextAttributes(t *testing.T) {
+	const query = "SELECT 1"
+	assert.Equal(t, []attribute.KeyValue{semconv.DBStatement(query)}, DBQueryTextAttributes(query, StabilityOld))
+	assert.Equal(t, []attribute.KeyValue{dbQueryTextKey.String(query)}, DBQueryTextAttributes(query, StabilityStable))
+	assert.Equal(t,
+		[]attribute.KeyValue{semconv.DBStatement(query), dbQueryTextKey.String(query)},
+		DBQueryTextAttributes(query, StabilityDup),
+	)
+}
+
+func TestDBNamespaceAttributes(t *testing.T) {
+	const name = "orders"
+	assert.Equal(t, []attribute.KeyValue{semconv.DBName(name)}, DBNamespaceAttributes(name, StabilityOld))
+	assert.Equal(t, []attribute.KeyValue{dbNamespaceKey.String(name)}, DBNamespaceAttributes(name, StabilityStable))
+	assert.Equal(t,
+		[]attribute.KeyValue{semconv.DBName(name), dbNamespaceKey.String(name)},
+		DBNamespaceAttributes(name, StabilityDup),
+	)
+}
+
+func TestSchemaURL(t *testing.T) {
+	assert.Equal(t, semconv.SchemaURL, SchemaURL(StabilityOld))
+	assert.Equal(t, semconv.SchemaURL, SchemaURL(StabilityDup))
+	assert.Equal(t, "https://opentelemetry.io/schemas/1.33.0", SchemaURL(StabilityStable))
+}
+
+func TestDBSystemAttributes(t *testing.T) {
+	assert.Equal(t, []attribute.KeyValue{semconv.DBSystemPostgreSQL}, DBSystemAttributes("postgresql", StabilityOld))
+	assert.Equal(t, []attribute.KeyValue{attribute.String("db.system.name", "postgresql")}, DBSystemAttributes("postgresql", StabilityStable))
+	assert.Equal(t, []attribute.KeyValue{
+		semconv.DBSystemPostgreSQL,
+		attribute.String("db.system.name", "postgresql"),
+	}, DBSystemAttributes("postgresql", StabilityDup))
+}
+
+func TestServerAttributes(t *testing.T) {
+	assert.Equal(t, []attribute.KeyValue{
+		attribute.String("server.address", "db"),
+		attribute.Int("server.port", 5432),
+		semconv.NetPeerName("db"),
+		semconv.NetPeerPort(5432),
+	}, ServerAttributes("db", 5432, StabilityOld))
+	assert.Equal(t, ServerAttributes("db", 5432, StabilityOld), ServerAttributes("db", 5432, StabilityDup))
+	assert.Equal(t, []attribute.KeyValue{
+		attribute.String("server.address", "db"),
+	}, ServerAttributes("db", 0, StabilityStable))
+	assert.Empty(t, ServerAttributes("", 0, StabilityOld))
+}
+
+type namedError struct{}
+
+func (namedError) Error() string { return "named" }
+
+func TestErrorTypeAttributes(t *testing.T) {
+	assert.Nil(t, ErrorTypeAttributes(nil, StabilityStable))
+	assert.Nil(t, ErrorTypeAttributes(driver.ErrBadConn, StabilityOld))
+	assert.Equal(t,
+		[]attribute.KeyValue{errorTypeKey.String("*errors.errorString")},
+		ErrorTypeAttributes(driver.ErrBadConn, StabilityDup),
+	)
+	assert.Equal(t,
+		[]attribute.KeyValue{errorTypeKey.String("github.com/XSAM/otelsql/semconvutil.namedError")},
+		ErrorTypeAttributes(namedError{}, StabilityStable),
+	)
+}
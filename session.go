@@ -0,0 +1,147 @@
+// Copyright Sam Xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otelsql
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// maxApplicationNameLength is the maximum length of a PostgreSQL
+// application_name, longer values are truncated by the server.
+const maxApplicationNameLength = 63
+
+// applicationName returns the application_name propagating the span context
+// of ctx in the traceparent format, prefixed by prefix. The prefix is
+// shortened, on a rune boundary, if the result would exceed
+// maxApplicationNameLength.
+func applicationName(ctx context.Context, prefix string) string {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return prefix
+	}
+
+	traceparent := fmt.Sprintf("00-%s-%s-%s", sc.TraceID(), sc.SpanID(), sc.TraceFlags())
+	if n := maxApplicationNameLength - len(traceparent); len(prefix) > n {
+		for n > 0 && !utf8.RuneStart(prefix[n]) {
+			n--
+		}
+		prefix = prefix[:n]
+	}
+	return prefix + traceparent
+}
+
+// propagateApplicationName sets the application_name of the session to
+// propagate the span context of ctx if it differs from the last value set on
+// this connection. It is not set again in a transaction in which it failed.
+func (c *otConn) propagateApplicationName(ctx context.Context) {
+	if c == nil || !c.cfg.ApplicationNamePropagation || c.txAborted {
+		return
+	}
+
+	name := applicationName(ctx, c.cfg.ApplicationNamePrefix)
+	if name == c.applicationName {
+		return
+	}
+
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return
+	}
+	query := "SET application_name = '" + strings.ReplaceAll(name, "'", "''") + "'"
+	if _, err := execer.ExecContext(ctx, query, nil); err != nil {
+		otel.Handle(err)
+		c.txAborted = c.inTx
+		return
+	}
+	c.applicationName = name
+}
+
+// sessionContextKey is the SESSION_CONTEXT key holding the traceparent.
+const sessionContextKey = "traceparent"
+
+// propagateSessionContext sets the traceparent of the span context of ctx in
+// the SQL Server SESSION_CONTEXT of the session. Without a valid span context,
+// the value is cleared so that it does not refer to a previous operation.
+func (c *otConn) propagateSessionContext(ctx context.Context) {
+	if !c.cfg.SessionContextPropagation {
+		return
+	}
+
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return
+	}
+
+	value := "NULL"
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		value = fmt.Sprintf("N'00-%s-%s-%s'", sc.TraceID(), sc.SpanID(), sc.TraceFlags())
+	}
+	query := "EXEC sp_set_session_context N'" + sessionContextKey + "', " + value
+	if _, err := execer.ExecContext(ctx, query, nil); err != nil {
+		otel.Handle(err)
+	}
+}
+
+// mysqlConnectionAttributeReplacer removes the separators of the
+// connectionAttributes DSN parameter from keys and values.
+var mysqlConnectionAttributeReplacer = strings.NewReplacer(",", "", ":", "", "&", "", "?", "")
+
+// withMySQLConnectionAttributes returns dsn, a go-sql-driver/mysql DSN, with
+// the connectionAttributes parameter extended by attrs and the traceparent of
+// the span context of ctx. The attributes are visible in
+// performance_schema.session_connect_attrs.
+func withMySQLConnectionAttributes(ctx context.Context, dsn string, attrs map[string]string) string {
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys)+1)
+	for _, k := range keys {
+		pairs = append(pairs, mysqlConnectionAttributeReplacer.Replace(k)+":"+mysqlConnectionAttributeReplacer.Replace(attrs[k]))
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		pairs = append(pairs, fmt.Sprintf("traceparent:00-%s-%s-%s", sc.TraceID(), sc.SpanID(), sc.TraceFlags()))
+	}
+	if len(pairs) == 0 {
+		return dsn
+	}
+	value := strings.Join(pairs, ",")
+
+	const param = "connectionAttributes="
+	if i := strings.Index(dsn, param); i >= 0 {
+		// Extend the existing parameter.
+		end := i + len(param)
+		if j := strings.IndexByte(dsn[end:], '&'); j >= 0 {
+			end += j
+		} else {
+			end = len(dsn)
+		}
+		return dsn[:end] + "," + value + dsn[end:]
+	}
+	if strings.Contains(dsn, "?") {
+		return dsn + "&" + param + value
+	}
+	return dsn + "?" + param + value
+}
@@ -0,0 +1,222 @@
+// Copyright Sam Xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otelsql
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func newTestSpanContext(t testing.TB) context.Context {
+	traceID, err := trace.TraceIDFromHex("a3d3b88cf7994e554c1afbdceec1620b")
+	require.NoError(t, err)
+	spanID, err := trace.SpanIDFromHex("683ec6a9a3a265fb")
+	require.NoError(t, err)
+	return trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+	}))
+}
+
+func TestApplicationName(t *testing.T) {
+	ctx := newTestSpanContext(t)
+	traceparent := "00-a3d3b88cf7994e554c1afbdceec1620b-683ec6a9a3a265fb-01"
+
+	assert.Equal(t, "orders/", applicationName(context.Background(), "orders/"))
+	assert.Equal(t, "orders/"+traceparent, applicationName(ctx, "orders/"))
+
+	name := applicationName(ctx, strings.Repeat("x", 20))
+	assert.Len(t, name, maxApplicationNameLength)
+	assert.True(t, strings.HasSuffix(name, traceparent))
+
+	// The prefix is shortened on a rune boundary.
+	name = applicationName(ctx, "x"+strings.Repeat("é", 4))
+	assert.Equal(t, "xééé"+traceparent, name)
+	assert.True(t, utf8.ValidString(name))
+}
+
+func TestOtConn_PropagateApplicationName(t *testing.T) {
+	ctx := newTestSpanContext(t)
+	cfg := newMockConfig(t, noop.NewTracerProvider().Tracer("test"))
+	cfg.ApplicationNamePropagation = true
+	cfg.ApplicationNamePrefix = "o'rders/"
+	mc := newMockConn(false)
+	otelConn := newConn(mc, cfg)
+
+	_, err := otelConn.QueryContext(ctx, "SELECT 1", nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, mc.execContextCount)
+	assert.Equal(t, "SET application_name = 'o''rders/00-a3d3b88cf7994e554c1afbdceec1620b-683ec6a9a3a265fb-01'", mc.execContextQuery)
+
+	// The application_name is not set again for the same span context.
+	_, err = otelConn.ExecContext(ctx, "UPDATE t SET a = 1", nil)
+	require.NoError(t, err)
+	assert.Equal(t, 2, mc.execContextCount)
+	assert.Equal(t, "UPDATE t SET a = 1", mc.execContextQuery)
+
+	// A different span context sets it again.
+	_, err = otelConn.ExecContext(context.Background(), "UPDATE t SET a = 1", nil)
+	require.NoError(t, err)
+	assert.Equal(t, 4, mc.execContextCount)
+
+	// Disabled
+	mc = newMockConn(false)
+	cfg.ApplicationNamePropagation = false
+	otelConn = newConn(mc, cfg)
+	_, err = otelConn.QueryContext(ctx, "SELECT 1", nil)
+	require.NoError(t, err)
+	assert.Equal(t, 0, mc.execContextCount)
+}
+
+func TestOtConn_PropagateApplicationNameInTx(t *testing.T) {
+	ctx := newTestSpanContext(t)
+	cfg := newMockConfig(t, noop.NewTracerProvider().Tracer("test"))
+	cfg.ApplicationNamePropagation = true
+	mc := newMockConn(false)
+	otelConn := newConn(mc, cfg)
+
+	// The application_name set in a rolled back transaction is set again.
+	tx, err := otelConn.BeginTx(ctx, driver.TxOptions{})
+	require.NoError(t, err)
+	_, err = otelConn.ExecContext(ctx, "UPDATE t SET a = 1", nil)
+	require.NoError(t, err)
+	assert.Equal(t, 2, mc.execContextCount)
+	require.NoError(t, tx.Rollback())
+	_, err = otelConn.ExecContext(ctx, "UPDATE t SET a = 1", nil)
+	require.NoError(t, err)
+	assert.Equal(t, 4, mc.execContextCount)
+
+	// It is not set again in a transaction in which it failed.
+	tx, err = otelConn.BeginTx(context.Background(), driver.TxOptions{})
+	require.NoError(t, err)
+	mc.shouldError = true
+	_, err = otelConn.ExecContext(context.Background(), "UPDATE t SET a = 1", nil)
+	require.Error(t, err)
+	assert.Equal(t, 6, mc.execContextCount)
+	_, err = otelConn.ExecContext(ctx, "UPDATE t SET a = 1", nil)
+	require.Error(t, err)
+	assert.Equal(t, 7, mc.execContextCount)
+	require.NoError(t, tx.Rollback())
+
+	mc.shouldError = false
+	_, err = otelConn.ExecContext(ctx, "UPDATE t SET a = 1", nil)
+	require.NoError(t, err)
+	assert.Equal(t, 9, mc.execContextCount)
+}
+
+func TestOtConn_PropagateSessionContext(t *testing.T) {
+	cfg := newMockConfig(t, noop.NewTracerProvider().Tracer("test"))
+	cfg.SessionContextPropagation = true
+	mc := newMockConn(false)
+	otelConn := newConn(mc, cfg)
+
+	err := otelConn.ResetSession(newTestSpanContext(t))
+	require.NoError(t, err)
+	assert.Equal(t, 1, mc.execContextCount)
+	assert.Equal(t, "EXEC sp_set_session_context N'traceparent', N'00-a3d3b88cf7994e554c1afbdceec1620b-683ec6a9a3a265fb-01'", mc.execContextQuery)
+
+	// Without a span context, the value is cleared.
+	err = otelConn.ResetSession(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, mc.execContextCount)
+	assert.Equal(t, "EXEC sp_set_session_context N'traceparent', NULL", mc.execContextQuery)
+
+	// Failed resets do not set the session context.
+	mc = newMockConn(true)
+	otelConn = newConn(mc, cfg)
+	err = otelConn.ResetSession(newTestSpanContext(t))
+	require.Error(t, err)
+	assert.Equal(t, 0, mc.execContextCount)
+
+	// Disabled
+	mc = newMockConn(false)
+	cfg.SessionContextPropagation = false
+	otelConn = newConn(mc, cfg)
+	err = otelConn.ResetSession(newTestSpanContext(t))
+	require.NoError(t, err)
+	assert.Equal(t, 0, mc.execContextCount)
+}
+
+func TestWithMySQLConnectionAttributes(t *testing.T) {
+	ctx := newTestSpanContext(t)
+	traceparent := "traceparent:00-a3d3b88cf7994e554c1afbdceec1620b-683ec6a9a3a265fb-01"
+
+	testCases := []struct {
+		name     string
+		ctx      context.Context
+		dsn      string
+		attrs    map[string]string
+		expected string
+	}{
+		{
+			name:     "no attributes",
+			ctx:      context.Background(),
+			dsn:      "user@tcp(mysql:3306)/orders",
+			expected: "user@tcp(mysql:3306)/orders",
+		},
+		{
+			name:     "without params",
+			ctx:      ctx,
+			dsn:      "user@tcp(mysql:3306)/orders",
+			attrs:    map[string]string{"service": "orders,api", "env": "prod"},
+			expected: "user@tcp(mysql:3306)/orders?connectionAttributes=env:prod,service:ordersapi," + traceparent,
+		},
+		{
+			name:     "with params",
+			ctx:      ctx,
+			dsn:      "user@tcp(mysql:3306)/orders?parseTime=true",
+			expected: "user@tcp(mysql:3306)/orders?parseTime=true&connectionAttributes=" + traceparent,
+		},
+		{
+			name:     "with existing connection attributes",
+			ctx:      ctx,
+			dsn:      "user@tcp(mysql:3306)/orders?connectionAttributes=app:foo&parseTime=true",
+			expected: "user@tcp(mysql:3306)/orders?connectionAttributes=app:foo," + traceparent + "&parseTime=true",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, withMySQLConnectionAttributes(tc.ctx, tc.dsn, tc.attrs))
+		})
+	}
+}
+
+func TestOtConnector_ConnectWithMySQLConnectionAttributes(t *testing.T) {
+	md := newMockDriver(false)
+	cfg := newMockConfig(t, noop.NewTracerProvider().Tracer("test"))
+	cfg.MySQLConnectionAttributes = map[string]string{"service": "orders"}
+
+	connector, err := newOtDriver(md, cfg).OpenConnector("user@tcp(mysql:3306)/orders")
+	require.NoError(t, err)
+	assert.Equal(t, 1, md.openConnectorCount)
+
+	_, err = connector.Connect(newTestSpanContext(t))
+	require.NoError(t, err)
+	assert.Equal(t, 2, md.openConnectorCount)
+	assert.Equal(t,
+		"user@tcp(mysql:3306)/orders?connectionAttributes=service:orders,traceparent:00-a3d3b88cf7994e554c1afbdceec1620b-683ec6a9a3a265fb-01",
+		md.openConnectorName,
+	)
+}
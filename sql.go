@@ -19,9 +19,11 @@ import (
 	"database/sql"
 	"database/sql/driver"
 	"errors"
+	"slices"
 	"strconv"
 	"sync"
 
+	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/metric"
 )
 
@@ -34,6 +36,11 @@ var maxDriverSlot = 1000
 // It is possible to register multiple wrappers for the same database driver if
 // needing different Option for different connections.
 func Register(driverName string, options ...Option) (string, error) {
+	cfg := newConfig(options...)
+	if err := cfg.validate(); err != nil {
+		return "", err
+	}
+
 	// Retrieve the driver implementation we need to wrap with instrumentation
 	db, err := sql.Open(driverName, "")
 	if err != nil {
@@ -62,7 +69,14 @@ func Register(driverName string, options ...Option) (string, error) {
 			}
 		}
 		if !found {
-			sql.Register(regName, newDriver(dri, newConfig(options...)))
+			if noopBuild || cfg.disabled {
+				sql.Register(regName, dri)
+			} else {
+				// Registered drivers cannot be unregistered, so the oldest
+				// call metric is observed as long as the process runs.
+				registerOldestCallMetric(&cfg)
+				sql.Register(regName, newDriver(dri, cfg))
+			}
 			return regName, nil
 		}
 	}
@@ -71,11 +85,29 @@ func Register(driverName string, options ...Option) (string, error) {
 
 // WrapDriver takes a SQL driver and wraps it with OTel instrumentation.
 func WrapDriver(dri driver.Driver, options ...Option) driver.Driver {
-	return newDriver(dri, newConfig(options...))
+	cfg := newConfig(options...)
+	if noopBuild || cfg.disabled {
+		return dri
+	}
+	if err := cfg.validate(); err != nil {
+		otel.Handle(err)
+	}
+	// The driver has no Close method, so the oldest call metric is observed
+	// as long as the process runs.
+	registerOldestCallMetric(&cfg)
+	return newDriver(dri, cfg)
 }
 
 // Open is a wrapper over sql.Open with OTel instrumentation.
 func Open(driverName, dataSourceName string, options ...Option) (*sql.DB, error) {
+	cfg := newConfig(options...)
+	if noopBuild || cfg.disabled {
+		return sql.Open(driverName, dataSourceName)
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
 	// Retrieve the driver implementation we need to wrap with instrumentation.
 	// The dataSourceName is used to bypass the driver's Open method, as some
 	// drivers validate the data source name first before actually opening
@@ -93,78 +125,262 @@ func Open(driverName, dataSourceName string, options ...Option) (*sql.DB, error)
 		return nil, err
 	}
 
-	otDriver := newOtDriver(d, newConfig(options...))
+	// The oldest call metric is unregistered when the DB closes its
+	// connector.
+	registration := registerOldestCallMetric(&cfg)
+	otDriver := newOtDriver(d, cfg)
 
 	if _, ok := d.(driver.DriverContext); ok {
 		connector, err := otDriver.OpenConnector(dataSourceName)
 		if err != nil {
+			unregister(registration)
 			return nil, err
 		}
+		connector.(*otConnector).registration = registration
 		return sql.OpenDB(connector), nil
 	}
 
-	return sql.OpenDB(dsnConnector{dsn: dataSourceName, driver: otDriver}), nil
+	return sql.OpenDB(dsnConnector{dsn: dataSourceName, driver: otDriver, registration: registration}), nil
 }
 
 // OpenDB is a wrapper over sql.OpenDB with OTel instrumentation.
 func OpenDB(c driver.Connector, options ...Option) *sql.DB {
-	d := newOtDriver(c.Driver(), newConfig(options...))
+	cfg := newConfig(options...)
+	if noopBuild || cfg.disabled {
+		return sql.OpenDB(c)
+	}
+	if err := cfg.validate(); err != nil {
+		otel.Handle(err)
+	}
+
+	registration := registerOldestCallMetric(&cfg)
+	d := newOtDriver(c.Driver(), cfg)
 	connector := newConnector(c, d)
+	connector.registration = registration
 
 	return sql.OpenDB(connector)
 }
 
 // RegisterDBStatsMetrics register sql.DBStats metrics with OTel instrumentation.
+//
+// The metrics stop being observed once db is closed, and the callback is then
+// unregistered from the meter.
 func RegisterDBStatsMetrics(db *sql.DB, opts ...Option) error {
+	_, err := RegisterDBStatsMetricsWithShutdown(db, opts...)
+	return err
+}
+
+// RegisterDBStatsMetricsWithShutdown is like RegisterDBStatsMetrics, but
+// returns the registration of the metrics, whose Shutdown method stops
+// observing them, e.g. before shutting the meter provider down.
+func RegisterDBStatsMetricsWithShutdown(db *sql.DB, opts ...Option) (*DBStatsRegistration, error) {
+	if noopBuild {
+		return &DBStatsRegistration{}, nil
+	}
 	cfg := newConfig(opts...)
 	meter := cfg.Meter
 
-	instruments, err := newDBStatsInstruments(meter)
+	instruments, err := newDBStatsInstruments(cfg)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	_, err = meter.RegisterCallback(func(_ context.Context, observer metric.Observer) error {
+	r := &DBStatsRegistration{}
+	registration, err := meter.RegisterCallback(func(_ context.Context, observer metric.Observer) error {
+		if r.isStopped() {
+			return nil
+		}
+		if dbClosed(db) {
+			// Unregistering blocks until the collection calling this
+			// callback is done.
+			if registration := r.stop(); registration != nil {
+				go func() {
+					if err := registration.Unregister(); err != nil {
+						otel.Handle(err)
+					}
+				}()
+			}
+			return nil
+		}
 		dbStats := db.Stats()
 
 		recordDBStatsMetrics(dbStats, instruments, cfg, observer)
 		return nil
-	}, instruments.connectionMaxOpen,
-		instruments.connectionOpen,
-		instruments.connectionWaitTotal,
-		instruments.connectionWaitDurationTotal,
-		instruments.connectionClosedMaxIdleTotal,
-		instruments.connectionClosedMaxIdleTimeTotal,
-		instruments.connectionClosedMaxLifetimeTotal)
+	}, instruments.observables()...)
+	if err != nil {
+		return nil, err
+	}
+	if err = r.set(registration); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// RegisterMultiDBStatsMetrics registers the sql.DBStats metrics of several
+// DBs, keyed by their pool name, with a single callback. The measurements of
+// each DB have the db.client.connection.pool.name attribute set to its key.
+//
+// Closed DBs are no longer observed.
+func RegisterMultiDBStatsMetrics(dbs map[string]*sql.DB, opts ...Option) error {
+	if noopBuild {
+		return nil
+	}
+	cfg := newConfig(opts...)
+	meter := cfg.Meter
+
+	instruments, err := newDBStatsInstruments(cfg)
 	if err != nil {
 		return err
 	}
+
+	pools := make([]dbStatsPool, 0, len(dbs))
+	for name, db := range dbs {
+		poolCfg := cfg
+		poolCfg.Attributes = append(slices.Clip(cfg.Attributes), poolNameKey.String(name))
+		pools = append(pools, dbStatsPool{db: db, cfg: poolCfg})
+	}
+
+	_, err = meter.RegisterCallback(func(_ context.Context, observer metric.Observer) error {
+		for _, pool := range pools {
+			if dbClosed(pool.db) {
+				continue
+			}
+			recordDBStatsMetrics(pool.db.Stats(), instruments, pool.cfg, observer)
+		}
+		return nil
+	}, instruments.observables()...)
+	return err
+}
+
+// dbStatsPool is a DB observed by RegisterMultiDBStatsMetrics, with the
+// config of its measurements.
+type dbStatsPool struct {
+	db  *sql.DB
+	cfg config
+}
+
+// DBStatsRegistration is the registration of the sql.DBStats metrics of a DB,
+// see RegisterDBStatsMetricsWithShutdown.
+type DBStatsRegistration struct {
+	mu           sync.Mutex
+	registration metric.Registration
+	stopped      bool
+}
+
+// set sets the registration of the callback, which is unregistered right
+// away if the callback was stopped before RegisterCallback returned.
+func (r *DBStatsRegistration) set(registration metric.Registration) error {
+	r.mu.Lock()
+	stopped := r.stopped
+	r.registration = registration
+	r.mu.Unlock()
+
+	if stopped {
+		return registration.Unregister()
+	}
 	return nil
 }
 
+// stop stops the callback and returns its registration to unregister, which
+// is nil if it was already stopped or is not set yet.
+func (r *DBStatsRegistration) stop() metric.Registration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.stopped {
+		return nil
+	}
+	r.stopped = true
+	return r.registration
+}
+
+// Shutdown stops observing the metrics and unregisters their callback from
+// the meter. The metrics are no longer observed once it returns, even if
+// unregistering the callback is interrupted by ctx. It is a no-op if the
+// metrics are already stopped, e.g. because the DB is closed.
+func (r *DBStatsRegistration) Shutdown(ctx context.Context) error {
+	registration := r.stop()
+	if registration == nil {
+		return nil
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- registration.Unregister()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *DBStatsRegistration) isStopped() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.stopped
+}
+
+// dbClosed reports whether db is closed. It asks db for a connection with a
+// canceled context, which fails without connecting, with an error depending
+// on whether db is closed.
+func dbClosed(db *sql.DB) bool {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	conn, err := db.Conn(ctx)
+	if err == nil {
+		_ = conn.Close()
+		return false
+	}
+	return !errors.Is(err, context.Canceled)
+}
+
 func recordDBStatsMetrics(
 	dbStats sql.DBStats, instruments *dbStatsInstruments, cfg config, observer metric.Observer,
 ) {
-	observer.ObserveInt64(instruments.connectionMaxOpen,
-		int64(dbStats.MaxOpenConnections),
-		metric.WithAttributes(cfg.Attributes...),
-	)
+	if instruments.connectionMaxOpen != nil {
+		observer.ObserveInt64(instruments.connectionMaxOpen,
+			int64(dbStats.MaxOpenConnections),
+			metric.WithAttributes(cfg.Attributes...),
+		)
 
-	observer.ObserveInt64(instruments.connectionOpen,
-		int64(dbStats.InUse),
-		metric.WithAttributes(append(cfg.Attributes, connectionStatusKey.String("inuse"))...),
-	)
-	observer.ObserveInt64(instruments.connectionOpen,
-		int64(dbStats.Idle),
-		metric.WithAttributes(append(cfg.Attributes, connectionStatusKey.String("idle"))...),
-	)
+		observer.ObserveInt64(instruments.connectionOpen,
+			int64(dbStats.InUse),
+			metric.WithAttributes(append(cfg.Attributes, connectionStatusKey.String("inuse"))...),
+		)
+		observer.ObserveInt64(instruments.connectionOpen,
+			int64(dbStats.Idle),
+			metric.WithAttributes(append(cfg.Attributes, connectionStatusKey.String("idle"))...),
+		)
+	}
+	if instruments.connectionCount != nil {
+		observer.ObserveInt64(instruments.connectionMax,
+			int64(dbStats.MaxOpenConnections),
+			metric.WithAttributes(cfg.Attributes...),
+		)
+
+		observer.ObserveInt64(instruments.connectionCount,
+			int64(dbStats.InUse),
+			metric.WithAttributes(append(cfg.Attributes, connectionStateKey.String("used"))...),
+		)
+		observer.ObserveInt64(instruments.connectionCount,
+			int64(dbStats.Idle),
+			metric.WithAttributes(append(cfg.Attributes, connectionStateKey.String("idle"))...),
+		)
+	}
 
 	observer.ObserveInt64(instruments.connectionWaitTotal,
 		dbStats.WaitCount,
 		metric.WithAttributes(cfg.Attributes...),
 	)
+	waitDuration := float64(dbStats.WaitDuration.Nanoseconds()) / 1e6
+	if cfg.DurationUnit == DurationUnitSeconds {
+		waitDuration = dbStats.WaitDuration.Seconds()
+	}
 	observer.ObserveFloat64(instruments.connectionWaitDurationTotal,
-		float64(dbStats.WaitDuration.Nanoseconds())/1e6,
+		waitDuration,
 		metric.WithAttributes(cfg.Attributes...),
 	)
 	observer.ObserveInt64(instruments.connectionClosedMaxIdleTotal,
@@ -179,4 +395,27 @@ func recordDBStatsMetrics(
 		dbStats.MaxLifetimeClosed,
 		metric.WithAttributes(cfg.Attributes...),
 	)
+
+	if limits := cfg.PoolLimits; limits != nil {
+		if instruments.connectionMaxIdle != nil {
+			observer.ObserveInt64(instruments.connectionMaxIdle,
+				int64(limits.maxIdleConns()),
+				metric.WithAttributes(cfg.Attributes...),
+			)
+		}
+		if instruments.connectionIdleMax != nil {
+			observer.ObserveInt64(instruments.connectionIdleMax,
+				int64(limits.maxIdleConns()),
+				metric.WithAttributes(cfg.Attributes...),
+			)
+		}
+		observer.ObserveFloat64(instruments.connectionMaxLifetime,
+			max(limits.ConnMaxLifetime, 0).Seconds(),
+			metric.WithAttributes(cfg.Attributes...),
+		)
+		observer.ObserveFloat64(instruments.connectionMaxIdleTime,
+			max(limits.ConnMaxIdleTime, 0).Seconds(),
+			metric.WithAttributes(cfg.Attributes...),
+		)
+	}
 }
@@ -12,6 +12,8 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
+//go:build !otelsql_noop
+
 package otelsql
 
 import (
@@ -20,12 +22,16 @@ import (
 	"database/sql/driver"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.opentelemetry.io/otel/attribute"
 	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
 	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	semconv "go.opentelemetry.io/otel/semconv/v1.18.0"
+
+	"github.com/XSAM/otelsql/semconvutil"
 )
 
 var driverName string
@@ -122,6 +128,15 @@ func TestOpen(t *testing.T) {
 	}
 }
 
+func TestOpenWithStrictMode(t *testing.T) {
+	_, err := Open(testDriverName, "", WithStrictMode(true))
+	assert.ErrorIs(t, err, errMissingDBSystem)
+
+	db, err := Open(testDriverName, "", WithStrictMode(true), WithAttributes(semconv.DBSystemMySQL))
+	require.NoError(t, err)
+	assert.NoError(t, db.Close())
+}
+
 func TestOpenDB(t *testing.T) {
 	connector, err := newMockDriver(false).OpenConnector("")
 	require.NoError(t, err)
@@ -140,6 +155,23 @@ func TestOpenDB(t *testing.T) {
 	}, otelDriver.cfg.Attributes)
 }
 
+func TestDisabledByEnv(t *testing.T) {
+	t.Setenv(disabledEnvKey, "true")
+
+	assert.IsType(t, &mockDriver{}, WrapDriver(newMockDriver(false)))
+
+	db, err := Open(testDriverWithoutContextName, "", WithStrictMode(true))
+	require.NoError(t, err)
+	assert.IsType(t, struct{ driver.Driver }{}, db.Driver())
+	assert.NoError(t, db.Close())
+
+	connector, err := newMockDriver(false).OpenConnector("")
+	require.NoError(t, err)
+	db = OpenDB(connector)
+	assert.IsType(t, &mockDriver{}, db.Driver())
+	assert.NoError(t, db.Close())
+}
+
 func TestRegisterDBStatsMetrics(t *testing.T) {
 	db, err := sql.Open(driverName, "")
 	require.NoError(t, err)
@@ -157,3 +189,283 @@ func TestRegisterDBStatsMetrics(t *testing.T) {
 	assert.Len(t, got.ScopeMetrics, 1)
 	assert.Len(t, got.ScopeMetrics[0].Metrics, 7)
 }
+
+func TestRegisterDBStatsMetricsWithPrometheusNaming(t *testing.T) {
+	db, err := sql.Open(driverName, "")
+	require.NoError(t, err)
+	defer db.Close()
+
+	r := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(r))
+
+	err = RegisterDBStatsMetrics(db, WithMeterProvider(mp), WithPrometheusNaming(true))
+	require.NoError(t, err)
+
+	got := &metricdata.ResourceMetrics{}
+	require.NoError(t, r.Collect(context.Background(), got))
+	require.Len(t, got.ScopeMetrics, 1)
+
+	var names []string
+	for _, m := range got.ScopeMetrics[0].Metrics {
+		names = append(names, m.Name)
+	}
+	assert.ElementsMatch(t, []string{
+		"db_sql_connection_max_open",
+		"db_sql_connection_open",
+		"db_sql_connection_wait_total",
+		"db_sql_connection_wait_duration_total",
+		"db_sql_connection_closed_max_idle_total",
+		"db_sql_connection_closed_max_idle_time_total",
+		"db_sql_connection_closed_max_lifetime_total",
+	}, names)
+}
+
+func TestRegisterDBStatsMetricsWithSemconvStability(t *testing.T) {
+	testCases := []struct {
+		optIn    string
+		expected []string
+	}{
+		{
+			optIn:    "database",
+			expected: []string{"db.client.connection.count", "db.client.connection.max"},
+		},
+		{
+			optIn: "database/dup",
+			expected: []string{
+				"db.client.connection.count", "db.client.connection.max",
+				"db.sql.connection.max_open", "db.sql.connection.open",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.optIn, func(t *testing.T) {
+			t.Setenv(semconvutil.OptInEnvKey, tc.optIn)
+
+			db, err := sql.Open(driverName, "")
+			require.NoError(t, err)
+			defer db.Close()
+
+			r := sdkmetric.NewManualReader()
+			mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(r))
+			require.NoError(t, RegisterDBStatsMetrics(db, WithMeterProvider(mp), WithPoolName("orders")))
+
+			got := &metricdata.ResourceMetrics{}
+			require.NoError(t, r.Collect(context.Background(), got))
+			require.Len(t, got.ScopeMetrics, 1)
+
+			var names []string
+			for _, m := range got.ScopeMetrics[0].Metrics {
+				switch m.Name {
+				case "db.client.connection.count":
+					sum, ok := m.Data.(metricdata.Sum[int64])
+					require.True(t, ok)
+					assert.False(t, sum.IsMonotonic)
+					require.Len(t, sum.DataPoints, 2)
+					for _, dp := range sum.DataPoints {
+						assert.True(t, dp.Attributes.HasValue(connectionStateKey))
+						pool, _ := dp.Attributes.Value(poolNameKey)
+						assert.Equal(t, "orders", pool.AsString())
+					}
+				case "db.sql.connection.wait", "db.sql.connection.wait_duration", "db.sql.connection.closed_max_idle",
+					"db.sql.connection.closed_max_idle_time", "db.sql.connection.closed_max_lifetime":
+					continue
+				}
+				names = append(names, m.Name)
+			}
+			assert.ElementsMatch(t, tc.expected, names)
+		})
+	}
+}
+
+func TestRegisterDBStatsMetricsWithPoolLimits(t *testing.T) {
+	db, err := sql.Open(driverName, "")
+	require.NoError(t, err)
+	defer db.Close()
+
+	limits := PoolLimits{
+		MaxOpenConns:    10,
+		MaxIdleConns:    5,
+		ConnMaxLifetime: time.Hour,
+		ConnMaxIdleTime: 90 * time.Second,
+	}
+	limits.Apply(db)
+	assert.Equal(t, 10, db.Stats().MaxOpenConnections)
+
+	r := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(r))
+	require.NoError(t, RegisterDBStatsMetrics(db, WithMeterProvider(mp), WithPoolLimits(limits)))
+
+	got := &metricdata.ResourceMetrics{}
+	require.NoError(t, r.Collect(context.Background(), got))
+	require.Len(t, got.ScopeMetrics, 1)
+	assert.Len(t, got.ScopeMetrics[0].Metrics, 10)
+
+	for _, m := range got.ScopeMetrics[0].Metrics {
+		switch m.Name {
+		case "db.sql.connection.max_idle":
+			gauge, ok := m.Data.(metricdata.Gauge[int64])
+			require.True(t, ok)
+			require.Len(t, gauge.DataPoints, 1)
+			assert.Equal(t, int64(5), gauge.DataPoints[0].Value)
+		case "db.sql.connection.max_lifetime":
+			gauge, ok := m.Data.(metricdata.Gauge[float64])
+			require.True(t, ok)
+			require.Len(t, gauge.DataPoints, 1)
+			assert.Equal(t, 3600.0, gauge.DataPoints[0].Value)
+			assert.Equal(t, "s", m.Unit)
+		case "db.sql.connection.max_idle_time":
+			gauge, ok := m.Data.(metricdata.Gauge[float64])
+			require.True(t, ok)
+			require.Len(t, gauge.DataPoints, 1)
+			assert.Equal(t, 90.0, gauge.DataPoints[0].Value)
+		}
+	}
+}
+
+func TestPoolLimitsMaxIdleConns(t *testing.T) {
+	for _, tc := range []struct {
+		limits   PoolLimits
+		expected int
+	}{
+		{limits: PoolLimits{}, expected: 2},
+		{limits: PoolLimits{MaxIdleConns: -1}, expected: 0},
+		{limits: PoolLimits{MaxIdleConns: 5}, expected: 5},
+		{limits: PoolLimits{MaxIdleConns: 5, MaxOpenConns: 3}, expected: 3},
+		{limits: PoolLimits{MaxOpenConns: 1}, expected: 1},
+	} {
+		assert.Equal(t, tc.expected, tc.limits.maxIdleConns(), "%+v", tc.limits)
+	}
+}
+
+func TestRegisterDBStatsMetricsWithPoolLimitsStable(t *testing.T) {
+	t.Setenv(semconvutil.OptInEnvKey, "database")
+	db, err := sql.Open(driverName, "")
+	require.NoError(t, err)
+	defer db.Close()
+
+	r := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(r))
+	require.NoError(t, RegisterDBStatsMetrics(db, WithMeterProvider(mp),
+		WithPoolLimits(PoolLimits{MaxOpenConns: 1})))
+
+	got := &metricdata.ResourceMetrics{}
+	require.NoError(t, r.Collect(context.Background(), got))
+	require.Len(t, got.ScopeMetrics, 1)
+
+	var found bool
+	for _, m := range got.ScopeMetrics[0].Metrics {
+		assert.NotEqual(t, "db.sql.connection.max_idle", m.Name)
+		if m.Name == "db.client.connection.idle.max" {
+			found = true
+			sum, ok := m.Data.(metricdata.Sum[int64])
+			require.True(t, ok)
+			require.Len(t, sum.DataPoints, 1)
+			assert.Equal(t, int64(1), sum.DataPoints[0].Value)
+		}
+	}
+	assert.True(t, found)
+}
+
+func TestRegisterDBStatsMetricsClosedDB(t *testing.T) {
+	db, err := sql.Open(driverName, "")
+	require.NoError(t, err)
+
+	r := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(r))
+
+	err = RegisterDBStatsMetrics(db, WithMeterProvider(mp))
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	// A closed DB is no longer observed.
+	for i := 0; i < 2; i++ {
+		got := &metricdata.ResourceMetrics{}
+		require.NoError(t, r.Collect(context.Background(), got))
+		for _, sm := range got.ScopeMetrics {
+			for _, m := range sm.Metrics {
+				switch data := m.Data.(type) {
+				case metricdata.Sum[int64]:
+					assert.Empty(t, data.DataPoints, m.Name)
+				case metricdata.Sum[float64]:
+					assert.Empty(t, data.DataPoints, m.Name)
+				case metricdata.Gauge[int64]:
+					assert.Empty(t, data.DataPoints, m.Name)
+				}
+			}
+		}
+	}
+}
+
+func TestRegisterDBStatsMetricsWithShutdown(t *testing.T) {
+	db, err := sql.Open(driverName, "")
+	require.NoError(t, err)
+	defer db.Close()
+
+	r := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(r))
+
+	registration, err := RegisterDBStatsMetricsWithShutdown(db, WithMeterProvider(mp))
+	require.NoError(t, err)
+
+	got := &metricdata.ResourceMetrics{}
+	require.NoError(t, r.Collect(context.Background(), got))
+	require.Len(t, got.ScopeMetrics, 1)
+	assert.Len(t, got.ScopeMetrics[0].Metrics, 7)
+
+	require.NoError(t, registration.Shutdown(context.Background()))
+	// Shutting down twice is a no-op.
+	require.NoError(t, registration.Shutdown(context.Background()))
+
+	got = &metricdata.ResourceMetrics{}
+	require.NoError(t, r.Collect(context.Background(), got))
+	assert.Empty(t, got.ScopeMetrics)
+}
+
+func TestRegisterMultiDBStatsMetrics(t *testing.T) {
+	orders, err := sql.Open(driverName, "")
+	require.NoError(t, err)
+	defer orders.Close()
+	users, err := sql.Open(driverName, "")
+	require.NoError(t, err)
+	defer users.Close()
+	closed, err := sql.Open(driverName, "")
+	require.NoError(t, err)
+	require.NoError(t, closed.Close())
+
+	r := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(r))
+
+	err = RegisterMultiDBStatsMetrics(map[string]*sql.DB{
+		"orders": orders,
+		"users":  users,
+		"closed": closed,
+	}, WithMeterProvider(mp))
+	require.NoError(t, err)
+
+	got := &metricdata.ResourceMetrics{}
+	require.NoError(t, r.Collect(context.Background(), got))
+	require.Len(t, got.ScopeMetrics, 1)
+	require.Len(t, got.ScopeMetrics[0].Metrics, 7)
+
+	var pools []string
+	for _, m := range got.ScopeMetrics[0].Metrics {
+		if m.Name != "db.sql.connection.max_open" {
+			continue
+		}
+		for _, dp := range m.Data.(metricdata.Gauge[int64]).DataPoints {
+			pool, _ := dp.Attributes.Value(poolNameKey)
+			pools = append(pools, pool.AsString())
+		}
+	}
+	assert.ElementsMatch(t, []string{"orders", "users"}, pools)
+}
+
+func TestDBClosed(t *testing.T) {
+	db, err := sql.Open(driverName, "")
+	require.NoError(t, err)
+
+	assert.False(t, dbClosed(db))
+	require.NoError(t, db.Close())
+	assert.True(t, dbClosed(db))
+}
@@ -18,9 +18,12 @@ import (
 	"context"
 	"database/sql/driver"
 
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
 )
 
+var dbPreparedStatementIDKey = attribute.Key("db.prepared_statement.id")
+
 var (
 	_ driver.Stmt              = (*otStmt)(nil)
 	_ driver.StmtExecContext   = (*otStmt)(nil)
@@ -32,36 +35,65 @@ type otStmt struct {
 	driver.Stmt
 	cfg config
 
-	query  string
-	otConn *otConn
+	query string
+	// preparedQuery is the query as sent to the driver, see QueryRecorder.
+	preparedQuery string
+	otConn        *otConn
+	// id is the ID of the statement, see SpanOptions.RecordStatementID.
+	id string
+
+	// prepareFallback reports whether database/sql prepared the statement
+	// because the connection returned driver.ErrSkip.
+	prepareFallback bool
 }
 
 func newStmt(stmt driver.Stmt, cfg config, query string, otConn *otConn) *otStmt {
 	return &otStmt{
-		Stmt:   stmt,
-		cfg:    cfg,
-		query:  query,
-		otConn: otConn,
+		Stmt:          stmt,
+		cfg:           cfg,
+		query:         query,
+		preparedQuery: query,
+		otConn:        otConn,
+	}
+}
+
+// setIDAttribute sets the ID of the statement on span, if any.
+func (s *otStmt) setIDAttribute(span trace.Span) {
+	if s.id != "" && span != nil {
+		span.SetAttributes(dbPreparedStatementIDKey.String(s.id))
 	}
 }
 
 func (s *otStmt) ExecContext(
 	ctx context.Context, args []driver.NamedValue,
 ) (result driver.Result, err error) {
-	method := MethodStmtExec
-	onDefer := recordMetric(ctx, s.cfg.Instruments, s.cfg, method, s.query, args)
+	ctx = contextWithTxID(ctx, s.otConn.txID())
+	ctx = contextWithNamespace(ctx, s.otConn.currentNamespace())
+	defer s.otConn.recordTxStatementDeferred(&result, &err)
+	defer s.otConn.trackNamespaceDeferred(s.query, &err)
+	method := execMethod(s.cfg, MethodStmtExec, s.query)
+	telemetryArgs := redactArgs(s.cfg, args)
+	onDefer := recordMetric(ctx, s.cfg.Instruments, s.cfg, method, s.query, telemetryArgs)
 	defer func() {
 		onDefer(err)
+		err = wrapError(ctx, s.cfg, err)
 	}()
+	defer setPprofLabels(ctx, pprofLabels(s.cfg, method, s.query))()
+
+	s.otConn.propagateApplicationName(ctx)
 
 	var span trace.Span
-	if filterSpan(ctx, s.cfg.SpanOptions, method, s.query, args) {
-		ctx, span = createSpan(ctx, s.cfg, method, true, s.query, args)
+	if !s.cfg.SpanOptions.OmitStmtExec && filterSpan(ctx, s.cfg.SpanOptions, method, s.query, telemetryArgs) {
+		ctx, span = createSpan(ctx, s.cfg, method, true, s.query, telemetryArgs)
+		s.setPrepareFallbackAttribute(span)
+		s.setIDAttribute(span)
 
 		defer span.End()
-		defer recordSpanErrorDeferred(span, s.cfg.SpanOptions, &err)
+		defer recordSpanErrorDeferred(ctx, span, s.cfg, method, s.query, &err)
 	}
+	defer recordAuditLogDeferred(ctx, s.cfg, method, s.query, telemetryArgs, &result, &err)
 
+	s.cfg.QueryRecorder.record(method, s.preparedQuery, telemetryArgs)
 	if execer, ok := s.Stmt.(driver.StmtExecContext); ok {
 		return execer.ExecContext(ctx, args)
 	}
@@ -84,22 +116,38 @@ func (s *otStmt) ExecContext(
 func (s *otStmt) QueryContext(
 	ctx context.Context, args []driver.NamedValue,
 ) (rows driver.Rows, err error) {
+	ctx = contextWithTxID(ctx, s.otConn.txID())
+	ctx = contextWithNamespace(ctx, s.otConn.currentNamespace())
+	defer s.otConn.recordTxStatementDeferred(nil, &err)
 	method := MethodStmtQuery
-	onDefer := recordMetric(ctx, s.cfg.Instruments, s.cfg, method, s.query, args)
+	telemetryArgs := redactArgs(s.cfg, args)
+	onDefer := recordMetric(ctx, s.cfg.Instruments, s.cfg, method, s.query, telemetryArgs)
 	defer func() {
 		onDefer(err)
+		err = wrapError(ctx, s.cfg, err)
 	}()
+	defer setPprofLabels(ctx, pprofLabels(s.cfg, method, s.query))()
+
+	s.otConn.propagateApplicationName(ctx)
 
 	var span trace.Span
 	var queryCtx context.Context
-	if filterSpan(ctx, s.cfg.SpanOptions, method, s.query, args) {
-		queryCtx, span = createSpan(ctx, s.cfg, method, true, s.query, args)
-		defer span.End()
-		defer recordSpanErrorDeferred(span, s.cfg.SpanOptions, &err)
+	if !s.cfg.SpanOptions.OmitStmtQuery && filterSpan(ctx, s.cfg.SpanOptions, method, s.query, telemetryArgs) {
+		queryCtx, span = createSpan(ctx, s.cfg, method, true, s.query, telemetryArgs)
+		s.setPrepareFallbackAttribute(span)
+		s.setIDAttribute(span)
+		if s.cfg.SpanOptions.MergeRowsIntoQuerySpan && !s.cfg.UnwrappedRows {
+			defer endSpanOnErrorDeferred(span, &err)
+		} else {
+			defer span.End()
+		}
+		defer recordSpanErrorDeferred(ctx, span, s.cfg, method, s.query, &err)
 	} else {
 		queryCtx = ctx
 	}
+	defer recordAuditLogDeferred(queryCtx, s.cfg, method, s.query, telemetryArgs, nil, &err)
 
+	s.cfg.QueryRecorder.record(method, s.preparedQuery, telemetryArgs)
 	if query, ok := s.Stmt.(driver.StmtQueryContext); ok {
 		if rows, err = query.QueryContext(queryCtx, args); err != nil {
 			return nil, err
@@ -122,7 +170,12 @@ func (s *otStmt) QueryContext(
 		}
 	}
 
-	return newRows(ctx, rows, s.cfg), nil
+	if s.cfg.UnwrappedRows {
+		return rows, nil
+	}
+	otelRows := newRowsWithSpan(ctx, rows, s.cfg, span)
+	otelRows.pprofLabels = pprofLabels(s.cfg, MethodRows, s.query)
+	return wrapRows(otelRows), nil
 }
 
 func (s *otStmt) CheckNamedValue(namedValue *driver.NamedValue) error {
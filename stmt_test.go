@@ -18,6 +18,8 @@ import (
 	"context"
 	"database/sql/driver"
 	"errors"
+	"fmt"
+	"slices"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -44,6 +46,7 @@ type mockStmt struct {
 	shouldError bool
 	queryCount  int
 	execCount   int
+	closeCount  int
 
 	queryContextArgs []driver.NamedValue
 	execContextArgs  []driver.NamedValue
@@ -77,6 +80,11 @@ func newMockStmt(shouldError bool) *mockStmt {
 	return &mockStmt{shouldError: shouldError}
 }
 
+func (m *mockStmt) Close() error {
+	m.closeCount++
+	return nil
+}
+
 func (m *mockStmt) CheckNamedValue(_ *driver.NamedValue) error {
 	if m.shouldError {
 		return errors.New("checkNamedValue")
@@ -90,7 +98,7 @@ func (m *mockStmt) QueryContext(_ context.Context, args []driver.NamedValue) (dr
 	if m.shouldError {
 		return nil, errors.New("queryContext")
 	}
-	return nil, nil
+	return newMockRows(false), nil
 }
 
 func (m *mockStmt) ExecContext(_ context.Context, args []driver.NamedValue) (driver.Result, error) {
@@ -343,6 +351,34 @@ func (nvc *namedValueChecker) CheckNamedValue(_ *driver.NamedValue) error {
 	return nvc.err
 }
 
+func TestOtStmt_OmitSpans(t *testing.T) {
+	for _, omitStmtExec := range []bool{true, false} {
+		for _, omitStmtQuery := range []bool{true, false} {
+			t.Run(fmt.Sprintf("OmitStmtExec=%t OmitStmtQuery=%t", omitStmtExec, omitStmtQuery), func(t *testing.T) {
+				ctx, sr, tracer, _ := prepareTraces(true)
+				cfg := newMockConfig(t, tracer)
+				cfg.SpanOptions.OmitStmtExec = omitStmtExec
+				cfg.SpanOptions.OmitStmtQuery = omitStmtQuery
+				cfg.SpanOptions.OmitRows = true
+				stmt := newStmt(newMockStmt(false), cfg, "query", nil)
+
+				_, err := stmt.ExecContext(ctx, nil)
+				require.NoError(t, err)
+				rows, err := stmt.QueryContext(ctx, nil)
+				require.NoError(t, err)
+				require.NoError(t, rows.Close())
+
+				var names []string
+				for _, span := range sr.Ended() {
+					names = append(names, span.Name())
+				}
+				assert.Equal(t, !omitStmtExec, slices.Contains(names, string(MethodStmtExec)))
+				assert.Equal(t, !omitStmtQuery, slices.Contains(names, string(MethodStmtQuery)))
+			})
+		}
+	}
+}
+
 func TestOtStmt_CheckNamedValue(t *testing.T) {
 	// Generate a variable that implements the driver.NamedValueChecker
 
@@ -401,3 +437,31 @@ func TestOtStmt_CheckNamedValue(t *testing.T) {
 	}
 
 }
+
+func TestOtStmt_RecordStatementID(t *testing.T) {
+	_, sr, tracer, _ := prepareTraces(true)
+	ctx := context.Background()
+	cfg := newMockConfig(t, tracer)
+	cfg.SpanOptions.RecordStatementID = true
+	otelConn := newConn(newMockConn(false), cfg)
+
+	var ids []string
+	for i := 0; i < 2; i++ {
+		stmt, err := otelConn.PrepareContext(ctx, "UPDATE t SET a = ?")
+		require.NoError(t, err)
+		for j := 0; j < 2; j++ {
+			_, err = stmt.(*otStmt).ExecContext(ctx, nil)
+			require.NoError(t, err)
+		}
+		ids = append(ids, stmt.(*otStmt).id)
+	}
+	assert.NotEmpty(t, ids[0])
+	assert.NotEqual(t, ids[0], ids[1])
+
+	// One prepare and two exec spans per statement.
+	spanList := sr.Ended()
+	require.Len(t, spanList, 6)
+	for i, span := range spanList {
+		assert.Contains(t, span.Attributes(), dbPreparedStatementIDKey.String(ids[i/3]), span.Name())
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright Sam Xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otelsql
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/metric"
+)
+
+// StmtCacheStats are the statistics of a prepared statement cache.
+type StmtCacheStats struct {
+	// Hits is the total number of statements found in the cache.
+	Hits int64
+	// Misses is the total number of statements missing from the cache, which
+	// were prepared.
+	Misses int64
+	// Evictions is the total number of statements evicted from the cache.
+	Evictions int64
+	// Size is the number of statements in the cache.
+	Size int
+}
+
+// StmtCache is implemented by the prepared statement caches of applications
+// or libraries whose effectiveness is recorded by RegisterStmtCacheMetrics.
+type StmtCache interface {
+	// StmtCacheStats returns the statistics of the cache. It must be safe
+	// for concurrent use.
+	StmtCacheStats() StmtCacheStats
+}
+
+// RegisterStmtCacheMetrics registers the statistics of cache as metrics,
+// alongside the ones of RegisterDBStatsMetrics, so that the hit ratio and the
+// evictions of the cache can be monitored. Use WithAttributes to tell caches
+// apart.
+func RegisterStmtCacheMetrics(cache StmtCache, opts ...Option) error {
+	if noopBuild {
+		return nil
+	}
+	cfg := newConfig(opts...)
+	meter := cfg.Meter
+
+	instruments, err := newStmtCacheInstruments(meter)
+	if err != nil {
+		return err
+	}
+
+	_, err = meter.RegisterCallback(func(_ context.Context, observer metric.Observer) error {
+		stats := cache.StmtCacheStats()
+		attrs := metric.WithAttributes(cfg.Attributes...)
+
+		observer.ObserveInt64(instruments.hits, stats.Hits, attrs)
+		observer.ObserveInt64(instruments.misses, stats.Misses, attrs)
+		observer.ObserveInt64(instruments.evictions, stats.Evictions, attrs)
+		observer.ObserveInt64(instruments.size, int64(stats.Size), attrs)
+		return nil
+	}, instruments.hits,
+		instruments.misses,
+		instruments.evictions,
+		instruments.size)
+	return err
+}
@@ -0,0 +1,67 @@
+// Copyright Sam Xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !otelsql_noop
+
+package otelsql
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+type mockStmtCache StmtCacheStats
+
+func (c mockStmtCache) StmtCacheStats() StmtCacheStats {
+	return StmtCacheStats(c)
+}
+
+func TestRegisterStmtCacheMetrics(t *testing.T) {
+	r := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(r))
+
+	cache := mockStmtCache{Hits: 8, Misses: 2, Evictions: 1, Size: 5}
+	err := RegisterStmtCacheMetrics(cache, WithMeterProvider(mp), WithAttributes(attribute.String("cache", "orders")))
+	require.NoError(t, err)
+
+	got := &metricdata.ResourceMetrics{}
+	require.NoError(t, r.Collect(context.Background(), got))
+	require.Len(t, got.ScopeMetrics, 1)
+
+	values := make(map[string]int64)
+	for _, m := range got.ScopeMetrics[0].Metrics {
+		var dataPoints []metricdata.DataPoint[int64]
+		switch data := m.Data.(type) {
+		case metricdata.Sum[int64]:
+			dataPoints = data.DataPoints
+		case metricdata.Gauge[int64]:
+			dataPoints = data.DataPoints
+		}
+		require.Len(t, dataPoints, 1)
+		assert.True(t, dataPoints[0].Attributes.HasValue("cache"))
+		values[m.Name] = dataPoints[0].Value
+	}
+	assert.Equal(t, map[string]int64{
+		"db.sql.stmt_cache.hits":      8,
+		"db.sql.stmt_cache.misses":    2,
+		"db.sql.stmt_cache.evictions": 1,
+		"db.sql.stmt_cache.size":      5,
+	}, values)
+}
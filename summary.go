@@ -0,0 +1,238 @@
+// Copyright Sam Xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otelsql
+
+import (
+	"strings"
+	"sync"
+	"unicode"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var (
+	dbQuerySummaryKey   = attribute.Key("db.query.summary")
+	dbCollectionNameKey = attribute.Key("db.collection.name")
+	dbOperationNameKey  = attribute.Key("db.operation.name")
+)
+
+const (
+	// maxQuerySummaryLength is the maximum length of a query summary.
+	maxQuerySummaryLength = 255
+
+	// querySummaryOverflow replaces query summaries, collection names and
+	// tenants beyond the cardinality limit.
+	querySummaryOverflow = "_OTHER"
+
+	// collectionNameMetricLimit is the maximum number of distinct collection
+	// names recorded in metrics.
+	collectionNameMetricLimit = 1000
+)
+
+// querySummaryOperations are the keywords recorded as operations in a query
+// summary.
+var querySummaryOperations = map[string]bool{
+	"SELECT": true, "INSERT": true, "UPDATE": true, "DELETE": true,
+	"MERGE": true, "UPSERT": true, "REPLACE": true, "CALL": true, "EXEC": true,
+	"CREATE": true, "ALTER": true, "DROP": true, "TRUNCATE": true,
+	"BEGIN": true, "COMMIT": true, "ROLLBACK": true, "SHOW": true, "SET": true,
+}
+
+// querySummaryTargetKeywords are the keywords followed by a target, like a
+// table name, recorded in a query summary.
+var querySummaryTargetKeywords = map[string]bool{
+	"FROM": true, "INTO": true, "JOIN": true, "UPDATE": true, "TABLE": true,
+	"CALL": true, "EXEC": true,
+}
+
+// querySummary returns a low-cardinality summary of query made of its
+// operations and their targets, e.g. "SELECT orders JOIN customers" becomes
+// "SELECT orders customers". Literals, comments, and parameters are never
+// included. It returns an empty string if no operation is found.
+func querySummary(query string) string {
+	var (
+		parts      []string
+		wantTarget bool
+	)
+	for _, token := range sqlTokens(query) {
+		upper := strings.ToUpper(token)
+		switch {
+		case wantTarget && isSQLIdentifier(token) && !querySummaryOperations[upper]:
+			parts = append(parts, token)
+			wantTarget = false
+		case querySummaryOperations[upper]:
+			// Only the first word of a statement is the operation, SET in
+			// UPDATE ... SET is not.
+			if upper != "SET" || len(parts) == 0 {
+				parts = append(parts, upper)
+			}
+			wantTarget = querySummaryTargetKeywords[upper]
+		default:
+			wantTarget = querySummaryTargetKeywords[upper]
+		}
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+
+	summary := strings.Join(parts, " ")
+	if len(summary) > maxQuerySummaryLength {
+		summary = summary[:maxQuerySummaryLength]
+	}
+	return summary
+}
+
+// collectionName returns the first table of query, e.g. "orders" for
+// "SELECT * FROM orders JOIN customers", or an empty string if none is found.
+func collectionName(query string) string {
+	var wantTarget bool
+	for _, token := range sqlTokens(query) {
+		upper := strings.ToUpper(token)
+		if wantTarget && isSQLIdentifier(token) && !querySummaryOperations[upper] {
+			return token
+		}
+		wantTarget = querySummaryTargetKeywords[upper]
+	}
+	return ""
+}
+
+// operationName returns the operation of query, its first keyword in upper
+// case like "SELECT", or an empty string if query has none.
+func operationName(query string) string {
+	return strings.ToUpper(firstSQLWord(query))
+}
+
+// sqlTokens splits query into words and identifiers, dropping comments,
+// string literals, numbers, and punctuation. Quoted identifiers are returned
+// without their quotes, so they may be empty.
+func sqlTokens(query string) []string {
+	var tokens []string
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == '-' && strings.HasPrefix(query[i:], "--"):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				return tokens
+			}
+			i += end
+		case c == '/' && strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				return tokens
+			}
+			i += end + 4
+		case c == '\'':
+			// String literal, '' is an escaped quote.
+			i++
+			for i < len(query) {
+				if query[i] == '\'' {
+					if i+1 < len(query) && query[i+1] == '\'' {
+						i += 2
+						continue
+					}
+					break
+				}
+				i++
+			}
+			i++
+		case c == '"' || c == '`' || c == '[':
+			closing := c
+			if c == '[' {
+				closing = ']'
+			}
+			end := strings.IndexByte(query[i+1:], closing)
+			if end < 0 {
+				return tokens
+			}
+			tokens = append(tokens, query[i+1:i+1+end])
+			i += end + 2
+		case isSQLWordByte(c):
+			start := i
+			for i < len(query) && (isSQLWordByte(query[i]) || query[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, query[start:i])
+		default:
+			i++
+		}
+	}
+	return tokens
+}
+
+func isSQLWordByte(c byte) bool {
+	return c == '_' || c == '$' || c == '@' || c == '#' || c >= 0x80 ||
+		unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c))
+}
+
+// isSQLIdentifier reports whether token can be a table or procedure name, as
+// opposed to a number, a parameter, or an empty quoted identifier.
+func isSQLIdentifier(token string) bool {
+	if token == "" {
+		return false
+	}
+	c := token[0]
+	return c != '$' && c != '@' && c != '?' && (c < '0' || c > '9')
+}
+
+// metricAttributeLimiter caps the number of distinct values of an attribute
+// extracted from queries and recorded in metrics.
+type metricAttributeLimiter struct {
+	key     attribute.Key
+	extract func(query string) string
+	limit   int
+
+	mu   sync.Mutex
+	seen map[string]struct{}
+}
+
+func newQuerySummaryLimiter(limit int) *metricAttributeLimiter {
+	return newMetricAttributeLimiter(dbQuerySummaryKey, querySummary, limit)
+}
+
+func newCollectionNameLimiter() *metricAttributeLimiter {
+	return newMetricAttributeLimiter(dbCollectionNameKey, collectionName, collectionNameMetricLimit)
+}
+
+func newMetricAttributeLimiter(key attribute.Key, extract func(string) string, limit int) *metricAttributeLimiter {
+	return &metricAttributeLimiter{key: key, extract: extract, limit: limit, seen: make(map[string]struct{})}
+}
+
+func newTenantLimiter(limit int) *metricAttributeLimiter {
+	return newMetricAttributeLimiter(tenantIDKey, nil, limit)
+}
+
+// attribute returns the attribute of query. Once limit distinct values have
+// been seen, new values are replaced by querySummaryOverflow.
+func (l *metricAttributeLimiter) attribute(query string) (attribute.KeyValue, bool) {
+	return l.limitedAttribute(l.extract(query))
+}
+
+// limitedAttribute returns the attribute of value, capped like attribute.
+func (l *metricAttributeLimiter) limitedAttribute(value string) (attribute.KeyValue, bool) {
+	if value == "" {
+		return attribute.KeyValue{}, false
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.seen[value]; !ok {
+		if len(l.seen) >= l.limit {
+			return l.key.String(querySummaryOverflow), true
+		}
+		l.seen[value] = struct{}{}
+	}
+	return l.key.String(value), true
+}
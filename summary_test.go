@@ -0,0 +1,136 @@
+// Copyright Sam Xie
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otelsql
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuerySummary(t *testing.T) {
+	testCases := []struct {
+		query    string
+		expected string
+	}{
+		{query: "", expected: ""},
+		{query: "SELECT 1", expected: "SELECT"},
+		{query: "select * from orders where id = $1", expected: "SELECT orders"},
+		{query: "SELECT o.id FROM public.orders o JOIN customers c ON c.id = o.customer_id", expected: "SELECT public.orders customers"},
+		{query: "INSERT INTO `orders` (id) SELECT id FROM [staging]", expected: "INSERT orders SELECT staging"},
+		{query: "UPDATE orders SET status = 'FROM secret' WHERE id = @id", expected: "UPDATE orders"},
+		{query: "DELETE FROM orders WHERE id IN (SELECT id FROM \"old orders\")", expected: "DELETE orders SELECT old orders"},
+		{query: "/* FROM users */ -- FROM users\nSELECT * FROM orders", expected: "SELECT orders"},
+		{query: "SELECT 'it''s' FROM dual", expected: "SELECT dual"},
+		{query: "CALL refresh_stats(?)", expected: "CALL refresh_stats"},
+		{query: "CREATE TABLE orders (id INT)", expected: "CREATE orders"},
+		{query: "SET search_path TO public", expected: "SET"},
+		{query: "'unterminated", expected: ""},
+		{query: `SELECT * FROM "" WHERE name = ""`, expected: "SELECT"},
+		{query: "SELECT * FROM [] JOIN orders", expected: "SELECT orders"},
+		{query: "SELECT * FROM ``", expected: "SELECT"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.query, func(t *testing.T) {
+			assert.Equal(t, tc.expected, querySummary(tc.query))
+		})
+	}
+
+	assert.Len(t, querySummary("SELECT * FROM "+strings.Repeat("x", 300)), maxQuerySummaryLength)
+}
+
+func TestCollectionName(t *testing.T) {
+	testCases := []struct {
+		query    string
+		expected string
+	}{
+		{query: "", expected: ""},
+		{query: "SELECT 1", expected: ""},
+		{query: "select * from orders where id = $1", expected: "orders"},
+		{query: "SELECT o.id FROM public.orders o JOIN customers c ON c.id = o.customer_id", expected: "public.orders"},
+		{query: "INSERT INTO `orders` (id) SELECT id FROM [staging]", expected: "orders"},
+		{query: "UPDATE orders SET status = 'FROM secret' WHERE id = @id", expected: "orders"},
+		{query: "/* FROM users */ SELECT * FROM orders", expected: "orders"},
+		{query: `SELECT * FROM "" `, expected: ""},
+		{query: "SELECT * FROM []", expected: ""},
+		{query: "SELECT * FROM `` ", expected: ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.query, func(t *testing.T) {
+			assert.Equal(t, tc.expected, collectionName(tc.query))
+		})
+	}
+}
+
+func TestQuerySummaryLimiter(t *testing.T) {
+	limiter := newQuerySummaryLimiter(2)
+
+	for _, tc := range []struct {
+		query    string
+		expected string
+	}{
+		{query: "SELECT * FROM a", expected: "SELECT a"},
+		{query: "SELECT * FROM b", expected: "SELECT b"},
+		{query: "SELECT * FROM c", expected: querySummaryOverflow},
+		{query: "SELECT id FROM a", expected: "SELECT a"},
+	} {
+		attr, ok := limiter.attribute(tc.query)
+		assert.True(t, ok)
+		assert.Equal(t, dbQuerySummaryKey.String(tc.expected), attr)
+	}
+
+	_, ok := limiter.attribute("-- comment only")
+	assert.False(t, ok)
+}
+
+func TestTenantLimiter(t *testing.T) {
+	limiter := newTenantLimiter(1)
+
+	for _, tc := range []struct {
+		tenant   string
+		expected string
+	}{
+		{tenant: "acme", expected: "acme"},
+		{tenant: "globex", expected: querySummaryOverflow},
+		{tenant: "acme", expected: "acme"},
+	} {
+		attr, ok := limiter.limitedAttribute(tc.tenant)
+		assert.True(t, ok)
+		assert.Equal(t, tenantIDKey.String(tc.expected), attr)
+	}
+
+	_, ok := limiter.limitedAttribute("")
+	assert.False(t, ok)
+}
+
+func TestCollectionNameLimiter(t *testing.T) {
+	limiter := newCollectionNameLimiter()
+	for i := 0; i < collectionNameMetricLimit; i++ {
+		attr, ok := limiter.attribute(fmt.Sprintf("SELECT * FROM t%d", i))
+		assert.True(t, ok)
+		assert.Equal(t, dbCollectionNameKey.String(fmt.Sprintf("t%d", i)), attr)
+	}
+
+	attr, ok := limiter.attribute("SELECT * FROM overflow")
+	assert.True(t, ok)
+	assert.Equal(t, dbCollectionNameKey.String(querySummaryOverflow), attr)
+
+	_, ok = limiter.attribute("SELECT 1")
+	assert.False(t, ok)
+}
@@ -17,16 +17,92 @@ package otelsql
 import (
 	"context"
 	"database/sql/driver"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
 	"go.opentelemetry.io/otel/trace"
 )
 
+var (
+	dbTransactionIDKey           = attribute.Key("db.transaction.id")
+	dbTransactionStatementsKey   = attribute.Key("db.transaction.statement_count")
+	dbTransactionRowsAffectedKey = attribute.Key("db.transaction.rows_affected")
+	dbTransactionDurationKey     = attribute.Key("db.transaction.duration")
+
+	txOutcomeKey = attribute.Key("db.sql.tx.outcome")
+)
+
+// The outcomes of transactions recorded by db.sql.transactions.
+const (
+	txOutcomeCommitted  = "committed"
+	txOutcomeRolledBack = "rolled_back"
+	txOutcomeAborted    = "aborted"
+)
+
 var _ driver.Tx = (*otTx)(nil)
 
 type otTx struct {
 	tx  driver.Tx
 	ctx context.Context
 	cfg config
+
+	// onEnd, if set, is called when the transaction is committed or rolled
+	// back, with whether it was committed.
+	onEnd func(committed bool)
+	// info is the transaction tracked by the connection, if any.
+	info *txInfo
+}
+
+// txInfo is a transaction tracked by the connection it began on, see
+// SpanOptions.RecordTransactionID and SpanOptions.RecordTransactionSummary.
+type txInfo struct {
+	id    string
+	start time.Time
+
+	statements   int
+	rowsAffected int64
+}
+
+// summaryAttributes returns the attributes summarizing the transaction when
+// it ends.
+func (i *txInfo) summaryAttributes() []attribute.KeyValue {
+	return []attribute.KeyValue{
+		dbTransactionStatementsKey.Int(i.statements),
+		dbTransactionRowsAffectedKey.Int64(i.rowsAffected),
+		dbTransactionDurationKey.Float64(time.Since(i.start).Seconds()),
+	}
+}
+
+// recordOutcomeDeferred counts the transaction in db.sql.transactions. A
+// transaction whose commit or rollback fails is counted as aborted.
+func (t *otTx) recordOutcomeDeferred(outcome string, err *error) {
+	if t.cfg.Instruments == nil || t.cfg.Instruments.transactions == nil {
+		return
+	}
+	if *err != nil {
+		outcome = txOutcomeAborted
+	}
+	attrs := append(slices.Clip(t.cfg.Attributes), txOutcomeKey.String(outcome))
+	t.cfg.Instruments.transactions.Add(t.ctx, 1, metric.WithAttributes(attrs...))
+}
+
+// spanStartOptions returns the options of sql.tx.commit and sql.tx.rollback
+// spans.
+func (t *otTx) spanStartOptions() []trace.SpanStartOption {
+	if t.info == nil || !t.cfg.SpanOptions.RecordTransactionSummary {
+		return nil
+	}
+	return []trace.SpanStartOption{trace.WithAttributes(t.info.summaryAttributes()...)}
+}
+
+// newID returns a random ID for a transaction or a prepared statement, see
+// SpanOptions.RecordTransactionID and SpanOptions.RecordStatementID.
+func newID() string {
+	return fmt.Sprintf("%016x", rand.Uint64())
 }
 
 func newTx(ctx context.Context, tx driver.Tx, cfg config) *otTx {
@@ -38,42 +114,66 @@ func newTx(ctx context.Context, tx driver.Tx, cfg config) *otTx {
 }
 
 func (t *otTx) Commit() (err error) {
+	if t.onEnd != nil {
+		defer func() { t.onEnd(err == nil) }()
+	}
 	method := MethodTxCommit
+	defer t.recordOutcomeDeferred(txOutcomeCommitted, &err)
+	if !t.cfg.SpanOptions.CompactSpans {
+		addEvent(t.ctx, EventTxCommitStart)
+	}
 	onDefer := recordMetric(t.ctx, t.cfg.Instruments, t.cfg, method, "", nil)
 	defer func() {
 		onDefer(err)
+		err = wrapError(t.ctx, t.cfg, err)
 	}()
 
 	var span trace.Span
-	if filterSpan(t.ctx, t.cfg.SpanOptions, method, "", nil) {
-		_, span = createSpan(t.ctx, t.cfg, method, false, "", nil)
-		defer span.End()
+	if !t.cfg.SpanOptions.OmitTxCommit && filterSpan(t.ctx, t.cfg.SpanOptions, method, "", nil) {
+		if t.cfg.SpanOptions.CompactSpans {
+			defer recordMethodEventDeferred(t.ctx, t.cfg.SpanOptions, method, time.Now(), &err)
+		} else {
+			_, span = createSpan(t.ctx, t.cfg, method, false, "", nil, t.spanStartOptions()...)
+			defer span.End()
+		}
 	}
 
 	err = t.tx.Commit()
 	if err != nil {
-		recordSpanError(span, t.cfg.SpanOptions, err)
+		recordSpanError(t.ctx, span, t.cfg, method, "", err)
 		return err
 	}
 	return nil
 }
 
 func (t *otTx) Rollback() (err error) {
+	if t.onEnd != nil {
+		defer t.onEnd(false)
+	}
 	method := MethodTxRollback
+	defer t.recordOutcomeDeferred(txOutcomeRolledBack, &err)
+	if !t.cfg.SpanOptions.CompactSpans {
+		addEvent(t.ctx, EventTxRollbackStart)
+	}
 	onDefer := recordMetric(t.ctx, t.cfg.Instruments, t.cfg, method, "", nil)
 	defer func() {
 		onDefer(err)
+		err = wrapError(t.ctx, t.cfg, err)
 	}()
 
 	var span trace.Span
-	if filterSpan(t.ctx, t.cfg.SpanOptions, method, "", nil) {
-		_, span = createSpan(t.ctx, t.cfg, method, false, "", nil)
-		defer span.End()
+	if !t.cfg.SpanOptions.OmitTxRollback && filterSpan(t.ctx, t.cfg.SpanOptions, method, "", nil) {
+		if t.cfg.SpanOptions.CompactSpans {
+			defer recordMethodEventDeferred(t.ctx, t.cfg.SpanOptions, method, time.Now(), &err)
+		} else {
+			_, span = createSpan(t.ctx, t.cfg, method, false, "", nil, t.spanStartOptions()...)
+			defer span.End()
+		}
 	}
 
 	err = t.tx.Rollback()
 	if err != nil {
-		recordSpanError(span, t.cfg.SpanOptions, err)
+		recordSpanError(t.ctx, span, t.cfg, method, "", err)
 		return err
 	}
 	return nil
@@ -15,13 +15,17 @@
 package otelsql
 
 import (
+	"context"
 	"database/sql/driver"
 	"errors"
+	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.18.0"
 )
 
 type mockTx struct {
@@ -79,52 +83,65 @@ func TestOtTx_Commit(t *testing.T) {
 		},
 	}
 
-	for _, spanFilterFn := range []SpanFilter{nil, omit, keep} {
-		testname := "spanFilterOmit"
-		if spanFilterFn == nil {
-			testname = "spanFilterNil"
-		} else if spanFilterFn(nil, "", "", []driver.NamedValue{}) {
-			testname = "spanFilterKeep"
+	for _, omitTxCommit := range []bool{true, false} {
+		var testname string
+		if omitTxCommit {
+			testname = "OmitTxCommit"
 		}
 
 		t.Run(testname, func(t *testing.T) {
-			for _, tc := range testCases {
-				t.Run(tc.name, func(t *testing.T) {
-					// Prepare traces
-					ctx, sr, tracer, dummySpan := prepareTraces(tc.noParentSpan)
-					mt := newMockTx(tc.error)
-
-					// New tx
-					cfg := newMockConfig(t, tracer)
-					cfg.SpanOptions.SpanFilter = spanFilterFn
-					cfg.AttributesGetter = tc.attributesGetter
-					cfg.InstrumentAttributesGetter = InstrumentAttributesGetter(tc.attributesGetter)
-					tx := newTx(ctx, mt, cfg)
-					// Commit
-					err := tx.Commit()
-					if tc.error {
-						require.Error(t, err)
-					} else {
-						require.NoError(t, err)
-					}
+			for _, spanFilterFn := range []SpanFilter{nil, omit, keep} {
+				testname := "spanFilterOmit"
+				if spanFilterFn == nil {
+					testname = "spanFilterNil"
+				} else if spanFilterFn(nil, "", "", []driver.NamedValue{}) {
+					testname = "spanFilterKeep"
+				}
+
+				t.Run(testname, func(t *testing.T) {
+					for _, tc := range testCases {
+						t.Run(tc.name, func(t *testing.T) {
+							// Prepare traces
+							ctx, sr, tracer, dummySpan := prepareTraces(tc.noParentSpan)
+							mt := newMockTx(tc.error)
+
+							// New tx
+							cfg := newMockConfig(t, tracer)
+							cfg.SpanOptions.OmitTxCommit = omitTxCommit
+							cfg.SpanOptions.SpanFilter = spanFilterFn
+							cfg.AttributesGetter = tc.attributesGetter
+							cfg.InstrumentAttributesGetter = InstrumentAttributesGetter(tc.attributesGetter)
+							tx := newTx(ctx, mt, cfg)
+							// Commit
+							err := tx.Commit()
+							if tc.error {
+								require.Error(t, err)
+							} else {
+								require.NoError(t, err)
+							}
+
+							spanList := sr.Ended()
+							omit := omitTxCommit
+							if !omit {
+								omit = !filterSpan(ctx, cfg.SpanOptions, MethodTxCommit, "", []driver.NamedValue{})
+							}
+							expectedSpanCount := getExpectedSpanCount(tc.noParentSpan, omit)
+							// One dummy span and one span created in tx
+							require.Equal(t, expectedSpanCount, len(spanList))
 
-					spanList := sr.Ended()
-					omit := !filterSpan(ctx, cfg.SpanOptions, MethodTxCommit, "", []driver.NamedValue{})
-					expectedSpanCount := getExpectedSpanCount(tc.noParentSpan, omit)
-					// One dummy span and one span created in tx
-					require.Equal(t, expectedSpanCount, len(spanList))
-
-					assertSpanList(t, spanList, spanAssertionParameter{
-						parentSpan:         dummySpan,
-						error:              tc.error,
-						expectedAttributes: cfg.Attributes,
-						method:             MethodTxCommit,
-						noParentSpan:       tc.noParentSpan,
-						attributesGetter:   tc.attributesGetter,
-						omitSpan:           omit,
-					})
-
-					assert.Equal(t, 1, mt.commitCount)
+							assertSpanList(t, spanList, spanAssertionParameter{
+								parentSpan:         dummySpan,
+								error:              tc.error,
+								expectedAttributes: cfg.Attributes,
+								method:             MethodTxCommit,
+								noParentSpan:       tc.noParentSpan,
+								attributesGetter:   tc.attributesGetter,
+								omitSpan:           omit,
+							})
+
+							assert.Equal(t, 1, mt.commitCount)
+						})
+					}
 				})
 			}
 		})
@@ -155,55 +172,234 @@ func TestOtTx_Rollback(t *testing.T) {
 		},
 	}
 
-	for _, spanFilterFn := range []SpanFilter{nil, omit, keep} {
-		testname := "spanFilterOmit"
-		if spanFilterFn == nil {
-			testname = "spanFilterNil"
-		} else if spanFilterFn(nil, "", "", []driver.NamedValue{}) {
-			testname = "spanFilterKeep"
+	for _, omitTxRollback := range []bool{true, false} {
+		var testname string
+		if omitTxRollback {
+			testname = "OmitTxRollback"
 		}
 
 		t.Run(testname, func(t *testing.T) {
-			for _, tc := range testCases {
-				t.Run(tc.name, func(t *testing.T) {
-					// Prepare traces
-					ctx, sr, tracer, dummySpan := prepareTraces(tc.noParentSpan)
-					mt := newMockTx(tc.error)
-
-					// New tx
-					cfg := newMockConfig(t, tracer)
-					cfg.SpanOptions.SpanFilter = spanFilterFn
-					cfg.AttributesGetter = tc.attributesGetter
-					cfg.InstrumentAttributesGetter = InstrumentAttributesGetter(tc.attributesGetter)
-					tx := newTx(ctx, mt, cfg)
-
-					// Rollback
-					err := tx.Rollback()
-					if tc.error {
-						require.Error(t, err)
-					} else {
-						require.NoError(t, err)
-					}
+			for _, spanFilterFn := range []SpanFilter{nil, omit, keep} {
+				testname := "spanFilterOmit"
+				if spanFilterFn == nil {
+					testname = "spanFilterNil"
+				} else if spanFilterFn(nil, "", "", []driver.NamedValue{}) {
+					testname = "spanFilterKeep"
+				}
 
-					spanList := sr.Ended()
-					omit := !filterSpan(ctx, cfg.SpanOptions, MethodTxRollback, "", []driver.NamedValue{})
-					expectedSpanCount := getExpectedSpanCount(tc.noParentSpan, omit)
-					// One dummy span and a span created in tx
-					require.Equal(t, expectedSpanCount, len(spanList))
-
-					assertSpanList(t, spanList, spanAssertionParameter{
-						parentSpan:         dummySpan,
-						error:              tc.error,
-						expectedAttributes: cfg.Attributes,
-						method:             MethodTxRollback,
-						noParentSpan:       tc.noParentSpan,
-						attributesGetter:   tc.attributesGetter,
-						omitSpan:           omit,
-					})
-
-					assert.Equal(t, 1, mt.rollbackCount)
+				t.Run(testname, func(t *testing.T) {
+					for _, tc := range testCases {
+						t.Run(tc.name, func(t *testing.T) {
+							// Prepare traces
+							ctx, sr, tracer, dummySpan := prepareTraces(tc.noParentSpan)
+							mt := newMockTx(tc.error)
+
+							// New tx
+							cfg := newMockConfig(t, tracer)
+							cfg.SpanOptions.OmitTxRollback = omitTxRollback
+							cfg.SpanOptions.SpanFilter = spanFilterFn
+							cfg.AttributesGetter = tc.attributesGetter
+							cfg.InstrumentAttributesGetter = InstrumentAttributesGetter(tc.attributesGetter)
+							tx := newTx(ctx, mt, cfg)
+
+							// Rollback
+							err := tx.Rollback()
+							if tc.error {
+								require.Error(t, err)
+							} else {
+								require.NoError(t, err)
+							}
+
+							spanList := sr.Ended()
+							omit := omitTxRollback
+							if !omit {
+								omit = !filterSpan(ctx, cfg.SpanOptions, MethodTxRollback, "", []driver.NamedValue{})
+							}
+							expectedSpanCount := getExpectedSpanCount(tc.noParentSpan, omit)
+							// One dummy span and a span created in tx
+							require.Equal(t, expectedSpanCount, len(spanList))
+
+							assertSpanList(t, spanList, spanAssertionParameter{
+								parentSpan:         dummySpan,
+								error:              tc.error,
+								expectedAttributes: cfg.Attributes,
+								method:             MethodTxRollback,
+								noParentSpan:       tc.noParentSpan,
+								attributesGetter:   tc.attributesGetter,
+								omitSpan:           omit,
+							})
+
+							assert.Equal(t, 1, mt.rollbackCount)
+						})
+					}
 				})
 			}
 		})
 	}
 }
+
+func TestOtTx_CompactSpans(t *testing.T) {
+	for _, shouldError := range []bool{false, true} {
+		for _, method := range []Method{MethodTxCommit, MethodTxRollback, MethodConnResetSession} {
+			t.Run(fmt.Sprintf("%s error=%t", method, shouldError), func(t *testing.T) {
+				_, sr, tracer, _ := prepareTraces(true)
+				ctx, parentSpan := tracer.Start(context.Background(), "parent")
+				cfg := newMockConfig(t, tracer)
+				cfg.SpanOptions.CompactSpans = true
+
+				var err error
+				switch method {
+				case MethodTxCommit:
+					err = newTx(ctx, newMockTx(shouldError), cfg).Commit()
+				case MethodTxRollback:
+					err = newTx(ctx, newMockTx(shouldError), cfg).Rollback()
+				default:
+					err = newConn(newMockConn(shouldError), cfg).ResetSession(ctx)
+				}
+				assert.Equal(t, shouldError, err != nil)
+				parentSpan.End()
+
+				// Only the parent span exists.
+				spanList := sr.Ended()
+				require.Len(t, spanList, 1)
+				events := spanList[0].Events()
+				require.Len(t, events, 1)
+				assert.Equal(t, string(method), events[0].Name)
+
+				attrs := attribute.NewSet(events[0].Attributes...)
+				assert.True(t, attrs.HasValue(eventDurationKey))
+				message, ok := attrs.Value(semconv.ExceptionMessageKey)
+				assert.Equal(t, shouldError, ok)
+				if shouldError {
+					assert.Equal(t, err.Error(), message.AsString())
+				}
+			})
+		}
+	}
+}
+
+func TestOtTx_StartEvents(t *testing.T) {
+	for _, method := range []Method{MethodTxCommit, MethodTxRollback} {
+		t.Run(string(method), func(t *testing.T) {
+			_, sr, tracer, _ := prepareTraces(true)
+			ctx, parentSpan := tracer.Start(context.Background(), "parent")
+			cfg := newMockConfig(t, tracer)
+
+			tx := newTx(ctx, newMockTx(false), cfg)
+			expectedEvent := EventTxCommitStart
+			if method == MethodTxCommit {
+				require.NoError(t, tx.Commit())
+			} else {
+				require.NoError(t, tx.Rollback())
+				expectedEvent = EventTxRollbackStart
+			}
+			parentSpan.End()
+
+			spanList := sr.Ended()
+			require.Len(t, spanList, 2)
+			assert.Equal(t, string(method), spanList[0].Name())
+			events := spanList[1].Events()
+			require.Len(t, events, 1)
+			assert.Equal(t, string(expectedEvent), events[0].Name)
+			assert.False(t, events[0].Time.After(spanList[0].StartTime()))
+		})
+	}
+}
+
+func TestOtConn_RecordTransactionID(t *testing.T) {
+	_, sr, tracer, _ := prepareTraces(true)
+	ctx := context.Background()
+	cfg := newMockConfig(t, tracer)
+	cfg.SpanOptions.RecordTransactionID = true
+	otelConn := newConn(newMockConn(false), cfg)
+
+	txIDs := func() []string {
+		var ids []string
+		for _, span := range sr.Ended() {
+			attrs := attribute.NewSet(span.Attributes()...)
+			id, _ := attrs.Value(dbTransactionIDKey)
+			ids = append(ids, id.AsString())
+		}
+		return ids
+	}
+
+	for i := 0; i < 2; i++ {
+		tx, err := otelConn.BeginTx(ctx, driver.TxOptions{})
+		require.NoError(t, err)
+		_, err = otelConn.ExecContext(ctx, "UPDATE t SET a = 1", nil)
+		require.NoError(t, err)
+		stmt, err := otelConn.PrepareContext(ctx, "SELECT 1")
+		require.NoError(t, err)
+		_, err = stmt.(*otStmt).QueryContext(ctx, nil)
+		require.NoError(t, err)
+		require.NoError(t, tx.Commit())
+	}
+	// Calls made outside of a transaction have no ID.
+	_, err := otelConn.ExecContext(ctx, "UPDATE t SET a = 1", nil)
+	require.NoError(t, err)
+
+	// begin_tx, exec, prepare, stmt.query, and commit, twice, then exec.
+	ids := txIDs()
+	require.Len(t, ids, 11)
+	first, second := ids[:5], ids[5:10]
+	assert.NotEmpty(t, first[0])
+	assert.NotEqual(t, first[0], second[0])
+	for i := range first {
+		assert.Equal(t, first[0], first[i])
+		assert.Equal(t, second[0], second[i])
+	}
+	assert.Empty(t, ids[10])
+}
+
+type mockConnWithResult struct {
+	*mockConn
+}
+
+func (m mockConnWithResult) ExecContext(context.Context, string, []driver.NamedValue) (driver.Result, error) {
+	return driver.RowsAffected(3), nil
+}
+
+func TestOtTx_RecordTransactionSummary(t *testing.T) {
+	for _, method := range []Method{MethodTxCommit, MethodTxRollback} {
+		t.Run(string(method), func(t *testing.T) {
+			_, sr, tracer, _ := prepareTraces(true)
+			ctx := context.Background()
+			cfg := newMockConfig(t, tracer)
+			cfg.SpanOptions.RecordTransactionSummary = true
+			otelConn := newConn(mockConnWithResult{newMockConn(false)}, cfg)
+
+			tx, err := otelConn.BeginTx(ctx, driver.TxOptions{})
+			require.NoError(t, err)
+			for i := 0; i < 2; i++ {
+				_, err = otelConn.ExecContext(ctx, "UPDATE t SET a = 1", nil)
+				require.NoError(t, err)
+			}
+			_, err = otelConn.QueryContext(ctx, "SELECT 1", nil)
+			require.NoError(t, err)
+			if method == MethodTxCommit {
+				require.NoError(t, tx.Commit())
+			} else {
+				require.NoError(t, tx.Rollback())
+			}
+			// Statements executed after the transaction are not counted.
+			_, err = otelConn.ExecContext(ctx, "UPDATE t SET a = 1", nil)
+			require.NoError(t, err)
+
+			spanList := sr.Ended()
+			var endSpan sdktrace.ReadOnlySpan
+			for _, span := range spanList {
+				if span.Name() == string(method) {
+					endSpan = span
+				}
+			}
+			require.NotNil(t, endSpan)
+			attrs := attribute.NewSet(endSpan.Attributes()...)
+			statements, _ := attrs.Value(dbTransactionStatementsKey)
+			assert.EqualValues(t, 3, statements.AsInt64())
+			rowsAffected, _ := attrs.Value(dbTransactionRowsAffectedKey)
+			assert.EqualValues(t, 6, rowsAffected.AsInt64())
+			assert.True(t, attrs.HasValue(dbTransactionDurationKey))
+			assert.Nil(t, otelConn.tx)
+		})
+	}
+}
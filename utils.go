@@ -18,40 +18,104 @@ import (
 	"context"
 	"database/sql/driver"
 	"errors"
+	"reflect"
+	"slices"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/metric"
 	semconv "go.opentelemetry.io/otel/semconv/v1.18.0"
 	"go.opentelemetry.io/otel/trace"
+
+	"github.com/XSAM/otelsql/semconvutil"
 )
 
-func recordSpanErrorDeferred(span trace.Span, opts SpanOptions, err *error) {
-	recordSpanError(span, opts, *err)
+func recordSpanErrorDeferred(
+	ctx context.Context, span trace.Span, cfg config, method Method, query string, err *error,
+) {
+	recordSpanError(ctx, span, cfg, method, query, *err)
 }
 
-func recordSpanError(span trace.Span, opts SpanOptions, err error) {
-	if span == nil {
+// recordMethodEventDeferred adds an event named after method to the span of
+// ctx, in place of a span for the method, see SpanOptions.CompactSpans. The
+// event carries the duration of the call and the error, if any.
+func recordMethodEventDeferred(ctx context.Context, opts SpanOptions, method Method, start time.Time, err *error) {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
 		return
 	}
-	if opts.RecordError != nil && !opts.RecordError(err) {
+
+	attrs := []attribute.KeyValue{eventDurationKey.Float64(time.Since(start).Seconds())}
+	if *err != nil && shouldRecordError(ctx, opts, method, "", *err) {
+		attrs = append(attrs,
+			semconv.ExceptionType(reflect.TypeOf(*err).String()),
+			semconv.ExceptionMessage((*err).Error()),
+		)
+	}
+	span.AddEvent(string(method), trace.WithTimestamp(start), trace.WithAttributes(attrs...))
+}
+
+// addEvent adds event to the span of ctx, if it is recording.
+func addEvent(ctx context.Context, event Event) {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
 		return
 	}
+	span.AddEvent(string(event))
+}
 
-	switch err {
-	case nil:
+// endSpanOnErrorDeferred ends span if the call failed. It is used for query
+// spans that are otherwise ended by the returned rows.
+func endSpanOnErrorDeferred(span trace.Span, err *error) {
+	if *err != nil {
+		span.End()
+	}
+}
+
+func recordSpanError(ctx context.Context, span trace.Span, cfg config, method Method, query string, err error) {
+	if span == nil || err == nil || !span.IsRecording() {
+		return
+	}
+	opts := cfg.SpanOptions
+	if !shouldRecordError(ctx, opts, method, query, err) || err == driver.ErrSkip && opts.DisableErrSkip {
 		return
-	case driver.ErrSkip:
-		if !opts.DisableErrSkip {
-			span.RecordError(err)
-			span.SetStatus(codes.Error, "")
+	}
+
+	// Errors that are not recorded, like expected ones, are not classified
+	// either.
+	if attrs := classifyError(cfg, err); len(attrs) > 0 {
+		span.SetAttributes(attrs...)
+	}
+	setSpanError(span, opts, err)
+}
+
+// shouldRecordError reports whether err returned by a call of method with
+// query is recorded, according to SpanOptions.RecordError and
+// SpanOptions.RecordErrorWithContext.
+func shouldRecordError(ctx context.Context, opts SpanOptions, method Method, query string, err error) bool {
+	if opts.RecordError != nil && !opts.RecordError(err) {
+		return false
+	}
+	return opts.RecordErrorWithContext == nil || opts.RecordErrorWithContext(ctx, method, query, err)
+}
+
+func setSpanError(span trace.Span, opts SpanOptions, err error) {
+	if opts.ErrorRecordingMode != ErrorRecordingModeStatus {
+		var eventOptions []trace.EventOption
+		if opts.RecordErrorStackTrace {
+			eventOptions = append(eventOptions, trace.WithStackTrace(true))
 		}
-	default:
-		span.RecordError(err)
+		span.RecordError(err, eventOptions...)
+	}
+	if opts.ErrorRecordingMode != ErrorRecordingModeEvent {
 		span.SetStatus(codes.Error, "")
 	}
 }
 
+// noopOnDefer is returned by recordMetric when there is nothing to record.
+func noopOnDefer(error) {}
+
 func recordMetric(
 	ctx context.Context,
 	instruments *instruments,
@@ -59,33 +123,119 @@ func recordMetric(
 	method Method,
 	query string,
 	args []driver.NamedValue,
+	extraAttributes ...attribute.KeyValue,
+) func(error) {
+	onDriverCallEnd := observeDriverCall(ctx)
+	if cfg.QueryAggregator != nil && query != "" {
+		switch method {
+		case MethodConnExec, MethodConnQuery, MethodStmtExec, MethodStmtQuery:
+			onDriverCallEnd = cfg.QueryAggregator.observe(query, onDriverCallEnd)
+		}
+	}
+	if cfg.inFlightCalls != nil && method != MethodRows {
+		onDriverCallEnd = cfg.inFlightCalls.track(method, query, onDriverCallEnd)
+	}
+	if cfg.noopMeter || instruments == nil {
+		if onDriverCallEnd != nil {
+			return onDriverCallEnd
+		}
+		return noopOnDefer
+	}
+	return newMetricRecorder(ctx, instruments, cfg, method, query, args, onDriverCallEnd, extraAttributes)
+}
+
+// newMetricRecorder returns the func of recordMetric recording the latency of
+// a call. It is split from recordMetric so that cfg is only moved to the heap
+// when there is something to record.
+func newMetricRecorder(
+	ctx context.Context,
+	instruments *instruments,
+	cfg config,
+	method Method,
+	query string,
+	args []driver.NamedValue,
+	onDriverCallEnd func(error),
+	extraAttributes []attribute.KeyValue,
 ) func(error) {
+	// The call is counted as in flight with the same attributes until it
+	// ends, so that the increment and the decrement cancel out.
+	var inFlightAttributes metric.AddOption
+	if instruments.inFlight != nil {
+		inFlightAttributes = metric.WithAttributes(
+			append(slices.Clip(cfg.Attributes), queryMethodKey.String(string(method)))...,
+		)
+		instruments.inFlight.Add(ctx, 1, inFlightAttributes)
+	}
 	startTime := time.Now()
 
 	return func(err error) {
-		duration := float64(time.Since(startTime).Nanoseconds()) / 1e6
+		if onDriverCallEnd != nil {
+			onDriverCallEnd(err)
+		}
+		if instruments.inFlight != nil {
+			instruments.inFlight.Add(ctx, -1, inFlightAttributes)
+		}
+		elapsed := time.Since(startTime)
 
-		attributes := cfg.Attributes
+		attributes := append(slices.Clip(cfg.Attributes), extraAttributes...)
+		attributes = append(attributes, contextAttributes(ctx)...)
+		attributes = append(attributes, namespaceAttributes(ctx, cfg)...)
+		for _, limiter := range []*metricAttributeLimiter{cfg.querySummaries, cfg.collectionNames} {
+			if limiter == nil || query == "" {
+				continue
+			}
+			if attr, ok := limiter.attribute(query); ok {
+				attributes = append(attributes, attr)
+			}
+		}
+		if cfg.tenants != nil {
+			if attr, ok := cfg.tenants.limitedAttribute(cfg.TenantGetter(ctx)); ok {
+				attributes = append(attributes, attr)
+			}
+		}
 		if cfg.InstrumentAttributesGetter != nil {
 			attributes = append(attributes, cfg.InstrumentAttributesGetter(ctx, method, query, args)...)
 		}
-		if err != nil {
-			if cfg.DisableSkipErrMeasurement && err == driver.ErrSkip {
-				attributes = append(attributes, queryStatusKey.String("ok"))
-			} else {
-				attributes = append(attributes, queryStatusKey.String("error"))
+		status := queryStatusKey.String("ok")
+		// Skipped and expected errors are not failures, so they are not
+		// classified either.
+		if err != nil && !(cfg.DisableSkipErrMeasurement && err == driver.ErrSkip ||
+			cfg.ExpectedErrors != nil && cfg.ExpectedErrors(err)) {
+			status = queryStatusKey.String("error")
+			attributes = append(attributes, classifyError(cfg, err)...)
+		}
+		attributes = append(attributes, queryMethodKey.String(string(method)))
+
+		if instruments.latency != nil {
+			duration := float64(elapsed.Nanoseconds()) / 1e6
+			if instruments.latencySeconds {
+				duration = elapsed.Seconds()
 			}
-		} else {
-			attributes = append(attributes, queryStatusKey.String("ok"))
+			instruments.latency.Record(
+				ctx,
+				duration,
+				metric.WithAttributes(append(slices.Clip(attributes), status)...),
+			)
 		}
 
-		attributes = append(attributes, queryMethodKey.String(string(method)))
+		// The stable conventions report failures with error.type rather
+		// than the status, and the operation of the query.
+		if instruments.operationDuration != nil {
+			if operation := operationName(query); operation != "" {
+				attributes = append(attributes, dbOperationNameKey.String(operation))
+			}
+			instruments.operationDuration.Record(
+				ctx,
+				elapsed.Seconds(),
+				metric.WithAttributes(attributes...),
+			)
+		}
 
-		instruments.latency.Record(
-			ctx,
-			duration,
-			metric.WithAttributes(attributes...),
-		)
+		if instruments.connectionErrors != nil && errors.Is(err, driver.ErrBadConn) {
+			instruments.connectionErrors.Add(ctx, 1, metric.WithAttributes(
+				append(slices.Clip(cfg.Attributes), queryMethodKey.String(string(method)))...,
+			))
+		}
 	}
 }
 
@@ -96,19 +246,64 @@ func createSpan(
 	enableDBStatement bool,
 	query string,
 	args []driver.NamedValue,
+	opts ...trace.SpanStartOption,
 ) (context.Context, trace.Span) {
-	attrs := cfg.Attributes
+	// Only the attributes of cfg and the statement are set on start, for
+	// samplers. The other attributes are set once the span is known to be
+	// recording, so that they are not computed for sampled-out spans.
+	opts = append(opts,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(cfg.Attributes...),
+	)
 	if enableDBStatement && !cfg.SpanOptions.DisableQuery {
-		attrs = append(attrs, semconv.DBStatementKey.String(query))
+		opts = append(opts, trace.WithAttributes(semconvutil.DBQueryTextAttributes(query, cfg.semconvStability)...))
+	}
+	ctx, span := cfg.Tracer.Start(ctx, cfg.SpanNameFormatter(ctx, method, query), opts...)
+	if !span.IsRecording() {
+		return ctx, span
+	}
+
+	attrs := cfg.serverProbe.systemAttributes()
+	// The operation is recorded even if DisableQuery is set, so spans can be
+	// filtered on it without parsing span names or queries.
+	if enableDBStatement && cfg.semconvStability != semconvutil.StabilityOld {
+		if operation := operationName(query); operation != "" {
+			attrs = append(attrs, dbOperationNameKey.String(operation))
+		}
+	}
+	attrs = append(attrs, contextAttributes(ctx)...)
+	if cfg.TenantGetter != nil {
+		if tenant := cfg.TenantGetter(ctx); tenant != "" {
+			attrs = append(attrs, tenantIDKey.String(tenant))
+		}
+	}
+	attrs = append(attrs, namespaceAttributes(ctx, cfg)...)
+	if id := txIDFromContext(ctx); id != "" {
+		attrs = append(attrs, dbTransactionIDKey.String(id))
+	}
+	if isRetry(ctx) {
+		attrs = append(attrs, dbOperationRetryKey.Bool(true))
+	}
+	if cfg.SpanOptions.RecordDeadline {
+		if deadline, ok := ctx.Deadline(); ok {
+			attrs = append(attrs, deadlineRemainingKey.Float64(time.Until(deadline).Seconds()))
+		}
 	}
 	if cfg.AttributesGetter != nil {
 		attrs = append(attrs, cfg.AttributesGetter(ctx, method, query, args)...)
 	}
+	span.SetAttributes(attrs...)
+	return ctx, span
+}
 
-	return cfg.Tracer.Start(ctx, cfg.SpanNameFormatter(ctx, method, query),
-		trace.WithSpanKind(trace.SpanKindClient),
-		trace.WithAttributes(attrs...),
-	)
+// redactArgs returns args as passed to the telemetry of a call, redacted by
+// the ArgsRedactor of cfg. The redactor gets a copy of args, which are also
+// passed to the driver.
+func redactArgs(cfg config, args []driver.NamedValue) []driver.NamedValue {
+	if cfg.ArgsRedactor == nil || len(args) == 0 {
+		return args
+	}
+	return cfg.ArgsRedactor(slices.Clone(args))
 }
 
 func filterSpan(
@@ -118,7 +313,17 @@ func filterSpan(
 	query string,
 	args []driver.NamedValue,
 ) bool {
-	return spanOptions.SpanFilter == nil || spanOptions.SpanFilter(ctx, method, query, args)
+	if spanOptions.SpanFilter != nil && !spanOptions.SpanFilter(ctx, method, query, args) {
+		return false
+	}
+	if spanOptions.ParentSpanFilter != nil {
+		var parent trace.SpanContext
+		if ctx != nil {
+			parent = trace.SpanContextFromContext(ctx)
+		}
+		return spanOptions.ParentSpanFilter(ctx, parent, method, query, args)
+	}
+	return true
 }
 
 // Copied from stdlib database/sql package: src/database/sql/ctxutil.go.
@@ -20,6 +20,7 @@ import (
 	"errors"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -30,6 +31,8 @@ import (
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 	"go.opentelemetry.io/otel/trace"
+
+	"github.com/XSAM/otelsql/semconvutil"
 )
 
 func TestRecordSpanError(t *testing.T) {
@@ -79,6 +82,31 @@ func TestRecordSpanError(t *testing.T) {
 			opts:          SpanOptions{RecordError: func(_ error) bool { return true }},
 			expectedError: true,
 		},
+		{
+			name: "avoid recording error due to RecordErrorWithContext option",
+			err:  errors.New("error"),
+			opts: SpanOptions{RecordErrorWithContext: func(_ context.Context, method Method, _ string, _ error) bool {
+				return method != MethodConnQuery
+			}},
+			expectedError: false,
+		},
+		{
+			name: "record error with context returns true",
+			err:  errors.New("error"),
+			opts: SpanOptions{RecordErrorWithContext: func(_ context.Context, method Method, _ string, _ error) bool {
+				return method == MethodConnQuery
+			}},
+			expectedError: true,
+		},
+		{
+			name: "avoid recording error due to RecordError option despite RecordErrorWithContext",
+			err:  errors.New("error"),
+			opts: SpanOptions{
+				RecordError:            func(_ error) bool { return false },
+				RecordErrorWithContext: func(context.Context, Method, string, error) bool { return true },
+			},
+			expectedError: false,
+		},
 		{
 			name:          "nil span",
 			err:           nil,
@@ -101,7 +129,7 @@ func TestRecordSpanError(t *testing.T) {
 				span := spanList[0]
 
 				// Update the span
-				recordSpanError(span, tc.opts, tc.err)
+				recordSpanError(context.Background(), span, config{SpanOptions: tc.opts}, MethodConnQuery, "", tc.err)
 
 				// Check result
 				if tc.expectedError {
@@ -110,12 +138,165 @@ func TestRecordSpanError(t *testing.T) {
 					assert.Equal(t, codes.Unset, span.Status().Code)
 				}
 			} else {
-				recordSpanError(nil, tc.opts, tc.err)
+				recordSpanError(context.Background(), nil, config{SpanOptions: tc.opts}, MethodConnQuery, "", tc.err)
 			}
 		})
 	}
 }
 
+func TestRecordSpanErrorWithErrorRecordingMode(t *testing.T) {
+	testCases := []struct {
+		mode               ErrorRecordingMode
+		expectedEventCount int
+		expectedStatusCode codes.Code
+	}{
+		{
+			mode:               ErrorRecordingModeBoth,
+			expectedEventCount: 1,
+			expectedStatusCode: codes.Error,
+		},
+		{
+			mode:               ErrorRecordingModeEvent,
+			expectedEventCount: 1,
+			expectedStatusCode: codes.Unset,
+		},
+		{
+			mode:               ErrorRecordingModeStatus,
+			expectedEventCount: 0,
+			expectedStatusCode: codes.Error,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprint(tc.mode), func(t *testing.T) {
+			_, sr, tracer, _ := prepareTraces(true)
+			_, span := tracer.Start(context.Background(), "test")
+
+			recordSpanError(context.Background(), span, config{SpanOptions: SpanOptions{ErrorRecordingMode: tc.mode}}, MethodConnQuery, "", errors.New("error"))
+			span.End()
+
+			spanList := sr.Ended()
+			require.Len(t, spanList, 1)
+			assert.Len(t, spanList[0].Events(), tc.expectedEventCount)
+			assert.Equal(t, tc.expectedStatusCode, spanList[0].Status().Code)
+		})
+	}
+}
+
+func TestRecordSpanErrorWithStackTrace(t *testing.T) {
+	for _, recordStackTrace := range []bool{true, false} {
+		_, sr, tracer, _ := prepareTraces(true)
+		_, span := tracer.Start(context.Background(), "test")
+
+		recordSpanError(context.Background(), span, config{SpanOptions: SpanOptions{RecordErrorStackTrace: recordStackTrace}}, MethodConnQuery, "", errors.New("error"))
+		span.End()
+
+		events := sr.Ended()[0].Events()
+		require.Len(t, events, 1)
+
+		var hasStackTrace bool
+		for _, attr := range events[0].Attributes {
+			if attr.Key == "exception.stacktrace" {
+				hasStackTrace = true
+			}
+		}
+		assert.Equal(t, recordStackTrace, hasStackTrace)
+	}
+}
+
+func TestCreateSpanWithOperationName(t *testing.T) {
+	testCases := []struct {
+		name              string
+		stability         semconvutil.Stability
+		disableQuery      bool
+		enableDBStatement bool
+		query             string
+		expected          string
+	}{
+		{name: "old", query: "SELECT 1", enableDBStatement: true},
+		{name: "stable", stability: semconvutil.StabilityStable, query: "/* c */ select 1", enableDBStatement: true, expected: "SELECT"},
+		{name: "dup", stability: semconvutil.StabilityDup, query: "UPDATE t SET a = 1", enableDBStatement: true, expected: "UPDATE"},
+		{name: "stable without query", stability: semconvutil.StabilityStable, query: "", enableDBStatement: true},
+		{name: "stable with DisableQuery", stability: semconvutil.StabilityStable, disableQuery: true, query: "DELETE FROM t", enableDBStatement: true, expected: "DELETE"},
+		{name: "stable without db.statement", stability: semconvutil.StabilityStable, query: "SELECT 1"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, sr, tracer, _ := prepareTraces(true)
+			cfg := newMockConfig(t, tracer)
+			cfg.semconvStability = tc.stability
+			cfg.SpanOptions.DisableQuery = tc.disableQuery
+
+			_, span := createSpan(context.Background(), cfg, MethodConnQuery, tc.enableDBStatement, tc.query, nil)
+			span.End()
+
+			spanList := sr.Ended()
+			require.Len(t, spanList, 1)
+			attrs := attribute.NewSet(spanList[0].Attributes()...)
+			operation, ok := attrs.Value(dbOperationNameKey)
+			assert.Equal(t, tc.expected != "", ok)
+			assert.Equal(t, tc.expected, operation.AsString())
+		})
+	}
+}
+
+func TestCreateSpanWithSemconvStability(t *testing.T) {
+	const query = "SELECT 1"
+	for _, stability := range []semconvutil.Stability{
+		semconvutil.StabilityOld, semconvutil.StabilityStable, semconvutil.StabilityDup,
+	} {
+		_, sr, tracer, _ := prepareTraces(true)
+		cfg := newMockConfig(t, tracer)
+		cfg.semconvStability = stability
+
+		_, span := createSpan(context.Background(), cfg, MethodConnQuery, true, query, nil)
+		span.End()
+
+		spanList := sr.Ended()
+		require.Len(t, spanList, 1)
+		for _, attr := range semconvutil.DBQueryTextAttributes(query, stability) {
+			assert.Contains(t, spanList[0].Attributes(), attr)
+		}
+	}
+}
+
+func TestCreateSpanWithRecordDeadline(t *testing.T) {
+	for _, recordDeadline := range []bool{true, false} {
+		_, sr, tracer, _ := prepareTraces(true)
+		cfg := newMockConfig(t, tracer)
+		cfg.SpanOptions.RecordDeadline = recordDeadline
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		_, span := createSpan(ctx, cfg, MethodConnExec, false, "", nil)
+		span.End()
+		cancel()
+
+		spanList := sr.Ended()
+		require.Len(t, spanList, 1)
+
+		var remaining float64
+		for _, attr := range spanList[0].Attributes() {
+			if attr.Key == deadlineRemainingKey {
+				remaining = attr.Value.AsFloat64()
+			}
+		}
+		if recordDeadline {
+			assert.InDelta(t, time.Minute.Seconds(), remaining, 5)
+		} else {
+			assert.Zero(t, remaining)
+		}
+	}
+
+	// No deadline
+	_, sr, tracer, _ := prepareTraces(true)
+	cfg := newMockConfig(t, tracer)
+	cfg.SpanOptions.RecordDeadline = true
+	_, span := createSpan(context.Background(), cfg, MethodConnExec, false, "", nil)
+	span.End()
+	assert.Equal(t, cfg.Attributes, sr.Ended()[0].Attributes())
+}
+
 func newTracerProvider() (*tracetest.SpanRecorder, trace.TracerProvider) {
 	var sr tracetest.SpanRecorder
 	provider := sdktrace.NewTracerProvider(
@@ -134,7 +315,7 @@ func newMockConfig(t *testing.T, tracer trace.Tracer) config {
 	// TODO: use mock meter instead of noop meter
 	meter := noop.NewMeterProvider().Meter("test")
 
-	instruments, err := newInstruments(meter)
+	instruments, err := newInstruments(meter, DurationUnitMilliseconds, semconvutil.StabilityOld)
 	require.NoError(t, err)
 
 	return config{
@@ -259,82 +440,61 @@ var keep SpanFilter = func(_ context.Context, _ Method, _ string, _ []driver.Nam
 	return true
 }
 
-func TestRecordMetric(t *testing.T) {
-	type args struct {
-		ctx    context.Context
-		cfg    config
-		method Method
-		query  string
-		args   []driver.NamedValue
-	}
-	tests := []struct {
-		name           string
-		args           args
-		recordErr      error
-		expectedStatus string
-	}{
-		{
-			name: "metric with no error",
-			args: args{
-				cfg:    newConfig(),
-				method: MethodConnQuery,
-				query:  "example query",
-			},
-			recordErr:      nil,
-			expectedStatus: "ok",
-		},
-		{
-			name: "metric with an error",
-			args: args{
-				cfg:    newConfig(),
-				method: MethodConnQuery,
-				query:  "example query",
-			},
-			recordErr:      assert.AnError,
-			expectedStatus: "error",
-		},
-		{
-			name: "metric with skip error but not disabled",
-			args: args{
-				cfg:    newConfig(),
-				method: MethodConnQuery,
-				query:  "example query",
-			},
-			recordErr:      driver.ErrSkip,
-			expectedStatus: "error",
-		},
-		{
-			name: "metric with skip error but disabled",
-			args: args{
-				cfg:    newConfig(WithDisableSkipErrMeasurement(true)),
-				method: MethodConnQuery,
-				query:  "example query",
-			},
-			recordErr:      driver.ErrSkip,
-			expectedStatus: "ok",
-		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			mockLatency := &float64HistogramMock{}
-			mockInstruments := &instruments{
-				latency: mockLatency,
-			}
-			recordFunc := recordMetric(tt.args.ctx, mockInstruments, tt.args.cfg, tt.args.method, tt.args.query, tt.args.args)
-			recordFunc(tt.recordErr)
-			assert.Equal(t, tt.expectedStatus, mockLatency.status)
-		})
-	}
+type tenantContextKey struct{}
+
+func tenantFromContext(ctx context.Context) string {
+	tenant, _ := ctx.Value(tenantContextKey{}).(string)
+	return tenant
+}
+
+func TestCreateSpanWithTenant(t *testing.T) {
+	_, sr, tracer, _ := prepareTraces(true)
+	cfg := newMockConfig(t, tracer)
+	cfg.TenantGetter = tenantFromContext
+
+	_, span := createSpan(context.WithValue(context.Background(), tenantContextKey{}, "acme"), cfg, MethodConnQuery, true, "SELECT 1", nil)
+	span.End()
+	_, span = createSpan(context.Background(), cfg, MethodConnQuery, true, "SELECT 1", nil)
+	span.End()
+
+	spanList := sr.Ended()
+	require.Len(t, spanList, 2)
+	assert.Contains(t, spanList[0].Attributes(), tenantIDKey.String("acme"))
+	attrs := attribute.NewSet(spanList[1].Attributes()...)
+	assert.False(t, attrs.HasValue(tenantIDKey))
 }
 
 type float64HistogramMock struct {
 	// Add metric.Float64Histogram so we only need to implement the function we care about for the mock
 	metric.Float64Histogram
-	status string
+	status     string
+	attributes attribute.Set
 }
 
 func (m *float64HistogramMock) Record(_ context.Context, _ float64, opts ...metric.RecordOption) {
 	attr := metric.NewRecordConfig(opts).Attributes()
 	statusVal, _ := attr.Value(queryStatusKey)
 	m.status = statusVal.AsString()
+	m.attributes = attr
+}
+
+func TestCreateSpanNotRecording(t *testing.T) {
+	_, _, tracer, _ := prepareTraces(true)
+	cfg := newMockConfig(t, tracer)
+	var getterCalls int
+	cfg.AttributesGetter = func(context.Context, Method, string, []driver.NamedValue) []attribute.KeyValue {
+		getterCalls++
+		return nil
+	}
+
+	_, span := createSpan(context.Background(), cfg, MethodConnQuery, true, "SELECT 1", nil)
+	span.End()
+	assert.Equal(t, 1, getterCalls)
+
+	cfg.Tracer = sdktrace.NewTracerProvider(sdktrace.WithSampler(sdktrace.NeverSample())).Tracer("test")
+	_, span = createSpan(context.Background(), cfg, MethodConnQuery, true, "SELECT 1", nil)
+	assert.False(t, span.IsRecording())
+	recordSpanError(context.Background(), span, cfg, MethodConnQuery, "SELECT 1", errors.New("error"))
+	span.End()
+	assert.Equal(t, 1, getterCalls)
 }